@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
 	"log/slog"
@@ -8,6 +9,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/peterbourgon/ff/v4"
 	"github.com/peterbourgon/ff/v4/ffhelp"
@@ -31,17 +33,33 @@ func main() {
 
 	fs := ff.NewFlagSet("hsa-tracker")
 	var (
-		port        = fs.IntLong("port", 8080, "HTTP server port")
-		dbPath      = fs.StringLong("db", "hsa-tracker.db", "Database file path")
-		storagePath = fs.StringLong("storage", "./receipts", "Storage directory path")
-		scannerType = fs.StringLong("scanner", "gemini", "Scanner type: 'gemini' or 'ollama'")
-		geminiKey   = fs.StringLong("gemini-key", "", "Google Gemini API key (or set GEMINI_API_KEY env var)")
-		geminiModel = fs.StringLong("gemini-model", "gemini-2.5-pro", "Google Gemini model name")
-		ollamaURL   = fs.StringLong("ollama-url", "http://localhost:11434", "Ollama API base URL")
-		ollamaModel = fs.StringLong("ollama-model", "llava", "Ollama model name (e.g., llava, llava-phi3, bakllava, qwen2-vl)")
-		authUser    = fs.StringLong("auth-user", "", "Basic auth username (optional)")
-		authPass    = fs.StringLong("auth-pass", "", "Basic auth password (optional)")
-		showVersion = fs.BoolLong("version", "Show version information")
+		port         = fs.IntLong("port", 8080, "HTTP server port")
+		dbPath       = fs.StringLong("db", "hsa-tracker.db", "Database file path")
+		storagePath  = fs.StringLong("storage", "./receipts", "Storage directory path")
+		scannerType  = fs.StringLong("scanner", "gemini", "Scanner type: 'gemini', 'ollama', 'openai' or 'tesseract'; a comma-separated list (e.g., ollama,gemini) combines scanners")
+		scannerMode  = fs.StringLong("scanner-mode", "fallback", "How a list of scanners is combined: 'fallback' tries each in order, 'consensus' runs two and flags details they disagree on")
+		fallbackConf = fs.Float64Long("fallback-confidence", scanning.DefaultFallbackConfidence, "Lowest title, date or amount confidence accepted before falling back to the next scanner")
+		geminiKey    = fs.StringLong("gemini-key", "", "Google Gemini API key (or set GEMINI_API_KEY env var)")
+		geminiModel  = fs.StringLong("gemini-model", "gemini-2.5-pro", "Google Gemini model name")
+		geminiLimit  = providerLimits(fs, "gemini", 30*time.Second, 4)
+		ollamaURL    = fs.StringLong("ollama-url", "http://localhost:11434", "Ollama API base URL")
+		ollamaModel  = fs.StringLong("ollama-model", "llava", "Ollama model name (e.g., llava, llava-phi3, bakllava, qwen2-vl)")
+		ollamaLimit  = providerLimits(fs, "ollama", 120*time.Second, 1)
+		openAIURL    = fs.StringLong("openai-url", "https://api.openai.com/v1", "OpenAI-compatible API base URL, including the version (e.g., http://localhost:8000/v1 for vLLM)")
+		openAIModel  = fs.StringLong("openai-model", "gpt-4o-mini", "Model name served by the OpenAI-compatible API")
+		openAIKey    = fs.StringLong("openai-key", "", "OpenAI-compatible API key (optional; or set OPENAI_API_KEY env var)")
+		openAILimit  = providerLimits(fs, "openai", 120*time.Second, 4)
+		tessPath     = fs.StringLong("tesseract-path", "tesseract", "Tesseract executable, found on PATH if it isn't a path")
+		tessLang     = fs.StringLong("tesseract-lang", "eng", "Tesseract language code; its trained data must be installed")
+		authUser     = fs.StringLong("auth-user", "", "Basic auth username (optional)")
+		authPass     = fs.StringLong("auth-pass", "", "Basic auth password (optional)")
+		draftMaxAge  = fs.DurationLong("draft-max-age", 24*time.Hour, "How long unreviewed scans are kept before they're deleted")
+		sweepEvery   = fs.DurationLong("sweep-interval", time.Hour, "How often to delete abandoned drafts and unreferenced files")
+		trashKeep    = fs.DurationLong("trash-retention", 30*24*time.Hour, "How long deleted receipts stay in the trash before they're purged")
+		pdfMaxPages  = fs.IntLong("pdf-max-pages", scanning.DefaultPDFMaxPages, "Most pages of a PDF sent to the scanner; longer PDFs send the first pages and the last")
+		pdfDPI       = fs.Float64Long("pdf-dpi", scanning.DefaultPDFDPI, "Resolution PDF pages are rendered at before scanning")
+		promptsDir   = fs.StringLong("prompts-dir", "", "Directory of *.tmpl prompt templates that override the built-in scanner prompts (optional)")
+		showVersion  = fs.BoolLong("version", "Show version information")
 	)
 
 	if err := ff.Parse(fs, os.Args[1:],
@@ -69,33 +87,100 @@ func main() {
 
 	// Initialize scanner based on type
 	var scanner scanning.Scanner
-	switch *scannerType {
-	case "gemini":
-		// Get Gemini API key from flag or environment
-		apiKey := *geminiKey
-		if apiKey == "" {
-			apiKey = os.Getenv("GEMINI_API_KEY")
+	scanOpts := []scanning.Option{
+		scanning.WithPDFMaxPages(*pdfMaxPages),
+		scanning.WithPDFDPI(*pdfDPI),
+	}
+	if *promptsDir != "" {
+		prompts, err := scanning.LoadPrompts(*promptsDir)
+		if err != nil {
+			slog.Error("Failed to load prompt templates", "dir", *promptsDir, "error", err)
+			os.Exit(1)
 		}
-		if apiKey == "" {
-			slog.Error("Gemini API key is required. Set --gemini-key flag or GEMINI_API_KEY environment variable")
+		slog.Info("Loaded prompt templates", "dir", *promptsDir)
+		scanOpts = append(scanOpts, scanning.WithPrompts(prompts))
+	}
+	newScanner := func(name string) scanning.Scanner {
+		switch name {
+		case "gemini":
+			// Get Gemini API key from flag or environment
+			apiKey := *geminiKey
+			if apiKey == "" {
+				apiKey = os.Getenv("GEMINI_API_KEY")
+			}
+			if apiKey == "" {
+				slog.Error("Gemini API key is required. Set --gemini-key flag or GEMINI_API_KEY environment variable")
+				os.Exit(1)
+			}
+			slog.Info("Initializing Gemini scanner...", "model", *geminiModel)
+			scanner, err := scanning.NewGemini(apiKey, *geminiModel, append(scanOpts, scanning.WithTimeout(geminiLimit.timeout))...)
+			if err != nil {
+				slog.Error("Failed to initialize Gemini", "error", err)
+				os.Exit(1)
+			}
+			return scanning.NewLimited(scanner, geminiLimit.limits())
+		case "ollama":
+			slog.Info("Initializing Ollama scanner...", "url", *ollamaURL, "model", *ollamaModel)
+			scanner, err := scanning.NewOllama(*ollamaURL, *ollamaModel, append(scanOpts, scanning.WithTimeout(ollamaLimit.timeout))...)
+			if err != nil {
+				slog.Error("Failed to initialize Ollama", "error", err)
+				os.Exit(1)
+			}
+			return scanning.NewLimited(scanner, ollamaLimit.limits())
+		case "openai":
+			apiKey := *openAIKey
+			if apiKey == "" {
+				apiKey = os.Getenv("OPENAI_API_KEY")
+			}
+			slog.Info("Initializing OpenAI-compatible scanner...", "url", *openAIURL, "model", *openAIModel)
+			scanner, err := scanning.NewOpenAI(*openAIURL, *openAIModel, apiKey, append(scanOpts, scanning.WithTimeout(openAILimit.timeout))...)
+			if err != nil {
+				slog.Error("Failed to initialize OpenAI-compatible scanner", "error", err)
+				os.Exit(1)
+			}
+			return scanning.NewLimited(scanner, openAILimit.limits())
+		case "tesseract":
+			slog.Info("Initializing Tesseract scanner...", "path", *tessPath, "lang", *tessLang)
+			scanner, err := scanning.NewTesseract(*tessPath, *tessLang, scanOpts...)
+			if err != nil {
+				slog.Error("Failed to initialize Tesseract", "error", err)
+				os.Exit(1)
+			}
+			return scanner
+		default:
+			slog.Error("Invalid scanner type", "type", name, "valid", "gemini, ollama, openai or tesseract")
 			os.Exit(1)
 		}
-		slog.Info("Initializing Gemini scanner...", "model", *geminiModel)
-		scanner, err = scanning.NewGemini(apiKey, *geminiModel)
-		if err != nil {
-			slog.Error("Failed to initialize Gemini", "error", err)
+		return nil
+	}
+
+	// Several scanners are tried in order, or checked against each other in consensus mode
+	names := strings.Split(*scannerType, ",")
+	scanners := make([]scanning.Scanner, len(names))
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		scanners[i] = newScanner(names[i])
+	}
+	switch {
+	case *scannerMode == "consensus":
+		if len(scanners) != 2 {
+			slog.Error("Consensus mode needs exactly two scanners", "scanner", *scannerType)
 			os.Exit(1)
 		}
-	case "ollama":
-		slog.Info("Initializing Ollama scanner...", "url", *ollamaURL, "model", *ollamaModel)
-		scanner, err = scanning.NewOllama(*ollamaURL, *ollamaModel)
+		slog.Info("Checking scanners against each other", "primary", names[0], "secondary", names[1])
+		scanner = scanning.NewConsensus(scanners[0], scanners[1])
+	case *scannerMode != "fallback":
+		slog.Error("Invalid scanner mode", "mode", *scannerMode, "valid", "fallback or consensus")
+		os.Exit(1)
+	case len(scanners) == 1:
+		scanner = scanners[0]
+	default:
+		slog.Info("Falling back between scanners", "order", names, "min_confidence", *fallbackConf)
+		scanner, err = scanning.NewFallback(*fallbackConf, scanners...)
 		if err != nil {
-			slog.Error("Failed to initialize Ollama", "error", err)
+			slog.Error("Failed to initialize scanner fallback", "error", err)
 			os.Exit(1)
 		}
-	default:
-		slog.Error("Invalid scanner type", "type", *scannerType, "valid", "gemini or ollama")
-		os.Exit(1)
 	}
 	defer scanner.Close()
 
@@ -110,6 +195,11 @@ func main() {
 	// Initialize service
 	receiptService := receipt.NewService(db, scanner, store)
 
+	// Delete abandoned drafts and uploads, and empty old trash, in the background
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go receiptService.RunSweeper(ctx, *sweepEvery, *draftMaxAge, *trashKeep)
+
 	// Initialize server
 	basicAuth := receipt.BasicAuth{
 		Username: *authUser,
@@ -138,3 +228,36 @@ func main() {
 
 	slog.Info("Shutting down...")
 }
+
+// scannerLimits holds the flags that retry and throttle calls to one scanner API
+type scannerLimits struct {
+	timeout     time.Duration
+	retries     int
+	maxBackoff  time.Duration
+	rpm         float64
+	burst       int
+	concurrency int
+}
+
+// providerLimits registers the timeout, retry, rate limit and concurrency flags for a scanner API
+func providerLimits(fs *ff.FlagSet, provider string, timeout time.Duration, concurrency int) *scannerLimits {
+	l := &scannerLimits{}
+	fs.DurationVar(&l.timeout, 0, provider+"-timeout", timeout, "Longest a single "+provider+" call may take; calls that time out aren't retried")
+	fs.IntVar(&l.retries, 0, provider+"-retries", scanning.DefaultMaxAttempts, "Tries per document when "+provider+" fails with a rate limit, server or network error, including the first")
+	fs.DurationVar(&l.maxBackoff, 0, provider+"-max-backoff", scanning.DefaultMaxDelay, "Longest wait between "+provider+" retries; when it asks to wait longer, the scan fails instead")
+	fs.Float64Var(&l.rpm, 0, provider+"-rpm", 0, "Most "+provider+" calls per minute (0 for no limit)")
+	fs.IntVar(&l.burst, 0, provider+"-burst", 1, "Most "+provider+" calls sent at once before the per-minute limit applies")
+	fs.IntVar(&l.concurrency, 0, provider+"-concurrency", concurrency, "Most "+provider+" calls in flight at once (0 for no limit)")
+	return l
+}
+
+// limits returns how calls to the scanner API are retried and throttled
+func (l *scannerLimits) limits() scanning.Limits {
+	return scanning.Limits{
+		MaxAttempts:       l.retries,
+		MaxDelay:          l.maxBackoff,
+		RequestsPerMinute: l.rpm,
+		Burst:             l.burst,
+		MaxConcurrent:     l.concurrency,
+	}
+}
@@ -12,6 +12,7 @@ require (
 	github.com/onsi/gomega v1.36.1
 	github.com/peterbourgon/ff/v4 v4.0.0-beta.1
 	go.etcd.io/bbolt v1.4.3
+	golang.org/x/time v0.14.0
 	google.golang.org/api v0.214.0
 )
 
@@ -47,7 +48,6 @@ require (
 	golang.org/x/sync v0.18.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
-	golang.org/x/time v0.14.0 // indirect
 	golang.org/x/tools v0.38.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20241209162323-e6fa225c2576 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20241209162323-e6fa225c2576 // indirect
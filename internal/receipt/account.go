@@ -0,0 +1,106 @@
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPredatesHSA is returned when an expense was incurred before the HSA paying for it was established
+var ErrPredatesHSA = errors.New("expense was incurred before the HSA was established")
+
+// AccountType identifies the kind of tax-advantaged benefit account
+type AccountType string
+
+// Supported benefit account types
+const (
+	AccountTypeHSA   AccountType = "hsa"   // Health Savings Account
+	AccountTypeFSA   AccountType = "fsa"   // Health Flexible Spending Account
+	AccountTypeLPFSA AccountType = "lpfsa" // Limited-Purpose FSA (dental and vision only)
+	AccountTypeHRA   AccountType = "hra"   // Health Reimbursement Arrangement
+)
+
+// eligibleCategories lists the expense categories each account type may reimburse
+var eligibleCategories = map[AccountType][]Category{
+	AccountTypeHSA: {
+		CategoryMedical, CategoryDental, CategoryVision, CategoryPharmacy, CategoryOTC,
+		CategoryMentalHealth, CategoryMileage, CategoryOther,
+	},
+	AccountTypeFSA: {
+		CategoryMedical, CategoryDental, CategoryVision, CategoryPharmacy, CategoryOTC,
+		CategoryMentalHealth, CategoryMileage, CategoryOther,
+	},
+	AccountTypeLPFSA: {
+		CategoryDental, CategoryVision,
+	},
+	AccountTypeHRA: {
+		CategoryMedical, CategoryDental, CategoryVision, CategoryPharmacy, CategoryOTC,
+		CategoryMentalHealth, CategoryMileage, CategoryPremiums, CategoryOther,
+	},
+}
+
+// Valid reports whether the account type is supported
+func (t AccountType) Valid() bool {
+	_, ok := eligibleCategories[t]
+	return ok
+}
+
+// EligibleCategories returns the expense categories the account type may reimburse
+func (t AccountType) EligibleCategories() []Category {
+	return eligibleCategories[t]
+}
+
+// Account represents a benefit account that reimbursements are drawn from
+type Account struct {
+	ID              string       `json:"id"`
+	Name            string       `json:"name"`
+	Type            AccountType  `json:"type"`
+	OwnerID         string       `json:"owner_id,omitempty"`        // Household member who holds the account
+	Coverage        CoverageType `json:"coverage,omitempty"`        // HDHP coverage tier; determines the HSA contribution limit
+	PlanYearStart   time.Time    `json:"plan_year_start,omitzero"`  // First day expenses may be incurred; zero for no limit
+	PlanYearEnd     time.Time    `json:"plan_year_end,omitzero"`    // Last day expenses may be incurred; zero for no limit
+	ClaimDeadline   time.Time    `json:"claim_deadline,omitzero"`   // Last day reimbursements may be requested; zero for no limit
+	EstablishedDate time.Time    `json:"established_date,omitzero"` // Date an HSA was established; earlier expenses are never eligible
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// CheckEligible returns an error if the receipt can't be reimbursed from the account
+func (a *Account) CheckEligible(receipt *Receipt) error {
+	category := receipt.Category
+	if category == "" {
+		category = CategoryOther
+	}
+
+	eligible := false
+	for _, c := range a.Type.EligibleCategories() {
+		if c == category {
+			eligible = true
+			break
+		}
+	}
+	if !eligible {
+		return fmt.Errorf("receipt %s: %s expenses are not eligible for %s accounts", receipt.ID, category, a.Type)
+	}
+
+	if err := a.checkEstablished(receipt); err != nil {
+		return err
+	}
+	if !a.PlanYearStart.IsZero() && receipt.Date.Before(a.PlanYearStart) {
+		return fmt.Errorf("receipt %s: incurred before the plan year of account %s", receipt.ID, a.Name)
+	}
+	if !a.PlanYearEnd.IsZero() && receipt.Date.After(a.PlanYearEnd) {
+		return fmt.Errorf("receipt %s: incurred after the plan year of account %s", receipt.ID, a.Name)
+	}
+
+	return nil
+}
+
+// checkEstablished returns ErrPredatesHSA if the receipt was incurred before the HSA was established
+func (a *Account) checkEstablished(receipt *Receipt) error {
+	if a.EstablishedDate.IsZero() || !receipt.Date.Before(a.EstablishedDate) {
+		return nil
+	}
+	return fmt.Errorf("%w: receipt %s was incurred on %s, before %s was established on %s",
+		ErrPredatesHSA, receipt.ID, receipt.Date.Format("2006-01-02"), a.Name, a.EstablishedDate.Format("2006-01-02"))
+}
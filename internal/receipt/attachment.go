@@ -0,0 +1,76 @@
+package receipt
+
+import (
+	"strings"
+	"time"
+)
+
+// AttachmentRole describes what a document attached to an expense is
+type AttachmentRole string
+
+// Supported attachment roles
+const (
+	AttachmentReceipt   AttachmentRole = "receipt"   // The receipt that was scanned
+	AttachmentBill      AttachmentRole = "bill"      // Provider bill or itemized statement
+	AttachmentEOB       AttachmentRole = "eob"       // Insurance explanation of benefits
+	AttachmentStatement AttachmentRole = "statement" // Card or bank statement showing payment
+	AttachmentOther     AttachmentRole = "other"
+)
+
+// Valid reports whether the attachment role is supported
+func (r AttachmentRole) Valid() bool {
+	switch r {
+	case AttachmentReceipt, AttachmentBill, AttachmentEOB, AttachmentStatement, AttachmentOther:
+		return true
+	}
+	return false
+}
+
+// Attachment is a document stored for an expense
+type Attachment struct {
+	ID          string         `json:"id"`
+	Role        AttachmentRole `json:"role"`
+	Name        string         `json:"name"`     // Original file name, for display
+	Filename    string         `json:"filename"` // Path of the file in Storage
+	ContentType string         `json:"content_type"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// primaryAttachment describes a receipt's scanned file as an attachment
+// It shares the receipt's ID so it can be recognized and isn't removed on its own.
+func (r *Receipt) primaryAttachment() Attachment {
+	return Attachment{
+		ID:          r.ID,
+		Role:        AttachmentReceipt,
+		Name:        strings.TrimPrefix(r.Filename, r.ID+"_"),
+		Filename:    r.Filename,
+		ContentType: r.ContentType,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+// Attachment returns the receipt's attachment with the given ID
+func (r *Receipt) Attachment(id string) (*Attachment, bool) {
+	for i := range r.Attachments {
+		if r.Attachments[i].ID == id {
+			return &r.Attachments[i], true
+		}
+	}
+	return nil, false
+}
+
+// storedFiles returns the paths of every file kept in Storage for the receipt
+func (r *Receipt) storedFiles() []string {
+	if len(r.Attachments) == 0 {
+		if r.Filename == "" {
+			return nil
+		}
+		return []string{r.Filename}
+	}
+
+	files := make([]string, 0, len(r.Attachments))
+	for _, attachment := range r.Attachments {
+		files = append(files, attachment.Filename)
+	}
+	return files
+}
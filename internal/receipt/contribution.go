@@ -0,0 +1,155 @@
+package receipt
+
+import (
+	"fmt"
+	"time"
+)
+
+// ContributionSource identifies who paid money into an HSA
+type ContributionSource string
+
+// Supported contribution sources
+const (
+	ContributionEmployee   ContributionSource = "employee"   // Pre-tax payroll deduction
+	ContributionEmployer   ContributionSource = "employer"   // Employer contribution
+	ContributionIndividual ContributionSource = "individual" // After-tax deposit made directly to the custodian
+)
+
+// Valid reports whether the contribution source is supported
+func (s ContributionSource) Valid() bool {
+	switch s {
+	case ContributionEmployee, ContributionEmployer, ContributionIndividual:
+		return true
+	}
+	return false
+}
+
+// CoverageType is the HDHP coverage tier that determines the annual HSA limit
+type CoverageType string
+
+// Supported coverage tiers
+const (
+	CoverageSelfOnly CoverageType = "self_only"
+	CoverageFamily   CoverageType = "family"
+)
+
+// Valid reports whether the coverage type is supported
+func (c CoverageType) Valid() bool {
+	return c == CoverageSelfOnly || c == CoverageFamily
+}
+
+// ContributionLimit holds the IRS annual HSA contribution limits for a tax year in cents
+type ContributionLimit struct {
+	SelfOnly int
+	Family   int
+}
+
+// CatchUpContribution is the additional amount in cents account holders aged 55 or older may contribute
+const CatchUpContribution = 100000
+
+// CatchUpAge is the age by the end of the tax year at which catch-up contributions are allowed
+const CatchUpAge = 55
+
+// contributionLimits lists the IRS annual HSA contribution limits by tax year
+var contributionLimits = map[int]ContributionLimit{
+	2019: {SelfOnly: 350000, Family: 700000},
+	2020: {SelfOnly: 355000, Family: 710000},
+	2021: {SelfOnly: 360000, Family: 720000},
+	2022: {SelfOnly: 365000, Family: 730000},
+	2023: {SelfOnly: 385000, Family: 775000},
+	2024: {SelfOnly: 415000, Family: 830000},
+	2025: {SelfOnly: 430000, Family: 855000},
+	2026: {SelfOnly: 440000, Family: 875000},
+}
+
+// AnnualLimit returns the contribution limit in cents for a tax year and coverage tier
+// The second return value is false if the limit for the year is unknown.
+func AnnualLimit(taxYear int, coverage CoverageType, catchUp bool) (int, bool) {
+	limits, ok := contributionLimits[taxYear]
+	if !ok || !coverage.Valid() {
+		return 0, false
+	}
+
+	limit := limits.SelfOnly
+	if coverage == CoverageFamily {
+		limit = limits.Family
+	}
+	if catchUp {
+		limit += CatchUpContribution
+	}
+	return limit, true
+}
+
+// CatchUpEligible reports whether someone born on birthDate may make catch-up contributions for the tax year
+func CatchUpEligible(birthDate time.Time, taxYear int) bool {
+	if birthDate.IsZero() {
+		return false
+	}
+	endOfYear := time.Date(taxYear, time.December, 31, 0, 0, 0, 0, time.UTC)
+	return !birthDate.AddDate(CatchUpAge, 0, 0).After(endOfYear)
+}
+
+// Contribution represents money paid into an HSA
+type Contribution struct {
+	ID        string             `json:"id"`
+	AccountID string             `json:"account_id"`
+	Source    ContributionSource `json:"source"`
+	Amount    int                `json:"amount"`   // Amount in cents
+	Date      time.Time          `json:"date"`     // Date the money was deposited
+	TaxYear   int                `json:"tax_year"` // Tax year the contribution counts toward
+	Notes     string             `json:"notes,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// ContributionSummary totals an account's contributions for a tax year and compares them to the IRS limit
+type ContributionSummary struct {
+	TaxYear         int          `json:"tax_year"`
+	AccountID       string       `json:"account_id"`
+	AccountName     string       `json:"account_name"`
+	Coverage        CoverageType `json:"coverage,omitempty"`
+	CatchUpEligible bool         `json:"catch_up_eligible"`
+	Employee        int          `json:"employee"`   // Employee contributions in cents
+	Employer        int          `json:"employer"`   // Employer contributions in cents
+	Individual      int          `json:"individual"` // Individual contributions in cents
+	TotalAmount     int          `json:"total_amount"`
+	Limit           int          `json:"limit"`     // IRS limit in cents; zero if unknown
+	Remaining       int          `json:"remaining"` // Room left under the limit in cents
+	OverLimit       bool         `json:"over_limit"`
+	Warning         string       `json:"warning,omitempty"`
+}
+
+// add records a contribution in the summary totals
+func (cs *ContributionSummary) add(contribution *Contribution) {
+	switch contribution.Source {
+	case ContributionEmployee:
+		cs.Employee += contribution.Amount
+	case ContributionEmployer:
+		cs.Employer += contribution.Amount
+	case ContributionIndividual:
+		cs.Individual += contribution.Amount
+	}
+	cs.TotalAmount += contribution.Amount
+}
+
+// checkLimit compares the summary total to the IRS limit and sets the warning
+func (cs *ContributionSummary) checkLimit() {
+	if !cs.Coverage.Valid() {
+		cs.Warning = fmt.Sprintf("coverage is not set for account %s; the IRS limit can't be checked", cs.AccountName)
+		return
+	}
+
+	limit, ok := AnnualLimit(cs.TaxYear, cs.Coverage, cs.CatchUpEligible)
+	if !ok {
+		cs.Warning = fmt.Sprintf("the IRS limit for %d is not known", cs.TaxYear)
+		return
+	}
+
+	cs.Limit = limit
+	cs.Remaining = limit - cs.TotalAmount
+	if cs.Remaining < 0 {
+		cs.OverLimit = true
+		cs.Warning = fmt.Sprintf("contributions exceed the %d limit by %d cents", cs.TaxYear, -cs.Remaining)
+		cs.Remaining = 0
+	}
+}
@@ -1,7 +1,9 @@
 package receipt
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,10 +11,18 @@ import (
 )
 
 const (
-	bucketName         = "receipts"
+	bucketName              = "receipts"
 	reimbursementBucketName = "reimbursements"
+	memberBucketName        = "members"
+	accountBucketName       = "accounts"
+	contributionBucketName  = "contributions"
+	eobBucketName           = "eobs"
+	historyBucketName       = "receipt_history"
 )
 
+// ErrNotFound is returned when a record doesn't exist
+var ErrNotFound = errors.New("not found")
+
 // DB defines the interface for database operations
 type DB interface {
 	// SaveReceipt saves a receipt to the database
@@ -36,6 +46,66 @@ type DB interface {
 	// ListReimbursements returns all reimbursements
 	ListReimbursements() ([]*Reimbursement, error)
 
+	// SaveMember saves a household member to the database
+	SaveMember(member *Member) error
+
+	// GetMember retrieves a household member by ID
+	GetMember(id string) (*Member, error)
+
+	// ListMembers returns all household members
+	ListMembers() ([]*Member, error)
+
+	// DeleteMember removes a household member from the database
+	DeleteMember(id string) error
+
+	// SaveAccount saves a benefit account to the database
+	SaveAccount(account *Account) error
+
+	// GetAccount retrieves a benefit account by ID
+	GetAccount(id string) (*Account, error)
+
+	// ListAccounts returns all benefit accounts
+	ListAccounts() ([]*Account, error)
+
+	// DeleteAccount removes a benefit account from the database
+	DeleteAccount(id string) error
+
+	// SaveContribution saves an HSA contribution to the database
+	SaveContribution(contribution *Contribution) error
+
+	// GetContribution retrieves an HSA contribution by ID
+	GetContribution(id string) (*Contribution, error)
+
+	// ListContributions returns all HSA contributions
+	ListContributions() ([]*Contribution, error)
+
+	// DeleteContribution removes an HSA contribution from the database
+	DeleteContribution(id string) error
+
+	// SaveEOB saves an explanation of benefits to the database
+	SaveEOB(eob *EOB) error
+
+	// GetEOB retrieves an explanation of benefits by ID
+	GetEOB(id string) (*EOB, error)
+
+	// ListEOBs returns all explanations of benefits
+	ListEOBs() ([]*EOB, error)
+
+	// DeleteEOB removes an explanation of benefits from the database
+	DeleteEOB(id string) error
+
+	// AppendReceiptEvent adds an entry to a receipt's change history
+	// History is append-only; entries are never changed or removed.
+	AppendReceiptEvent(event *ReceiptEvent) error
+
+	// ListReceiptHistory returns a receipt's change history, oldest first
+	ListReceiptHistory(receiptID string) ([]*ReceiptEvent, error)
+
+	// Transaction runs fn as a single unit of work
+	// Every change fn makes through tx is saved together, or not at all if fn returns an error.
+	// Transactions don't nest; calling Transaction on tx runs fn in the same transaction.
+	Transaction(fn func(tx DB) error) error
+
 	// Close closes the database connection
 	Close() error
 }
@@ -43,6 +113,7 @@ type DB interface {
 // BoltDB implements the DB interface using BoltDB
 type BoltDB struct {
 	db *bbolt.DB
+	tx *bbolt.Tx // Set while running inside Transaction
 }
 
 // NewBoltDB creates a new BoltDB instance
@@ -60,6 +131,21 @@ func NewBoltDB(path string) (*BoltDB, error) {
 		if _, err := tx.CreateBucketIfNotExists([]byte(reimbursementBucketName)); err != nil {
 			return err
 		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(memberBucketName)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(accountBucketName)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(contributionBucketName)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(eobBucketName)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(historyBucketName)); err != nil {
+			return err
+		}
 		return nil
 	})
 	if err != nil {
@@ -67,12 +153,180 @@ func NewBoltDB(path string) (*BoltDB, error) {
 		return nil, fmt.Errorf("creating buckets: %w", err)
 	}
 
+	if err := db.Update(migrateAllocations); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating reimbursement allocations: %w", err)
+	}
+	if err := db.Update(migrateAttachments); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating receipt attachments: %w", err)
+	}
+	if err := db.Update(migrateReimbursementStatus); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating reimbursement status: %w", err)
+	}
+
 	return &BoltDB{db: db}, nil
 }
 
+// legacyReceipt reads receipts saved before partial reimbursements, which
+// linked a receipt to a single reimbursement by ID
+type legacyReceipt struct {
+	Receipt
+	ReimbursementID string `json:"reimbursement_id,omitempty"`
+}
+
+// migrateAllocations converts receipts and reimbursements from the single
+// reimbursement ID model into allocations covering each receipt's full amount
+func migrateAllocations(tx *bbolt.Tx) error {
+	receipts := tx.Bucket([]byte(bucketName))
+	reimbursements := tx.Bucket([]byte(reimbursementBucketName))
+
+	// Collect updates first; bbolt doesn't allow modifying a bucket while iterating it
+	updates := make(map[string][]byte)
+	err := receipts.ForEach(func(k, v []byte) error {
+		var legacy legacyReceipt
+		if err := json.Unmarshal(v, &legacy); err != nil {
+			return fmt.Errorf("unmarshaling receipt: %w", err)
+		}
+		if legacy.ReimbursementID == "" || len(legacy.Allocations) > 0 {
+			return nil
+		}
+		receipt := legacy.Receipt
+		receipt.Allocations = []Allocation{{
+			ReimbursementID: legacy.ReimbursementID,
+			ReceiptID:       receipt.ID,
+			Amount:          receipt.ReimbursableAmount(),
+		}}
+		data, err := json.Marshal(&receipt)
+		if err != nil {
+			return fmt.Errorf("marshaling receipt: %w", err)
+		}
+		updates[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, data := range updates {
+		if err := receipts.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+
+	updates = make(map[string][]byte)
+	err = reimbursements.ForEach(func(k, v []byte) error {
+		var reimbursement Reimbursement
+		if err := json.Unmarshal(v, &reimbursement); err != nil {
+			return fmt.Errorf("unmarshaling reimbursement: %w", err)
+		}
+		if len(reimbursement.Allocations) > 0 || len(reimbursement.ReceiptIDs) == 0 {
+			return nil
+		}
+		for _, receiptID := range reimbursement.ReceiptIDs {
+			var receipt Receipt
+			if data := receipts.Get([]byte(receiptID)); data != nil {
+				if err := json.Unmarshal(data, &receipt); err != nil {
+					return fmt.Errorf("unmarshaling receipt: %w", err)
+				}
+			}
+			reimbursement.Allocations = append(reimbursement.Allocations, Allocation{
+				ReimbursementID: reimbursement.ID,
+				ReceiptID:       receiptID,
+				Amount:          receipt.ReimbursableAmount(),
+			})
+		}
+		data, err := json.Marshal(&reimbursement)
+		if err != nil {
+			return fmt.Errorf("marshaling reimbursement: %w", err)
+		}
+		updates[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, data := range updates {
+		if err := reimbursements.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateAttachments lists the single file of receipts saved before
+// attachments were supported as their first attachment
+func migrateAttachments(tx *bbolt.Tx) error {
+	receipts := tx.Bucket([]byte(bucketName))
+
+	// Collect updates first; bbolt doesn't allow modifying a bucket while iterating it
+	updates := make(map[string][]byte)
+	err := receipts.ForEach(func(k, v []byte) error {
+		var receipt Receipt
+		if err := json.Unmarshal(v, &receipt); err != nil {
+			return fmt.Errorf("unmarshaling receipt: %w", err)
+		}
+		if receipt.Filename == "" || len(receipt.Attachments) > 0 {
+			return nil
+		}
+		receipt.Attachments = []Attachment{receipt.primaryAttachment()}
+		data, err := json.Marshal(&receipt)
+		if err != nil {
+			return fmt.Errorf("marshaling receipt: %w", err)
+		}
+		updates[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, data := range updates {
+		if err := receipts.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateReimbursementStatus marks reimbursements recorded before the
+// reimbursement lifecycle as paid, which is what recording one used to mean
+func migrateReimbursementStatus(tx *bbolt.Tx) error {
+	reimbursements := tx.Bucket([]byte(reimbursementBucketName))
+
+	// Collect updates first; bbolt doesn't allow modifying a bucket while iterating it
+	updates := make(map[string][]byte)
+	err := reimbursements.ForEach(func(k, v []byte) error {
+		var reimbursement Reimbursement
+		if err := json.Unmarshal(v, &reimbursement); err != nil {
+			return fmt.Errorf("unmarshaling reimbursement: %w", err)
+		}
+		if reimbursement.Status != "" {
+			return nil
+		}
+		reimbursement.Status = ReimbursementPaid
+		reimbursement.History = []StatusChange{{To: ReimbursementPaid, At: reimbursement.CreatedAt}}
+		data, err := json.Marshal(&reimbursement)
+		if err != nil {
+			return fmt.Errorf("marshaling reimbursement: %w", err)
+		}
+		updates[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, data := range updates {
+		if err := reimbursements.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SaveReceipt saves a receipt to the database
 func (b *BoltDB) SaveReceipt(receipt *Receipt) error {
-	return b.db.Update(func(tx *bbolt.Tx) error {
+	return b.update(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 		data, err := json.Marshal(receipt)
 		if err != nil {
@@ -85,11 +339,11 @@ func (b *BoltDB) SaveReceipt(receipt *Receipt) error {
 // GetReceipt retrieves a receipt by ID
 func (b *BoltDB) GetReceipt(id string) (*Receipt, error) {
 	var receipt *Receipt
-	err := b.db.View(func(tx *bbolt.Tx) error {
+	err := b.view(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 		data := bucket.Get([]byte(id))
 		if data == nil {
-			return fmt.Errorf("receipt not found: %s", id)
+			return fmt.Errorf("receipt %w: %s", ErrNotFound, id)
 		}
 		return json.Unmarshal(data, &receipt)
 	})
@@ -102,7 +356,7 @@ func (b *BoltDB) GetReceipt(id string) (*Receipt, error) {
 // ListReceipts returns all receipts
 func (b *BoltDB) ListReceipts() ([]*Receipt, error) {
 	receipts := make([]*Receipt, 0)
-	err := b.db.View(func(tx *bbolt.Tx) error {
+	err := b.view(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 		return bucket.ForEach(func(k, v []byte) error {
 			var receipt Receipt
@@ -121,7 +375,7 @@ func (b *BoltDB) ListReceipts() ([]*Receipt, error) {
 
 // DeleteReceipt removes a receipt from the database
 func (b *BoltDB) DeleteReceipt(id string) error {
-	return b.db.Update(func(tx *bbolt.Tx) error {
+	return b.update(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 		return bucket.Delete([]byte(id))
 	})
@@ -129,7 +383,7 @@ func (b *BoltDB) DeleteReceipt(id string) error {
 
 // SaveReimbursement saves a reimbursement to the database
 func (b *BoltDB) SaveReimbursement(reimbursement *Reimbursement) error {
-	return b.db.Update(func(tx *bbolt.Tx) error {
+	return b.update(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(reimbursementBucketName))
 		data, err := json.Marshal(reimbursement)
 		if err != nil {
@@ -142,11 +396,11 @@ func (b *BoltDB) SaveReimbursement(reimbursement *Reimbursement) error {
 // GetReimbursement retrieves a reimbursement by ID
 func (b *BoltDB) GetReimbursement(id string) (*Reimbursement, error) {
 	var reimbursement *Reimbursement
-	err := b.db.View(func(tx *bbolt.Tx) error {
+	err := b.view(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(reimbursementBucketName))
 		data := bucket.Get([]byte(id))
 		if data == nil {
-			return fmt.Errorf("reimbursement not found: %s", id)
+			return fmt.Errorf("reimbursement %w: %s", ErrNotFound, id)
 		}
 		return json.Unmarshal(data, &reimbursement)
 	})
@@ -159,7 +413,7 @@ func (b *BoltDB) GetReimbursement(id string) (*Reimbursement, error) {
 // ListReimbursements returns all reimbursements
 func (b *BoltDB) ListReimbursements() ([]*Reimbursement, error) {
 	reimbursements := make([]*Reimbursement, 0)
-	err := b.db.View(func(tx *bbolt.Tx) error {
+	err := b.view(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(reimbursementBucketName))
 		return bucket.ForEach(func(k, v []byte) error {
 			var reimbursement Reimbursement
@@ -176,6 +430,304 @@ func (b *BoltDB) ListReimbursements() ([]*Reimbursement, error) {
 	return reimbursements, nil
 }
 
+// SaveMember saves a household member to the database
+func (b *BoltDB) SaveMember(member *Member) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(memberBucketName))
+		data, err := json.Marshal(member)
+		if err != nil {
+			return fmt.Errorf("marshaling member: %w", err)
+		}
+		return bucket.Put([]byte(member.ID), data)
+	})
+}
+
+// GetMember retrieves a household member by ID
+func (b *BoltDB) GetMember(id string) (*Member, error) {
+	var member *Member
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(memberBucketName))
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("member %w: %s", ErrNotFound, id)
+		}
+		return json.Unmarshal(data, &member)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// ListMembers returns all household members
+func (b *BoltDB) ListMembers() ([]*Member, error) {
+	members := make([]*Member, 0)
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(memberBucketName))
+		return bucket.ForEach(func(k, v []byte) error {
+			var member Member
+			if err := json.Unmarshal(v, &member); err != nil {
+				return fmt.Errorf("unmarshaling member: %w", err)
+			}
+			members = append(members, &member)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// DeleteMember removes a household member from the database
+func (b *BoltDB) DeleteMember(id string) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(memberBucketName))
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// SaveAccount saves a benefit account to the database
+func (b *BoltDB) SaveAccount(account *Account) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(accountBucketName))
+		data, err := json.Marshal(account)
+		if err != nil {
+			return fmt.Errorf("marshaling account: %w", err)
+		}
+		return bucket.Put([]byte(account.ID), data)
+	})
+}
+
+// GetAccount retrieves a benefit account by ID
+func (b *BoltDB) GetAccount(id string) (*Account, error) {
+	var account *Account
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(accountBucketName))
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("account %w: %s", ErrNotFound, id)
+		}
+		return json.Unmarshal(data, &account)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// ListAccounts returns all benefit accounts
+func (b *BoltDB) ListAccounts() ([]*Account, error) {
+	accounts := make([]*Account, 0)
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(accountBucketName))
+		return bucket.ForEach(func(k, v []byte) error {
+			var account Account
+			if err := json.Unmarshal(v, &account); err != nil {
+				return fmt.Errorf("unmarshaling account: %w", err)
+			}
+			accounts = append(accounts, &account)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// DeleteAccount removes a benefit account from the database
+func (b *BoltDB) DeleteAccount(id string) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(accountBucketName))
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// SaveContribution saves an HSA contribution to the database
+func (b *BoltDB) SaveContribution(contribution *Contribution) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(contributionBucketName))
+		data, err := json.Marshal(contribution)
+		if err != nil {
+			return fmt.Errorf("marshaling contribution: %w", err)
+		}
+		return bucket.Put([]byte(contribution.ID), data)
+	})
+}
+
+// GetContribution retrieves an HSA contribution by ID
+func (b *BoltDB) GetContribution(id string) (*Contribution, error) {
+	var contribution *Contribution
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(contributionBucketName))
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("contribution %w: %s", ErrNotFound, id)
+		}
+		return json.Unmarshal(data, &contribution)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return contribution, nil
+}
+
+// ListContributions returns all HSA contributions
+func (b *BoltDB) ListContributions() ([]*Contribution, error) {
+	contributions := make([]*Contribution, 0)
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(contributionBucketName))
+		return bucket.ForEach(func(k, v []byte) error {
+			var contribution Contribution
+			if err := json.Unmarshal(v, &contribution); err != nil {
+				return fmt.Errorf("unmarshaling contribution: %w", err)
+			}
+			contributions = append(contributions, &contribution)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return contributions, nil
+}
+
+// DeleteContribution removes an HSA contribution from the database
+func (b *BoltDB) DeleteContribution(id string) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(contributionBucketName))
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// SaveEOB saves an explanation of benefits to the database
+func (b *BoltDB) SaveEOB(eob *EOB) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(eobBucketName))
+		data, err := json.Marshal(eob)
+		if err != nil {
+			return fmt.Errorf("marshaling eob: %w", err)
+		}
+		return bucket.Put([]byte(eob.ID), data)
+	})
+}
+
+// GetEOB retrieves an explanation of benefits by ID
+func (b *BoltDB) GetEOB(id string) (*EOB, error) {
+	var eob *EOB
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(eobBucketName))
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("eob %w: %s", ErrNotFound, id)
+		}
+		return json.Unmarshal(data, &eob)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eob, nil
+}
+
+// ListEOBs returns all explanations of benefits
+func (b *BoltDB) ListEOBs() ([]*EOB, error) {
+	eobs := make([]*EOB, 0)
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(eobBucketName))
+		return bucket.ForEach(func(k, v []byte) error {
+			var eob EOB
+			if err := json.Unmarshal(v, &eob); err != nil {
+				return fmt.Errorf("unmarshaling eob: %w", err)
+			}
+			eobs = append(eobs, &eob)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eobs, nil
+}
+
+// DeleteEOB removes an explanation of benefits from the database
+func (b *BoltDB) DeleteEOB(id string) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(eobBucketName))
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// AppendReceiptEvent adds an entry to a receipt's change history
+// Each receipt's history is a nested bucket keyed by sequence number, so entries stay in order.
+func (b *BoltDB) AppendReceiptEvent(event *ReceiptEvent) error {
+	return b.update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.Bucket([]byte(historyBucketName)).CreateBucketIfNotExists([]byte(event.ReceiptID))
+		if err != nil {
+			return fmt.Errorf("creating history bucket: %w", err)
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return fmt.Errorf("getting history sequence: %w", err)
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("marshaling receipt event: %w", err)
+		}
+		return bucket.Put(binary.BigEndian.AppendUint64(nil, seq), data)
+	})
+}
+
+// ListReceiptHistory returns a receipt's change history, oldest first
+func (b *BoltDB) ListReceiptHistory(receiptID string) ([]*ReceiptEvent, error) {
+	events := make([]*ReceiptEvent, 0)
+	err := b.view(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(historyBucketName)).Bucket([]byte(receiptID))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var event ReceiptEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return fmt.Errorf("unmarshaling receipt event: %w", err)
+			}
+			events = append(events, &event)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Transaction runs fn as a single unit of work in one bbolt transaction
+func (b *BoltDB) Transaction(fn func(tx DB) error) error {
+	if b.tx != nil {
+		return fn(b)
+	}
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return fn(&BoltDB{db: b.db, tx: tx})
+	})
+}
+
+// update runs fn in a read-write transaction, reusing the current transaction if there is one
+func (b *BoltDB) update(fn func(tx *bbolt.Tx) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+	return b.db.Update(fn)
+}
+
+// view runs fn in a read-only transaction, reusing the current transaction if there is one
+// bbolt can deadlock when a goroutine holding a write transaction opens a read transaction.
+func (b *BoltDB) view(fn func(tx *bbolt.Tx) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+	return b.db.View(fn)
+}
+
 // Close closes the database connection
 func (b *BoltDB) Close() error {
 	return b.db.Close()
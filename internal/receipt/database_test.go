@@ -7,6 +7,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"go.etcd.io/bbolt"
 )
 
 var _ = Describe("BoltDB", func() {
@@ -119,7 +120,8 @@ var _ = Describe("BoltDB", func() {
 			})
 
 			It("returns the error", func() {
-				Expect(err).To(MatchError(expectedErr))
+				Expect(err).To(MatchError(expectedErr.Error()))
+				Expect(err).To(MatchError(ErrNotFound))
 			})
 		})
 	})
@@ -301,7 +303,8 @@ var _ = Describe("BoltDB", func() {
 			})
 
 			It("returns the error", func() {
-				Expect(err).To(MatchError(expectedErr))
+				Expect(err).To(MatchError(expectedErr.Error()))
+				Expect(err).To(MatchError(ErrNotFound))
 			})
 		})
 	})
@@ -355,4 +358,328 @@ var _ = Describe("BoltDB", func() {
 			})
 		})
 	})
+
+	Describe("Members", func() {
+		BeforeEach(func() {
+			member := &Member{
+				ID:           "member-1",
+				Name:         "Alex",
+				Relationship: "dependent",
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+			}
+			Expect(db.SaveMember(member)).NotTo(HaveOccurred())
+		})
+
+		When("getting a saved member", func() {
+			It("should return the member", func() {
+				member, err := db.GetMember("member-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(member.Name).To(Equal("Alex"))
+			})
+		})
+
+		When("getting a missing member", func() {
+			It("returns the error", func() {
+				_, err := db.GetMember("nonexistent")
+				Expect(err).To(MatchError("member not found: nonexistent"))
+			})
+		})
+
+		When("listing members", func() {
+			It("should return all members", func() {
+				members, err := db.ListMembers()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(members).To(HaveLen(1))
+			})
+		})
+
+		When("deleting a member", func() {
+			It("should remove the member from the database", func() {
+				Expect(db.DeleteMember("member-1")).NotTo(HaveOccurred())
+				_, err := db.GetMember("member-1")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Accounts", func() {
+		BeforeEach(func() {
+			account := &Account{
+				ID:        "account-1",
+				Name:      "Family HSA",
+				Type:      AccountTypeHSA,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}
+			Expect(db.SaveAccount(account)).NotTo(HaveOccurred())
+		})
+
+		When("getting a saved account", func() {
+			It("should return the account", func() {
+				account, err := db.GetAccount("account-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(account.Type).To(Equal(AccountTypeHSA))
+			})
+		})
+
+		When("getting a missing account", func() {
+			It("returns the error", func() {
+				_, err := db.GetAccount("nonexistent")
+				Expect(err).To(MatchError("account not found: nonexistent"))
+			})
+		})
+
+		When("deleting an account", func() {
+			It("should remove the account from the database", func() {
+				Expect(db.DeleteAccount("account-1")).NotTo(HaveOccurred())
+				accounts, err := db.ListAccounts()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(accounts).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Contributions", func() {
+		BeforeEach(func() {
+			contribution := &Contribution{
+				ID:        "contribution-1",
+				AccountID: "account-1",
+				Source:    ContributionEmployer,
+				Amount:    50000,
+				Date:      time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+				TaxYear:   2024,
+			}
+			Expect(db.SaveContribution(contribution)).NotTo(HaveOccurred())
+		})
+
+		When("getting a saved contribution", func() {
+			It("should return the contribution", func() {
+				contribution, err := db.GetContribution("contribution-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(contribution.Amount).To(Equal(50000))
+			})
+		})
+
+		When("getting a missing contribution", func() {
+			It("returns the error", func() {
+				_, err := db.GetContribution("nonexistent")
+				Expect(err).To(MatchError("contribution not found: nonexistent"))
+			})
+		})
+
+		When("deleting a contribution", func() {
+			It("should remove the contribution from the database", func() {
+				Expect(db.DeleteContribution("contribution-1")).NotTo(HaveOccurred())
+				contributions, err := db.ListContributions()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(contributions).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("EOBs", func() {
+		BeforeEach(func() {
+			eob := &EOB{
+				ID:                    "eob-1",
+				Provider:              "Riverside Orthopedics",
+				ServiceDate:           time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+				PatientResponsibility: 6000,
+			}
+			Expect(db.SaveEOB(eob)).NotTo(HaveOccurred())
+		})
+
+		When("getting a saved eob", func() {
+			It("should return the eob", func() {
+				eob, err := db.GetEOB("eob-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(eob.PatientResponsibility).To(Equal(6000))
+			})
+		})
+
+		When("getting a missing eob", func() {
+			It("returns the error", func() {
+				_, err := db.GetEOB("nonexistent")
+				Expect(err).To(MatchError("eob not found: nonexistent"))
+			})
+		})
+
+		When("deleting an eob", func() {
+			It("should remove the eob from the database", func() {
+				Expect(db.DeleteEOB("eob-1")).NotTo(HaveOccurred())
+				eobs, err := db.ListEOBs()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(eobs).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Receipt history", func() {
+		BeforeEach(func() {
+			for _, action := range []ReceiptAction{ActionScanned, ActionConfirmed, ActionReimbursed} {
+				event := &ReceiptEvent{ReceiptID: "receipt-1", Action: action, After: &Receipt{ID: "receipt-1"}}
+				Expect(db.AppendReceiptEvent(event)).NotTo(HaveOccurred())
+			}
+			Expect(db.AppendReceiptEvent(&ReceiptEvent{ReceiptID: "receipt-2", Action: ActionCreated})).NotTo(HaveOccurred())
+		})
+
+		When("listing a receipt's history", func() {
+			It("should return its events in the order they were recorded", func() {
+				events, err := db.ListReceiptHistory("receipt-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(HaveLen(3))
+				Expect(events[0].Action).To(Equal(ActionScanned))
+				Expect(events[1].Action).To(Equal(ActionConfirmed))
+				Expect(events[2].Action).To(Equal(ActionReimbursed))
+			})
+		})
+
+		When("the receipt has no history", func() {
+			It("should return an empty list", func() {
+				events, err := db.ListReceiptHistory("nonexistent")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(BeEmpty())
+			})
+		})
+
+		When("the receipt is deleted", func() {
+			It("should keep its history", func() {
+				Expect(db.DeleteReceipt("receipt-1")).NotTo(HaveOccurred())
+				events, err := db.ListReceiptHistory("receipt-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(HaveLen(3))
+			})
+		})
+	})
+
+	Describe("Transaction", func() {
+		var err error
+
+		JustBeforeEach(func() {
+			err = db.Transaction(func(tx DB) error {
+				if err := tx.SaveReimbursement(&Reimbursement{ID: "reimb-1", TotalAmount: 1000}); err != nil {
+					return err
+				}
+				receipt, err := tx.GetReceipt("receipt-1")
+				if err != nil {
+					return err
+				}
+				receipt.Allocations = []Allocation{{ReimbursementID: "reimb-1", ReceiptID: "receipt-1", Amount: 1000}}
+				return tx.SaveReceipt(receipt)
+			})
+		})
+
+		When("every step succeeds", func() {
+			BeforeEach(func() {
+				Expect(db.SaveReceipt(&Receipt{ID: "receipt-1", Amount: 1000})).To(Succeed())
+			})
+
+			It("should save every change", func() {
+				Expect(err).NotTo(HaveOccurred())
+				receipt, err := db.GetReceipt("receipt-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(receipt.Allocations).To(HaveLen(1))
+			})
+		})
+
+		When("a step fails", func() {
+			It("returns the error", func() {
+				Expect(err).To(MatchError("receipt not found: receipt-1"))
+			})
+
+			It("should roll back the earlier changes", func() {
+				_, err := db.GetReimbursement("reimb-1")
+				Expect(err).To(MatchError("reimbursement not found: reimb-1"))
+			})
+		})
+	})
+
+	Describe("migrating reimbursement allocations", func() {
+		BeforeEach(func() {
+			Expect(db.Close()).To(Succeed())
+
+			raw, err := bbolt.Open(dbPath, 0600, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(raw.Update(func(tx *bbolt.Tx) error {
+				if err := tx.Bucket([]byte(bucketName)).Put([]byte("receipt-1"),
+					[]byte(`{"id":"receipt-1","amount":2500,"reimbursement_id":"reimb-1"}`)); err != nil {
+					return err
+				}
+				return tx.Bucket([]byte(reimbursementBucketName)).Put([]byte("reimb-1"),
+					[]byte(`{"id":"reimb-1","receipt_ids":["receipt-1"],"total_amount":2500}`))
+			})).To(Succeed())
+			Expect(raw.Close()).To(Succeed())
+
+			db, err = NewBoltDB(dbPath)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should convert the receipt's reimbursement ID into an allocation", func() {
+			receipt, err := db.GetReceipt("receipt-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Allocations).To(Equal([]Allocation{
+				{ReimbursementID: "reimb-1", ReceiptID: "receipt-1", Amount: 2500},
+			}))
+		})
+
+		It("should add allocations to the reimbursement", func() {
+			reimbursement, err := db.GetReimbursement("reimb-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reimbursement.Allocations).To(Equal([]Allocation{
+				{ReimbursementID: "reimb-1", ReceiptID: "receipt-1", Amount: 2500},
+			}))
+		})
+	})
+
+	Describe("migrating receipt attachments", func() {
+		BeforeEach(func() {
+			Expect(db.Close()).To(Succeed())
+
+			raw, err := bbolt.Open(dbPath, 0600, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(raw.Update(func(tx *bbolt.Tx) error {
+				return tx.Bucket([]byte(bucketName)).Put([]byte("receipt-1"),
+					[]byte(`{"id":"receipt-1","filename":"receipt-1_pharmacy.jpg","content_type":"image/jpeg"}`))
+			})).To(Succeed())
+			Expect(raw.Close()).To(Succeed())
+
+			db, err = NewBoltDB(dbPath)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should list the receipt's file as its first attachment", func() {
+			receipt, err := db.GetReceipt("receipt-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Attachments).To(Equal([]Attachment{{
+				ID:          "receipt-1",
+				Role:        AttachmentReceipt,
+				Name:        "pharmacy.jpg",
+				Filename:    "receipt-1_pharmacy.jpg",
+				ContentType: "image/jpeg",
+			}}))
+		})
+	})
+
+	Describe("migrating reimbursement status", func() {
+		BeforeEach(func() {
+			Expect(db.Close()).To(Succeed())
+
+			raw, err := bbolt.Open(dbPath, 0600, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(raw.Update(func(tx *bbolt.Tx) error {
+				return tx.Bucket([]byte(reimbursementBucketName)).Put([]byte("reimb-1"),
+					[]byte(`{"id":"reimb-1","receipt_ids":[],"total_amount":2500,"created_at":"2024-01-15T00:00:00Z"}`))
+			})).To(Succeed())
+			Expect(raw.Close()).To(Succeed())
+
+			db, err = NewBoltDB(dbPath)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should mark the reimbursement as paid", func() {
+			reimbursement, err := db.GetReimbursement("reimb-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reimbursement.Status).To(Equal(ReimbursementPaid))
+		})
+	})
 })
@@ -0,0 +1,67 @@
+package receipt
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zombor/hsa-tracker/internal/scanning"
+)
+
+// Duplicate is an existing receipt that looks like the same expense as a newly scanned one
+type Duplicate struct {
+	ReceiptID string   `json:"receipt_id"`
+	Title     string   `json:"title"`
+	Reasons   []string `json:"reasons"` // Why the receipt looks like a duplicate
+}
+
+// maxImageHashDistance is the most bits two perceptual hashes may differ by to count as the same image
+const maxImageHashDistance = 6
+
+// duplicateOf reports why an existing receipt looks like the same expense as the candidate, if it does
+func (r *Receipt) duplicateOf(existing *Receipt) (*Duplicate, bool) {
+	var reasons []string
+
+	if r.ContentHash != "" && r.ContentHash == existing.ContentHash {
+		reasons = append(reasons, "the file is identical")
+	} else if similarImages(r.ImageHash, existing.ImageHash) {
+		reasons = append(reasons, "the image looks the same")
+	}
+
+	if r.Amount != 0 && r.Amount == existing.Amount && sameDay(r.Date, existing.Date) {
+		switch {
+		case strings.EqualFold(strings.TrimSpace(r.Title), strings.TrimSpace(existing.Title)):
+			reasons = append(reasons, "same title, date and amount")
+		case sharesProviderWord(r.Title, existing.Title):
+			reasons = append(reasons, "similar provider, same date and amount")
+		default:
+			reasons = append(reasons, "same date and amount")
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil, false
+	}
+	return &Duplicate{ReceiptID: existing.ID, Title: existing.Title, Reasons: reasons}, true
+}
+
+// similarImages reports whether two hex-encoded perceptual hashes are close enough to be the same image
+func similarImages(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	hashA, err := strconv.ParseUint(a, 16, 64)
+	if err != nil {
+		return false
+	}
+	hashB, err := strconv.ParseUint(b, 16, 64)
+	if err != nil {
+		return false
+	}
+	return scanning.HashDistance(hashA, hashB) <= maxImageHashDistance
+}
+
+// sameDay reports whether two times fall on the same calendar day
+func sameDay(a, b time.Time) bool {
+	return a.Truncate(24 * time.Hour).Equal(b.Truncate(24 * time.Hour))
+}
@@ -0,0 +1,119 @@
+package receipt
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// EOB is an insurance explanation of benefits for a single claim
+type EOB struct {
+	ID                    string    `json:"id"`
+	Provider              string    `json:"provider"`
+	ServiceDate           time.Time `json:"service_date,omitzero"`
+	ClaimNumber           string    `json:"claim_number,omitempty"`
+	BilledAmount          int       `json:"billed_amount"`              // Amount the provider charged, in cents
+	AllowedAmount         int       `json:"allowed_amount"`             // Amount the plan allows, in cents
+	InsurerPaidAmount     int       `json:"insurer_paid_amount"`        // Amount the insurer paid, in cents
+	PatientResponsibility int       `json:"patient_responsibility"`     // Amount the patient owes, in cents
+	AmountsMismatch       bool      `json:"amounts_mismatch,omitempty"` // Set when paid and owed amounts don't add up to allowed
+	Filename              string    `json:"filename"`
+	ContentType           string    `json:"content_type"`
+	ReceiptID             string    `json:"receipt_id,omitempty"` // Receipt the EOB was matched to
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// EOBMatch is a receipt that may be the provider bill an EOB covers
+type EOBMatch struct {
+	ReceiptID string    `json:"receipt_id"`
+	Title     string    `json:"title"`
+	Date      time.Time `json:"date"`
+	Amount    int       `json:"amount"`  // Receipt amount in cents
+	Score     int       `json:"score"`   // Higher scores are better matches
+	Reasons   []string  `json:"reasons"` // Why the receipt was suggested
+}
+
+// minEOBMatchScore is the lowest score a receipt needs to be suggested for an EOB
+const minEOBMatchScore = 3
+
+// maxEOBMatches is the most receipts suggested for an EOB
+const maxEOBMatches = 5
+
+// eobMatchWindow is how long after the date of service a provider bill is expected
+const eobMatchWindow = 60 * 24 * time.Hour
+
+// providerStopWords are common words in provider names that don't identify a provider
+var providerStopWords = map[string]bool{
+	"the": true, "and": true, "inc": true, "llc": true, "of": true,
+	"pc": true, "pa": true, "md": true, "dds": true,
+}
+
+// match scores how likely the receipt is the bill covered by the EOB
+func (e *EOB) match(receipt *Receipt) *EOBMatch {
+	m := &EOBMatch{
+		ReceiptID: receipt.ID,
+		Title:     receipt.Title,
+		Date:      receipt.Date,
+		Amount:    receipt.Amount,
+		Reasons:   []string{},
+	}
+
+	switch {
+	case e.PatientResponsibility > 0 && receipt.Amount == e.PatientResponsibility:
+		m.Score += 3
+		m.Reasons = append(m.Reasons, "amount matches the patient responsibility")
+	case e.BilledAmount > 0 && receipt.Amount == e.BilledAmount:
+		m.Score += 2
+		m.Reasons = append(m.Reasons, "amount matches the billed amount")
+	}
+
+	if sharesProviderWord(e.Provider, receipt.Title) {
+		m.Score += 2
+		m.Reasons = append(m.Reasons, "provider name matches")
+	}
+
+	if !e.ServiceDate.IsZero() {
+		serviceDay := e.ServiceDate.Truncate(24 * time.Hour)
+		receiptDay := receipt.Date.Truncate(24 * time.Hour)
+		switch {
+		case receiptDay.Equal(serviceDay):
+			m.Score += 2
+			m.Reasons = append(m.Reasons, "same date as the service")
+		case !receiptDay.Before(serviceDay) && receiptDay.Sub(serviceDay) <= eobMatchWindow:
+			m.Score++
+			m.Reasons = append(m.Reasons, "dated shortly after the service")
+		}
+	}
+
+	return m
+}
+
+// sharesProviderWord reports whether two names have a distinctive word in common
+func sharesProviderWord(a, b string) bool {
+	words := make(map[string]bool)
+	for _, word := range providerWords(a) {
+		words[word] = true
+	}
+	for _, word := range providerWords(b) {
+		if words[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// providerWords splits a provider name into lowercase words, skipping short and common ones
+func providerWords(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if len(field) < 3 || providerStopWords[field] {
+			continue
+		}
+		words = append(words, field)
+	}
+	return words
+}
@@ -2,11 +2,18 @@ package receipt
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/zombor/hsa-tracker/internal/scanning"
 )
 
 // corsError writes an error response with CORS headers set
@@ -30,9 +37,15 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	w.Write(indexHTML)
 }
 
-// handleListReceipts returns a list of all receipts
+// handleListReceipts returns a list of all receipts, optionally filtered by query parameters
 func (s *Server) handleListReceipts(w http.ResponseWriter, r *http.Request) {
-	receipts, err := s.service.ListReceipts()
+	filter := ReceiptFilter{
+		PatientID:   r.URL.Query().Get("patient_id"),
+		Category:    Category(r.URL.Query().Get("category")),
+		NeedsReview: r.URL.Query().Get("needs_review") == "true",
+	}
+
+	receipts, err := s.service.ListReceipts(filter)
 	if err != nil {
 		slog.Error("Error listing receipts", "error", err)
 		corsError(w, "Internal server error", http.StatusInternalServerError)
@@ -106,7 +119,45 @@ func (s *Server) handleScanReceipt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine content type
+	contentType := uploadContentType(header)
+
+	// The document type picks the scanner prompt; plain receipts are the default
+	docType := scanning.DocumentType(r.FormValue("document_type"))
+	if docType == "" {
+		docType = scanning.DocumentReceipt
+	}
+	if !docType.IsReceipt() {
+		writeJSONError(w, "Unsupported document type: "+string(docType), http.StatusBadRequest)
+		return
+	}
+
+	// Scan receipt
+	receipt, err := s.serviceFor(r).ScanReceipt(header.Filename, data, contentType, docType)
+	if err != nil {
+		slog.Error("Error processing receipt", "filename", header.Filename, "error", err)
+		writeScanError(w, err)
+		return
+	}
+
+	// Warn about likely duplicates before the draft is confirmed
+	duplicates, err := s.service.FindDuplicates(receipt)
+	if err != nil {
+		slog.Error("Error finding duplicate receipts", "filename", header.Filename, "error", err)
+		duplicates = []*Duplicate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct {
+		*Receipt
+		Duplicates []*Duplicate `json:"duplicates"`
+	}{receipt, duplicates}); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// uploadContentType determines the content type of an uploaded file
+func uploadContentType(header *multipart.FileHeader) string {
 	contentType := header.Header.Get("Content-Type")
 	if contentType == "" {
 		ext := strings.ToLower(filepath.Ext(header.Filename))
@@ -127,28 +178,9 @@ func (s *Server) handleScanReceipt(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Normalize content type for common phone formats
-	contentType = strings.ToLower(strings.TrimSpace(contentType))
 	// Preserve HEIC/HEIF MIME types so conversion logic can detect them
 	// The conversion logic will handle converting HEIC to PNG
-
-	// Scan receipt
-	receipt, err := s.service.ScanReceipt(header.Filename, data, contentType)
-	if err != nil {
-		slog.Error("Error processing receipt", "filename", header.Filename, "error", err)
-		setCORSHeaders(w)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(receipt); err != nil {
-		slog.Error("Error encoding response", "error", err)
-	}
+	return strings.ToLower(strings.TrimSpace(contentType))
 }
 
 // handleCreateReceipt handles receipt creation/confirmation
@@ -160,8 +192,12 @@ func (s *Server) handleCreateReceipt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.service.CreateReceipt(&receipt); err != nil {
+	if err := s.serviceFor(r).CreateReceipt(&receipt); err != nil {
 		slog.Error("Error creating receipt", "error", err)
+		if errors.Is(err, ErrInvalidCategory) || errors.Is(err, ErrUnknownPatient) {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		corsError(w, "Error creating receipt", http.StatusInternalServerError)
 		return
 	}
@@ -209,6 +245,142 @@ func (s *Server) handleGetReceiptFile(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
+// handleReceiptHistory returns every recorded change to a receipt, oldest first
+func (s *Server) handleReceiptHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Receipt ID required", http.StatusBadRequest)
+		return
+	}
+	events, err := s.service.ReceiptHistory(id)
+	if err != nil {
+		corsError(w, "Receipt not found", http.StatusNotFound)
+		return
+	}
+
+	// Ensure we always return an array, not nil
+	if events == nil {
+		events = []*ReceiptEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleListAttachments returns the documents stored for a receipt
+func (s *Server) handleListAttachments(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Receipt ID required", http.StatusBadRequest)
+		return
+	}
+	attachments, err := s.service.ListAttachments(id)
+	if err != nil {
+		corsError(w, "Receipt not found", http.StatusNotFound)
+		return
+	}
+
+	// Ensure we always return an array, not nil
+	if attachments == nil {
+		attachments = []Attachment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(attachments); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleAddAttachment stores an uploaded document for a receipt
+// The multipart form carries the document in "file" and its role in "role".
+func (s *Server) handleAddAttachment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Receipt ID required", http.StatusBadRequest)
+		return
+	}
+
+	maxFormSize := int64(50 << 20) // 50MB
+	if err := r.ParseMultipartForm(maxFormSize); err != nil {
+		slog.Error("Error parsing multipart form", "error", err)
+		writeJSONError(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, "No file provided", http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		slog.Error("Error reading file data", "error", err, "filename", header.Filename)
+		writeJSONError(w, "Error reading file. Please try again.", http.StatusInternalServerError)
+		return
+	}
+
+	role := AttachmentRole(r.FormValue("role"))
+	if role == "" {
+		role = AttachmentOther
+	}
+
+	attachment, err := s.serviceFor(r).AddAttachment(id, role, header.Filename, data, uploadContentType(header))
+	if err != nil {
+		slog.Error("Error adding attachment", "receipt_id", id, "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(attachment); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleRemoveAttachment deletes a document from a receipt
+func (s *Server) handleRemoveAttachment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	aid := r.PathValue("aid")
+	if id == "" || aid == "" {
+		corsError(w, "Receipt ID and attachment ID required", http.StatusBadRequest)
+		return
+	}
+	if err := s.serviceFor(r).RemoveAttachment(id, aid); err != nil {
+		slog.Error("Error removing attachment", "receipt_id", id, "attachment_id", aid, "error", err)
+		if errors.Is(err, ErrReceiptLocked) {
+			writeJSONError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetAttachmentFile serves the file of one of a receipt's attachments
+func (s *Server) handleGetAttachmentFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	aid := r.PathValue("aid")
+	if id == "" || aid == "" {
+		corsError(w, "Receipt ID and attachment ID required", http.StatusBadRequest)
+		return
+	}
+	data, contentType, err := s.service.GetAttachmentFile(id, aid)
+	if err != nil {
+		corsError(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
+
 // handleUpdateReceipt handles receipt updates
 func (s *Server) handleUpdateReceipt(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -227,9 +399,69 @@ func (s *Server) handleUpdateReceipt(w http.ResponseWriter, r *http.Request) {
 	// Ensure the ID matches the path parameter
 	receipt.ID = id
 
-	if err := s.service.UpdateReceipt(&receipt); err != nil {
+	if err := s.serviceFor(r).UpdateReceipt(&receipt); err != nil {
 		slog.Error("Error updating receipt", "error", err)
-		corsError(w, "Error updating receipt", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, ErrInvalidCategory), errors.Is(err, ErrUnknownPatient):
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrReceiptDeleted), errors.Is(err, ErrReceiptLocked):
+			writeJSONError(w, err.Error(), http.StatusConflict)
+		default:
+			corsError(w, "Error updating receipt", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(receipt); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleListDrafts lists scanned receipts waiting for review
+func (s *Server) handleListDrafts(w http.ResponseWriter, r *http.Request) {
+	drafts, err := s.service.ListDrafts()
+	if err != nil {
+		slog.Error("Error listing drafts", "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(drafts); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleConfirmReceipt saves the reviewed details of a draft receipt
+func (s *Server) handleConfirmReceipt(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Receipt ID required", http.StatusBadRequest)
+		return
+	}
+
+	var receipt Receipt
+	if err := json.NewDecoder(r.Body).Decode(&receipt); err != nil {
+		slog.Error("Error decoding receipt", "error", err)
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Ensure the ID matches the path parameter
+	receipt.ID = id
+
+	if err := s.serviceFor(r).ConfirmReceipt(&receipt); err != nil {
+		slog.Error("Error confirming receipt", "error", err)
+		switch {
+		case errors.Is(err, ErrInvalidCategory), errors.Is(err, ErrUnknownPatient):
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrNotDraft):
+			writeJSONError(w, err.Error(), http.StatusConflict)
+		default:
+			corsError(w, "Error confirming receipt", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -247,7 +479,11 @@ func (s *Server) handleDeleteReceipt(w http.ResponseWriter, r *http.Request) {
 		corsError(w, "Receipt ID required", http.StatusBadRequest)
 		return
 	}
-	if err := s.service.DeleteReceipt(id); err != nil {
+	if err := s.serviceFor(r).DeleteReceipt(id); err != nil {
+		if errors.Is(err, ErrReceiptLocked) {
+			writeJSONError(w, err.Error(), http.StatusConflict)
+			return
+		}
 		corsError(w, "Error deleting receipt", http.StatusInternalServerError)
 		return
 	}
@@ -255,8 +491,115 @@ func (s *Server) handleDeleteReceipt(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleUnlockReceipt allows one change to a reimbursed receipt
+// The request body carries the reason, which is kept in the receipt's history.
+func (s *Server) handleUnlockReceipt(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Receipt ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := s.serviceFor(r).UnlockReceipt(id, req.Reason)
+	if err != nil {
+		slog.Error("Error unlocking receipt", "receipt_id", id, "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(receipt); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleListTrash lists the receipts in the trash
+func (s *Server) handleListTrash(w http.ResponseWriter, r *http.Request) {
+	trash, err := s.service.ListTrash()
+	if err != nil {
+		slog.Error("Error listing trash", "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(trash); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleRestoreReceipt takes a receipt back out of the trash
+func (s *Server) handleRestoreReceipt(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Receipt ID required", http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := s.serviceFor(r).RestoreReceipt(id)
+	if err != nil {
+		slog.Error("Error restoring receipt", "error", err)
+		if errors.Is(err, ErrNotInTrash) {
+			writeJSONError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		corsError(w, "Error restoring receipt", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(receipt); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handlePurgeReceipt permanently removes a receipt from the trash
+func (s *Server) handlePurgeReceipt(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Receipt ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.serviceFor(r).PurgeReceipt(id); err != nil {
+		slog.Error("Error purging receipt", "error", err)
+		if errors.Is(err, ErrNotInTrash) || errors.Is(err, ErrReceiptLocked) {
+			writeJSONError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		corsError(w, "Error purging receipt", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // handleListReimbursements returns a list of all reimbursements
+// With ?group_by=account the reimbursements are grouped by the account they were drawn from.
 func (s *Server) handleListReimbursements(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("group_by") == "account" {
+		groups, err := s.service.ListReimbursementsByAccount()
+		if err != nil {
+			slog.Error("Error listing reimbursements by account", "error", err)
+			corsError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(groups); err != nil {
+			slog.Error("Error encoding response", "error", err)
+		}
+		return
+	}
+
 	reimbursements, err := s.service.ListReimbursements()
 	if err != nil {
 		slog.Error("Error listing reimbursements", "error", err)
@@ -278,7 +621,10 @@ func (s *Server) handleListReimbursements(w http.ResponseWriter, r *http.Request
 // handleCreateReimbursement handles reimbursement creation
 func (s *Server) handleCreateReimbursement(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ReceiptIDs []string `json:"receipt_ids"`
+		AccountID   string              `json:"account_id"`  // Benefit account the money is drawn from
+		Status      ReimbursementStatus `json:"status"`      // Starting status; defaults to paid
+		ReceiptIDs  []string            `json:"receipt_ids"` // Receipts to reimburse in full
+		Allocations []Allocation        `json:"allocations"` // Receipts to reimburse by a specific amount
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -286,15 +632,19 @@ func (s *Server) handleCreateReimbursement(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	reimbursement, err := s.service.CreateReimbursement(req.ReceiptIDs)
+	allocations := req.Allocations
+	for _, receiptID := range req.ReceiptIDs {
+		allocations = append(allocations, Allocation{ReceiptID: receiptID})
+	}
+
+	reimbursement, err := s.serviceFor(r).CreateReimbursement(req.AccountID, req.Status, allocations)
 	if err != nil {
 		slog.Error("Error creating reimbursement", "error", err)
-		setCORSHeaders(w)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		code := http.StatusBadRequest
+		if errors.Is(err, ErrPredatesHSA) {
+			code = http.StatusUnprocessableEntity
+		}
+		writeJSONError(w, err.Error(), code)
 		return
 	}
 
@@ -312,15 +662,21 @@ func (s *Server) handleGetReimbursement(w http.ResponseWriter, r *http.Request)
 		corsError(w, "Reimbursement ID required", http.StatusBadRequest)
 		return
 	}
-	reimbursement, receipts, err := s.service.GetReimbursementWithReceipts(id)
+	reimbursement, receipts, missing, err := s.service.GetReimbursementWithReceipts(id)
 	if err != nil {
 		corsError(w, "Reimbursement not found", http.StatusNotFound)
 		return
 	}
 
 	response := map[string]interface{}{
-		"reimbursement": reimbursement,
-		"receipts":      receipts,
+		"reimbursement":       reimbursement,
+		"receipts":            receipts,
+		"missing_receipt_ids": missing,
+	}
+	if reimbursement.AccountID != "" {
+		if account, err := s.service.GetAccount(reimbursement.AccountID); err == nil {
+			response["account"] = account
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -329,6 +685,621 @@ func (s *Server) handleGetReimbursement(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleTransitionReimbursement returns a handler that moves a reimbursement to the given status
+// The request body may carry a note explaining the change, e.g. why a claim was rejected.
+func (s *Server) handleTransitionReimbursement(status ReimbursementStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if id == "" {
+			corsError(w, "Reimbursement ID required", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Note string `json:"note"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				corsError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		reimbursement, err := s.serviceFor(r).TransitionReimbursement(id, status, req.Note)
+		if err != nil {
+			slog.Error("Error changing reimbursement status", "reimbursement_id", id, "status", status, "error", err)
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(reimbursement); err != nil {
+			slog.Error("Error encoding response", "error", err)
+		}
+	}
+}
+
+// handleUpdateReimbursement records the payment details of a reimbursement
+func (s *Server) handleUpdateReimbursement(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Reimbursement ID required", http.StatusBadRequest)
+		return
+	}
+
+	var reimbursement Reimbursement
+	if err := json.NewDecoder(r.Body).Decode(&reimbursement); err != nil {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Ensure the ID matches the path parameter
+	reimbursement.ID = id
+
+	if err := s.serviceFor(r).UpdateReimbursement(&reimbursement); err != nil {
+		slog.Error("Error updating reimbursement", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reimbursement); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// writeJSONError writes a JSON error response with CORS headers set
+func writeJSONError(w http.ResponseWriter, message string, code int) {
+	setCORSHeaders(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
+
+// writeScanError reports a failed scan
+// When the scanner's API is out of quota, the client is told to retry and when, if the API said.
+func writeScanError(w http.ResponseWriter, err error) {
+	if !errors.Is(err, scanning.ErrQuotaExhausted) {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	message := "The scanner is rate limited or out of quota."
+	var apiErr *scanning.APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		seconds := int(math.Ceil(apiErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		message += fmt.Sprintf(" Please retry in %ds.", seconds)
+	}
+	writeJSONError(w, message, http.StatusTooManyRequests)
+}
+
+// handleListMembers returns a list of all household members
+func (s *Server) handleListMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := s.service.ListMembers()
+	if err != nil {
+		slog.Error("Error listing members", "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(members); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleCreateMember handles household member creation
+func (s *Server) handleCreateMember(w http.ResponseWriter, r *http.Request) {
+	var member Member
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.service.CreateMember(&member); err != nil {
+		slog.Error("Error creating member", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(member); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleGetMember returns a single household member
+func (s *Server) handleGetMember(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Member ID required", http.StatusBadRequest)
+		return
+	}
+	member, err := s.service.GetMember(id)
+	if err != nil {
+		corsError(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(member); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleUpdateMember handles household member updates
+func (s *Server) handleUpdateMember(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Member ID required", http.StatusBadRequest)
+		return
+	}
+
+	var member Member
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Ensure the ID matches the path parameter
+	member.ID = id
+
+	if err := s.service.UpdateMember(&member); err != nil {
+		slog.Error("Error updating member", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(member); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleDeleteMember deletes a household member
+func (s *Server) handleDeleteMember(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Member ID required", http.StatusBadRequest)
+		return
+	}
+	if err := s.service.DeleteMember(id); err != nil {
+		slog.Error("Error deleting member", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMemberTotals returns per-member spending totals, optionally for a single year
+func (s *Server) handleMemberTotals(w http.ResponseWriter, r *http.Request) {
+	year, ok := parseYearParam(w, r)
+	if !ok {
+		return
+	}
+
+	totals, err := s.service.MemberTotals(year)
+	if err != nil {
+		slog.Error("Error calculating member totals", "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(totals); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleListAccounts returns a list of all benefit accounts
+func (s *Server) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := s.service.ListAccounts()
+	if err != nil {
+		slog.Error("Error listing accounts", "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(accounts); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleCreateAccount handles benefit account creation
+func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
+	var account Account
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.service.CreateAccount(&account); err != nil {
+		slog.Error("Error creating account", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(account); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleGetAccount returns a single benefit account
+func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Account ID required", http.StatusBadRequest)
+		return
+	}
+	account, err := s.service.GetAccount(id)
+	if err != nil {
+		corsError(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(account); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleUpdateAccount handles benefit account updates
+func (s *Server) handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Account ID required", http.StatusBadRequest)
+		return
+	}
+
+	var account Account
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Ensure the ID matches the path parameter
+	account.ID = id
+
+	if err := s.service.UpdateAccount(&account); err != nil {
+		slog.Error("Error updating account", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(account); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleDeleteAccount deletes a benefit account
+func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Account ID required", http.StatusBadRequest)
+		return
+	}
+	if err := s.service.DeleteAccount(id); err != nil {
+		slog.Error("Error deleting account", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListContributions returns HSA contributions, optionally filtered by ?year
+func (s *Server) handleListContributions(w http.ResponseWriter, r *http.Request) {
+	year, ok := parseYearParam(w, r)
+	if !ok {
+		return
+	}
+
+	contributions, err := s.service.ListContributions(year)
+	if err != nil {
+		slog.Error("Error listing contributions", "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(contributions); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleCreateContribution handles HSA contribution creation
+func (s *Server) handleCreateContribution(w http.ResponseWriter, r *http.Request) {
+	var contribution Contribution
+	if err := json.NewDecoder(r.Body).Decode(&contribution); err != nil {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.service.CreateContribution(&contribution); err != nil {
+		slog.Error("Error creating contribution", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(contribution); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleGetContribution returns a single HSA contribution
+func (s *Server) handleGetContribution(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Contribution ID required", http.StatusBadRequest)
+		return
+	}
+	contribution, err := s.service.GetContribution(id)
+	if err != nil {
+		corsError(w, "Contribution not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(contribution); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleUpdateContribution handles HSA contribution updates
+func (s *Server) handleUpdateContribution(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Contribution ID required", http.StatusBadRequest)
+		return
+	}
+
+	var contribution Contribution
+	if err := json.NewDecoder(r.Body).Decode(&contribution); err != nil {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Ensure the ID matches the path parameter
+	contribution.ID = id
+
+	if err := s.service.UpdateContribution(&contribution); err != nil {
+		slog.Error("Error updating contribution", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(contribution); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleDeleteContribution deletes an HSA contribution
+func (s *Server) handleDeleteContribution(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "Contribution ID required", http.StatusBadRequest)
+		return
+	}
+	if err := s.service.DeleteContribution(id); err != nil {
+		slog.Error("Error deleting contribution", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleContributionSummary returns per-account contribution totals checked against the IRS limits
+func (s *Server) handleContributionSummary(w http.ResponseWriter, r *http.Request) {
+	year, ok := parseYearParam(w, r)
+	if !ok {
+		return
+	}
+
+	summaries, err := s.service.ContributionSummaries(year)
+	if err != nil {
+		slog.Error("Error summarizing contributions", "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summaries); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleScanEOB scans an uploaded explanation of benefits and suggests receipts to match it to
+func (s *Server) handleScanEOB(w http.ResponseWriter, r *http.Request) {
+	maxFormSize := int64(50 << 20) // 50MB
+	if err := r.ParseMultipartForm(maxFormSize); err != nil {
+		slog.Error("Error parsing multipart form", "error", err)
+		writeJSONError(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, "No file provided", http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		slog.Error("Error reading file data", "error", err, "filename", header.Filename)
+		writeJSONError(w, "Error reading file. Please try again.", http.StatusInternalServerError)
+		return
+	}
+
+	eob, err := s.service.ScanEOB(header.Filename, data, uploadContentType(header))
+	if err != nil {
+		slog.Error("Error processing eob", "filename", header.Filename, "error", err)
+		writeScanError(w, err)
+		return
+	}
+	matches, err := s.service.SuggestEOBMatches(eob.ID)
+	if err != nil {
+		slog.Error("Error suggesting eob matches", "eob_id", eob.ID, "error", err)
+		matches = []*EOBMatch{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"eob":     eob,
+		"matches": matches,
+	}); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleListEOBs returns all explanations of benefits
+func (s *Server) handleListEOBs(w http.ResponseWriter, r *http.Request) {
+	eobs, err := s.service.ListEOBs()
+	if err != nil {
+		slog.Error("Error listing eobs", "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(eobs); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleGetEOB returns an explanation of benefits with its suggested receipt matches
+func (s *Server) handleGetEOB(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "EOB ID required", http.StatusBadRequest)
+		return
+	}
+	eob, err := s.service.GetEOB(id)
+	if err != nil {
+		corsError(w, "EOB not found", http.StatusNotFound)
+		return
+	}
+	matches, err := s.service.SuggestEOBMatches(id)
+	if err != nil {
+		slog.Error("Error suggesting eob matches", "eob_id", id, "error", err)
+		corsError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"eob":     eob,
+		"matches": matches,
+	}); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleGetEOBFile returns the scanned file for an explanation of benefits
+func (s *Server) handleGetEOBFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "EOB ID required", http.StatusBadRequest)
+		return
+	}
+	data, contentType, err := s.service.GetEOBFile(id)
+	if err != nil {
+		corsError(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
+
+// handleMatchEOB links an explanation of benefits to a receipt
+func (s *Server) handleMatchEOB(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "EOB ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ReceiptID string `json:"receipt_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReceiptID == "" {
+		corsError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := s.serviceFor(r).MatchEOB(id, req.ReceiptID)
+	if err != nil {
+		slog.Error("Error matching eob", "eob_id", id, "receipt_id", req.ReceiptID, "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(receipt); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+// handleUnmatchEOB removes the link between an explanation of benefits and its receipt
+func (s *Server) handleUnmatchEOB(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "EOB ID required", http.StatusBadRequest)
+		return
+	}
+	if err := s.serviceFor(r).UnmatchEOB(id); err != nil {
+		slog.Error("Error unmatching eob", "eob_id", id, "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteEOB deletes an explanation of benefits
+func (s *Server) handleDeleteEOB(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		corsError(w, "EOB ID required", http.StatusBadRequest)
+		return
+	}
+	if err := s.serviceFor(r).DeleteEOB(id); err != nil {
+		slog.Error("Error deleting eob", "error", err)
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseYearParam reads the optional ?year query parameter, writing a 400 response if it is invalid
+func parseYearParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	y := r.URL.Query().Get("year")
+	if y == "" {
+		return 0, true
+	}
+	year, err := strconv.Atoi(y)
+	if err != nil {
+		corsError(w, "Invalid year", http.StatusBadRequest)
+		return 0, false
+	}
+	return year, true
+}
+
 // handleStaticCSS serves the CSS file
 func (s *Server) handleStaticCSS(w http.ResponseWriter, r *http.Request) {
 	setCORSHeaders(w)
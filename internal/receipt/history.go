@@ -0,0 +1,32 @@
+package receipt
+
+import "time"
+
+// ReceiptAction is the kind of change recorded in a receipt's history
+type ReceiptAction string
+
+// Supported receipt actions
+const (
+	ActionScanned    ReceiptAction = "scanned"    // Details extracted by the scanner, before anyone reviewed them
+	ActionCreated    ReceiptAction = "created"    // Entered by hand
+	ActionConfirmed  ReceiptAction = "confirmed"  // Scanned details reviewed and saved
+	ActionUpdated    ReceiptAction = "updated"    // Details, attachments or EOB link changed
+	ActionReimbursed ReceiptAction = "reimbursed" // Claimed by a reimbursement
+	ActionReleased   ReceiptAction = "released"   // Reimbursement rejected or voided
+	ActionDeleted    ReceiptAction = "deleted"    // Moved to the trash
+	ActionRestored   ReceiptAction = "restored"   // Taken back out of the trash
+	ActionPurged     ReceiptAction = "purged"     // Permanently removed
+	ActionUnlocked   ReceiptAction = "unlocked"   // Reimbursed receipt opened for one change
+)
+
+// ReceiptEvent is an entry in a receipt's append-only change history
+type ReceiptEvent struct {
+	ReceiptID       string        `json:"receipt_id"`
+	Action          ReceiptAction `json:"action"`
+	Actor           string        `json:"actor"` // Who made the change: the signed-in user, or "system" for background jobs
+	At              time.Time     `json:"at"`
+	Before          *Receipt      `json:"before,omitempty"` // Empty when the receipt was created
+	After           *Receipt      `json:"after,omitempty"`  // Empty when the receipt was purged
+	ReimbursementID string        `json:"reimbursement_id,omitempty"`
+	Note            string        `json:"note,omitempty"` // Why the change was made, e.g. the reason for an unlock
+}
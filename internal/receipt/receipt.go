@@ -1,26 +1,338 @@
 package receipt
 
-import "time"
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// Category classifies an expense for year-end review
+type Category string
+
+// Supported expense categories
+const (
+	CategoryMedical      Category = "medical"
+	CategoryDental       Category = "dental"
+	CategoryVision       Category = "vision"
+	CategoryPharmacy     Category = "pharmacy"
+	CategoryOTC          Category = "otc"
+	CategoryMentalHealth Category = "mental_health"
+	CategoryMileage      Category = "mileage"
+	CategoryPremiums     Category = "premiums"
+	CategoryOther        Category = "other"
+)
+
+// Categories lists every supported expense category
+// Scanners are constrained to the same list by the schema tag on scanning.ReceiptData.
+var Categories = []Category{
+	CategoryMedical,
+	CategoryDental,
+	CategoryVision,
+	CategoryPharmacy,
+	CategoryOTC,
+	CategoryMentalHealth,
+	CategoryMileage,
+	CategoryPremiums,
+	CategoryOther,
+}
+
+// ErrInvalidCategory is returned when a receipt has a category outside the taxonomy
+var ErrInvalidCategory = errors.New("invalid category")
+
+// ErrUnknownPatient is returned when a receipt's patient isn't a household member
+var ErrUnknownPatient = errors.New("unknown patient")
+
+// Valid reports whether the category is part of the supported taxonomy
+func (c Category) Valid() bool {
+	for _, category := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// ReceiptStatus is whether a scanned receipt has been reviewed
+type ReceiptStatus string
+
+// Supported receipt statuses
+const (
+	ReceiptDraft     ReceiptStatus = "draft" // Scanned but not yet reviewed
+	ReceiptConfirmed ReceiptStatus = "confirmed"
+)
 
 // Receipt represents a receipt with metadata
 type Receipt struct {
-	ID              string    `json:"id"`
-	Title           string    `json:"title"`
-	Date            time.Time `json:"date"`
-	Amount          int       `json:"amount"` // Amount in cents
-	Filename        string    `json:"filename"`
-	ContentType     string    `json:"content_type"`
-	ReimbursementID string    `json:"reimbursement_id,omitempty"` // ID of the reimbursement this receipt belongs to
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID                    string         `json:"id"`
+	Title                 string         `json:"title"`
+	Date                  time.Time      `json:"date"`
+	Amount                int            `json:"amount"`   // Amount in cents
+	Filename              string         `json:"filename"` // The scanned file; also listed in Attachments
+	ContentType           string         `json:"content_type"`
+	Status                ReceiptStatus  `json:"status,omitempty"`       // Receipts saved before drafts existed have no status and count as confirmed
+	Attachments           []Attachment   `json:"attachments,omitempty"`  // Every document stored for this expense
+	ContentHash           string         `json:"content_hash,omitempty"` // SHA-256 of the scanned file, for duplicate detection
+	ImageHash             string         `json:"image_hash,omitempty"`   // Perceptual hash of the scanned image in hex, for duplicate detection
+	Category              Category       `json:"category,omitempty"`
+	LineItems             []LineItem     `json:"line_items,omitempty"`             // Itemized lines, if the receipt was itemized
+	LineItemsMismatch     bool           `json:"line_items_mismatch,omitempty"`    // Set when the line items don't add up to Amount
+	PatientID             string         `json:"patient_id,omitempty"`             // ID of the household member the expense was for
+	Allocations           []Allocation   `json:"allocations,omitempty"`            // Reimbursements drawn against this receipt
+	PredatesHSA           bool           `json:"predates_hsa,omitempty"`           // Computed on read: incurred before any HSA was established
+	EOBID                 string         `json:"eob_id,omitempty"`                 // Explanation of benefits matched to this receipt
+	PatientResponsibility int            `json:"patient_responsibility,omitempty"` // Amount owed per the matched EOB, in cents
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             time.Time      `json:"deleted_at,omitzero"`      // Set while the receipt is in the trash
+	Unlocked              bool           `json:"unlocked,omitempty"`       // Set by UnlockReceipt to allow one change to a reimbursed receipt
+	Fields                *ReceiptFields `json:"fields,omitempty"`         // Where scanned details came from; nil for receipts entered by hand
+	NeedsReview           bool           `json:"needs_review,omitempty"`   // Set while a scanned detail was defaulted or is low confidence
+	DocumentType          string         `json:"document_type,omitempty"`  // Kind of document the receipt was scanned as, e.g. "pharmacy"
+	PromptVersion         string         `json:"prompt_version,omitempty"` // Version of the scanner prompt that read the receipt
+	RawText               string         `json:"raw_text,omitempty"`       // Text read by OCR, for scanners that run it
+}
+
+// LineItem is a single line on an itemized receipt
+type LineItem struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	Amount      int     `json:"amount"`   // Line total in cents; negative for discounts
+	Eligible    bool    `json:"eligible"` // Whether the item is an HSA-eligible expense
+}
+
+// Provenance is where a receipt detail's value came from
+type Provenance string
+
+// Supported provenances
+const (
+	ProvenanceExtracted Provenance = "extracted" // Read from the document by the scanner
+	ProvenanceInferred  Provenance = "inferred"  // Worked out by the scanner from context rather than read directly
+	ProvenanceDefaulted Provenance = "defaulted" // Filled in because the scanner found nothing usable
+	ProvenanceEdited    Provenance = "edited"    // Entered or corrected by a person
+)
+
+// FieldConfidence describes how much to trust a single receipt detail
+type FieldConfidence struct {
+	Provenance Provenance `json:"provenance"`
+	Confidence float64    `json:"confidence,omitempty"` // Scanner's confidence from 0 to 1; zero when it didn't say
+	Disputed   bool       `json:"disputed,omitempty"`   // Set when two scanners read different values
+}
+
+// ReceiptFields holds the confidence of each scanned receipt detail
+type ReceiptFields struct {
+	Title    FieldConfidence `json:"title"`
+	Date     FieldConfidence `json:"date"`
+	Amount   FieldConfidence `json:"amount"`
+	Category FieldConfidence `json:"category"`
+}
+
+// minFieldConfidence is the lowest scanner confidence accepted without review
+const minFieldConfidence = 0.6
+
+// needsReview reports whether the detail should be checked by a person
+func (f FieldConfidence) needsReview() bool {
+	switch f.Provenance {
+	case ProvenanceDefaulted:
+		return true
+	case ProvenanceEdited:
+		return false
+	}
+	return f.Disputed || f.Confidence > 0 && f.Confidence < minFieldConfidence
+}
+
+// needsReview reports whether any scanned detail should be checked by a person
+func (f *ReceiptFields) needsReview() bool {
+	if f == nil {
+		return false
+	}
+	return f.Title.needsReview() || f.Date.needsReview() || f.Amount.needsReview() || f.Category.needsReview()
+}
+
+// reviewed returns the field confidences after a person saved updated details
+// Details that changed were entered by the person, so they no longer need review.
+func (f *ReceiptFields) reviewed(before, after *Receipt) *ReceiptFields {
+	if f == nil {
+		return nil
+	}
+	reviewed := *f
+	edited := FieldConfidence{Provenance: ProvenanceEdited}
+	if after.Title != before.Title {
+		reviewed.Title = edited
+	}
+	if !after.Date.Equal(before.Date) {
+		reviewed.Date = edited
+	}
+	if after.Amount != before.Amount {
+		reviewed.Amount = edited
+	}
+	if after.Category != before.Category {
+		reviewed.Category = edited
+	}
+	return &reviewed
+}
+
+// ReimbursableAmount returns the portion of the receipt that can be reimbursed, in cents
+// Itemized receipts are limited to the subtotal of their eligible line items;
+// receipts without line items are fully reimbursable. Receipts matched to an EOB are limited to the
+// patient responsibility, and receipts that predate the HSA are not reimbursable.
+func (r *Receipt) ReimbursableAmount() int {
+	if r.PredatesHSA {
+		return 0
+	}
+	if r.EOBID != "" {
+		return r.PatientResponsibility
+	}
+	if len(r.LineItems) == 0 {
+		return r.Amount
+	}
+	var eligible int
+	for _, item := range r.LineItems {
+		if item.Eligible {
+			eligible += item.Amount
+		}
+	}
+	return eligible
+}
+
+// ReimbursedAmount returns how much of the receipt has been reimbursed to date, in cents
+func (r *Receipt) ReimbursedAmount() int {
+	var reimbursed int
+	for _, allocation := range r.Allocations {
+		reimbursed += allocation.Amount
+	}
+	return reimbursed
+}
+
+// OutstandingAmount returns the reimbursable balance not yet reimbursed, in cents
+func (r *Receipt) OutstandingAmount() int {
+	outstanding := r.ReimbursableAmount() - r.ReimbursedAmount()
+	if outstanding < 0 {
+		return 0
+	}
+	return outstanding
+}
+
+// ErrNotDraft is returned when confirming a receipt that has already been reviewed
+var ErrNotDraft = errors.New("receipt is not a draft")
+
+// ErrReceiptDeleted is returned when changing a receipt that is in the trash
+var ErrReceiptDeleted = errors.New("receipt is in the trash")
+
+// ErrNotInTrash is returned when restoring or purging a receipt that isn't in the trash
+var ErrNotInTrash = errors.New("receipt is not in the trash")
+
+// IsDeleted reports whether the receipt is in the trash
+func (r *Receipt) IsDeleted() bool {
+	return !r.DeletedAt.IsZero()
+}
+
+// IsDraft reports whether the receipt was scanned but hasn't been reviewed yet
+func (r *Receipt) IsDraft() bool {
+	return r.Status == ReceiptDraft
+}
+
+// IsReimbursed reports whether any reimbursement has been drawn against the receipt
+func (r *Receipt) IsReimbursed() bool {
+	return len(r.Allocations) > 0
+}
+
+// ErrReceiptLocked is returned when deleting a reimbursed receipt or changing what was reimbursed
+var ErrReceiptLocked = errors.New("receipt is locked because it has been reimbursed")
+
+// IsLocked reports whether the receipt is reimbursed and hasn't been unlocked for a change
+// A reimbursement is substantiated by the receipt as it was, so its amount, date, line items
+// and files can't change while locked. Reimbursed receipts can't be deleted even when unlocked.
+func (r *Receipt) IsLocked() bool {
+	return r.IsReimbursed() && !r.Unlocked
+}
+
+// lockedChanges lists the locked fields that differ in updated
+func (r *Receipt) lockedChanges(updated *Receipt) []string {
+	var fields []string
+	if updated.Amount != r.Amount {
+		fields = append(fields, "amount")
+	}
+	if !updated.Date.Equal(r.Date) {
+		fields = append(fields, "date")
+	}
+	if !slices.Equal(updated.LineItems, r.LineItems) {
+		fields = append(fields, "line items")
+	}
+	return fields
+}
+
+// Allocation is the portion of a receipt paid out by a single reimbursement
+type Allocation struct {
+	ReimbursementID string `json:"reimbursement_id,omitempty"`
+	ReceiptID       string `json:"receipt_id"`
+	Amount          int    `json:"amount"` // Amount in cents; 0 in a request means the outstanding balance
+}
+
+// PaymentMethod is how reimbursed money reached the account holder
+type PaymentMethod string
+
+// Supported payment methods
+const (
+	PaymentCustodianTransfer PaymentMethod = "custodian_transfer" // Transfer initiated from the HSA custodian
+	PaymentCheck             PaymentMethod = "check"
+	PaymentDirectDeposit     PaymentMethod = "direct_deposit"
+)
+
+// Valid reports whether the payment method is supported
+func (m PaymentMethod) Valid() bool {
+	switch m {
+	case PaymentCustodianTransfer, PaymentCheck, PaymentDirectDeposit:
+		return true
+	}
+	return false
 }
 
 // Reimbursement represents a reimbursement event with associated receipts
 type Reimbursement struct {
-	ID          string    `json:"id"`
-	ReceiptIDs  []string  `json:"receipt_ids"` // IDs of receipts in this reimbursement
-	TotalAmount int       `json:"total_amount"` // Total amount in cents
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID            string              `json:"id"`
+	AccountID     string              `json:"account_id,omitempty"` // ID of the benefit account the money was drawn from
+	ReceiptIDs    []string            `json:"receipt_ids"`          // IDs of receipts in this reimbursement
+	Allocations   []Allocation        `json:"allocations"`          // Amount reimbursed for each receipt
+	TotalAmount   int                 `json:"total_amount"`         // Total amount in cents
+	Status        ReimbursementStatus `json:"status"`
+	History       []StatusChange      `json:"history,omitempty"`  // Every status change, oldest first
+	PaidDate      time.Time           `json:"paid_date,omitzero"` // Date the money arrived
+	PaymentMethod PaymentMethod       `json:"payment_method,omitempty"`
+	Reference     string              `json:"reference,omitempty"` // Custodian's transaction reference
+	Notes         string              `json:"notes,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
 }
 
+// Member represents a household member (account holder, spouse or dependent) covered by the HSA
+type Member struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Relationship string    `json:"relationship,omitempty"` // e.g. "self", "spouse", "dependent"
+	BirthDate    time.Time `json:"birth_date,omitzero"`    // Used to determine HSA catch-up eligibility
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ReceiptFilter narrows the receipts returned by Service.ListReceipts
+// Zero-valued fields do not filter
+type ReceiptFilter struct {
+	PatientID   string
+	Category    Category
+	NeedsReview bool // Only receipts with scanned details that should be checked
+}
+
+// MemberTotal summarizes receipt spending for a single household member
+type MemberTotal struct {
+	MemberID     string `json:"member_id"` // Empty for receipts not assigned to a member
+	Name         string `json:"name"`
+	ReceiptCount int    `json:"receipt_count"`
+	TotalAmount  int    `json:"total_amount"` // Total amount in cents
+}
+
+// AccountReimbursements groups reimbursements drawn from the same benefit account
+type AccountReimbursements struct {
+	Account        *Account         `json:"account"` // Nil for reimbursements not drawn from an account
+	Reimbursements []*Reimbursement `json:"reimbursements"`
+	TotalAmount    int              `json:"total_amount"` // Total amount in cents, leaving out rejected and voided reimbursements
+}
@@ -0,0 +1,70 @@
+package receipt
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReimbursementStatus is where a reimbursement is in its lifecycle
+type ReimbursementStatus string
+
+// Supported reimbursement statuses
+const (
+	ReimbursementDraft     ReimbursementStatus = "draft"     // Receipts set aside but the claim isn't filed yet
+	ReimbursementRequested ReimbursementStatus = "requested" // Claim filed with the custodian or plan administrator
+	ReimbursementPaid      ReimbursementStatus = "paid"      // Money received
+	ReimbursementRejected  ReimbursementStatus = "rejected"  // Claim denied; the receipts are outstanding again
+	ReimbursementVoided    ReimbursementStatus = "voided"    // Recorded by mistake; the receipts are outstanding again
+)
+
+// Valid reports whether the reimbursement status is supported
+func (s ReimbursementStatus) Valid() bool {
+	_, ok := reimbursementTransitions[s]
+	return ok
+}
+
+// Releases reports whether reimbursements in this status no longer hold their receipts' amounts
+func (s ReimbursementStatus) Releases() bool {
+	return s == ReimbursementRejected || s == ReimbursementVoided
+}
+
+// reimbursementTransitions lists the statuses each status can move to
+var reimbursementTransitions = map[ReimbursementStatus][]ReimbursementStatus{
+	ReimbursementDraft:     {ReimbursementRequested, ReimbursementPaid, ReimbursementVoided},
+	ReimbursementRequested: {ReimbursementPaid, ReimbursementRejected, ReimbursementVoided},
+	ReimbursementPaid:      {ReimbursementVoided},
+	ReimbursementRejected:  {},
+	ReimbursementVoided:    {},
+}
+
+// canTransition reports whether a reimbursement may move from one status to another
+func canTransition(from, to ReimbursementStatus) bool {
+	for _, next := range reimbursementTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange records a reimbursement moving to a new status
+type StatusChange struct {
+	From ReimbursementStatus `json:"from,omitempty"` // Empty when the reimbursement was created
+	To   ReimbursementStatus `json:"to"`
+	At   time.Time           `json:"at"`
+	Note string              `json:"note,omitempty"`
+}
+
+// transition moves the reimbursement to a new status and records the change
+func (r *Reimbursement) transition(to ReimbursementStatus, note string, now time.Time) error {
+	if !to.Valid() {
+		return fmt.Errorf("invalid reimbursement status: %s", to)
+	}
+	if !canTransition(r.Status, to) {
+		return fmt.Errorf("a %s reimbursement can't be marked %s", r.Status, to)
+	}
+	r.History = append(r.History, StatusChange{From: r.Status, To: to, At: now, Note: note})
+	r.Status = to
+	r.UpdatedAt = now
+	return nil
+}
@@ -90,6 +90,15 @@ func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// serviceFor returns the service acting for the request's user, so receipt history records who made each change
+func (s *Server) serviceFor(r *http.Request) *Service {
+	actor := "anonymous"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		actor = username
+	}
+	return s.service.WithActor(actor)
+}
+
 // setCORSHeaders sets CORS headers on a response
 func (s *Server) setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -127,7 +136,15 @@ func (s *Server) registerRoutes() {
 	s.mux.HandleFunc("GET /static/app.js", s.requireAuth(s.handleStaticJS))
 
 	// API endpoints - receipts (most specific paths first)
+	s.mux.HandleFunc("GET /api/receipts/{id}/attachments/{aid}/file", s.requireAuth(s.handleGetAttachmentFile))
+	s.mux.HandleFunc("DELETE /api/receipts/{id}/attachments/{aid}", s.requireAuth(s.handleRemoveAttachment))
+	s.mux.HandleFunc("GET /api/receipts/{id}/attachments", s.requireAuth(s.handleListAttachments))
+	s.mux.HandleFunc("POST /api/receipts/{id}/attachments", s.requireAuth(s.handleAddAttachment))
 	s.mux.HandleFunc("GET /api/receipts/{id}/file", s.requireAuth(s.handleGetReceiptFile))
+	s.mux.HandleFunc("GET /api/receipts/{id}/history", s.requireAuth(s.handleReceiptHistory))
+	s.mux.HandleFunc("POST /api/receipts/{id}/confirm", s.requireAuth(s.handleConfirmReceipt))
+	s.mux.HandleFunc("POST /api/receipts/{id}/unlock", s.requireAuth(s.handleUnlockReceipt))
+	s.mux.HandleFunc("GET /api/receipts/drafts", s.requireAuth(s.handleListDrafts))
 	s.mux.HandleFunc("GET /api/receipts/{id}", s.requireAuth(s.handleGetReceipt))
 	s.mux.HandleFunc("PUT /api/receipts/{id}", s.requireAuth(s.handleUpdateReceipt))
 	s.mux.HandleFunc("DELETE /api/receipts/{id}", s.requireAuth(s.handleDeleteReceipt))
@@ -135,11 +152,53 @@ func (s *Server) registerRoutes() {
 	s.mux.HandleFunc("POST /api/receipts", s.requireAuth(s.handleCreateReceipt))
 	s.mux.HandleFunc("POST /api/receipts/scan", s.requireAuth(s.handleScanReceipt))
 
+	// API endpoints - trash
+	s.mux.HandleFunc("POST /api/trash/{id}/restore", s.requireAuth(s.handleRestoreReceipt))
+	s.mux.HandleFunc("DELETE /api/trash/{id}", s.requireAuth(s.handlePurgeReceipt))
+	s.mux.HandleFunc("GET /api/trash", s.requireAuth(s.handleListTrash))
+
 	// API endpoints - reimbursements
+	s.mux.HandleFunc("POST /api/reimbursements/{id}/request", s.requireAuth(s.handleTransitionReimbursement(ReimbursementRequested)))
+	s.mux.HandleFunc("POST /api/reimbursements/{id}/pay", s.requireAuth(s.handleTransitionReimbursement(ReimbursementPaid)))
+	s.mux.HandleFunc("POST /api/reimbursements/{id}/reject", s.requireAuth(s.handleTransitionReimbursement(ReimbursementRejected)))
+	s.mux.HandleFunc("POST /api/reimbursements/{id}/void", s.requireAuth(s.handleTransitionReimbursement(ReimbursementVoided)))
 	s.mux.HandleFunc("GET /api/reimbursements/{id}", s.requireAuth(s.handleGetReimbursement))
+	s.mux.HandleFunc("PUT /api/reimbursements/{id}", s.requireAuth(s.handleUpdateReimbursement))
 	s.mux.HandleFunc("GET /api/reimbursements", s.requireAuth(s.handleListReimbursements))
 	s.mux.HandleFunc("POST /api/reimbursements", s.requireAuth(s.handleCreateReimbursement))
 
+	// API endpoints - household members
+	s.mux.HandleFunc("GET /api/members/totals", s.requireAuth(s.handleMemberTotals))
+	s.mux.HandleFunc("GET /api/members/{id}", s.requireAuth(s.handleGetMember))
+	s.mux.HandleFunc("PUT /api/members/{id}", s.requireAuth(s.handleUpdateMember))
+	s.mux.HandleFunc("DELETE /api/members/{id}", s.requireAuth(s.handleDeleteMember))
+	s.mux.HandleFunc("GET /api/members", s.requireAuth(s.handleListMembers))
+	s.mux.HandleFunc("POST /api/members", s.requireAuth(s.handleCreateMember))
+
+	// API endpoints - benefit accounts
+	s.mux.HandleFunc("GET /api/accounts/{id}", s.requireAuth(s.handleGetAccount))
+	s.mux.HandleFunc("PUT /api/accounts/{id}", s.requireAuth(s.handleUpdateAccount))
+	s.mux.HandleFunc("DELETE /api/accounts/{id}", s.requireAuth(s.handleDeleteAccount))
+	s.mux.HandleFunc("GET /api/accounts", s.requireAuth(s.handleListAccounts))
+	s.mux.HandleFunc("POST /api/accounts", s.requireAuth(s.handleCreateAccount))
+
+	// API endpoints - HSA contributions
+	s.mux.HandleFunc("GET /api/contributions/summary", s.requireAuth(s.handleContributionSummary))
+	s.mux.HandleFunc("GET /api/contributions/{id}", s.requireAuth(s.handleGetContribution))
+	s.mux.HandleFunc("PUT /api/contributions/{id}", s.requireAuth(s.handleUpdateContribution))
+	s.mux.HandleFunc("DELETE /api/contributions/{id}", s.requireAuth(s.handleDeleteContribution))
+	s.mux.HandleFunc("GET /api/contributions", s.requireAuth(s.handleListContributions))
+	s.mux.HandleFunc("POST /api/contributions", s.requireAuth(s.handleCreateContribution))
+
+	// API endpoints - insurance explanations of benefits
+	s.mux.HandleFunc("GET /api/eobs/{id}/file", s.requireAuth(s.handleGetEOBFile))
+	s.mux.HandleFunc("PUT /api/eobs/{id}/match", s.requireAuth(s.handleMatchEOB))
+	s.mux.HandleFunc("DELETE /api/eobs/{id}/match", s.requireAuth(s.handleUnmatchEOB))
+	s.mux.HandleFunc("GET /api/eobs/{id}", s.requireAuth(s.handleGetEOB))
+	s.mux.HandleFunc("DELETE /api/eobs/{id}", s.requireAuth(s.handleDeleteEOB))
+	s.mux.HandleFunc("GET /api/eobs", s.requireAuth(s.handleListEOBs))
+	s.mux.HandleFunc("POST /api/eobs/scan", s.requireAuth(s.handleScanEOB))
+
 	// Static HTML interface (register last as it's the catch-all)
 	s.mux.HandleFunc("GET /index.html", s.requireAuth(s.handleIndex))
 	s.mux.HandleFunc("GET /", s.requireAuth(s.handleIndex))
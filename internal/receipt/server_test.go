@@ -5,13 +5,16 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/ghttp"
+	"github.com/zombor/hsa-tracker/internal/scanning"
 )
 
 var _ = Describe("Server", func() {
@@ -111,6 +114,35 @@ var _ = Describe("Server", func() {
 			})
 		})
 
+		When("the receipt was uploaded before", func() {
+			BeforeEach(func() {
+				db := newMockDB()
+				db.receipts["earlier"] = &Receipt{ID: "earlier", Title: "Test Receipt", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Amount: 2599}
+				service = NewService(db, newMockScanner(), newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should list the earlier receipt as a likely duplicate", func() {
+				var b bytes.Buffer
+				writer := multipart.NewWriter(&b)
+				part, _ := writer.CreateFormFile("file", "test.jpg")
+				part.Write([]byte("fake image data"))
+				writer.Close()
+
+				resp, err := http.Post(ghttpServer.URL()+"/api/receipts/scan", writer.FormDataContentType(), &b)
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				var result struct {
+					Duplicates []Duplicate `json:"duplicates"`
+				}
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(body, &result)).NotTo(HaveOccurred())
+				Expect(result.Duplicates[0].ReceiptID).To(Equal("earlier"))
+			})
+		})
+
 		When("no receipts exist", func() {
 			BeforeEach(func() {
 				db := newMockDB()
@@ -212,6 +244,73 @@ var _ = Describe("Server", func() {
 			})
 		})
 
+		When("a document type is given", func() {
+			It("should scan the receipt as that type", func() {
+				var b bytes.Buffer
+				writer := multipart.NewWriter(&b)
+				writer.WriteField("document_type", "pharmacy")
+				part, _ := writer.CreateFormFile("file", "test.jpg")
+				part.Write([]byte("fake image data"))
+				writer.Close()
+
+				resp, err := http.Post(ghttpServer.URL()+"/api/receipts/scan", writer.FormDataContentType(), &b)
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				var receipt Receipt
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(body, &receipt)).NotTo(HaveOccurred())
+				Expect(receipt.DocumentType).To(Equal("pharmacy"))
+			})
+		})
+
+		When("the document type isn't a receipt type", func() {
+			It("should return status Bad Request", func() {
+				var b bytes.Buffer
+				writer := multipart.NewWriter(&b)
+				writer.WriteField("document_type", "eob")
+				part, _ := writer.CreateFormFile("file", "test.jpg")
+				part.Write([]byte("fake image data"))
+				writer.Close()
+
+				resp, err := http.Post(ghttpServer.URL()+"/api/receipts/scan", writer.FormDataContentType(), &b)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+
+		When("the scanner is out of quota", func() {
+			BeforeEach(func() {
+				scanner := newMockScanner()
+				scanner.scanErr = fmt.Errorf("generating content: %w", &scanning.APIError{
+					Provider:   "gemini",
+					StatusCode: http.StatusTooManyRequests,
+					RetryAfter: 42 * time.Second,
+				})
+				service = NewService(newMockDB(), scanner, newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should ask the client to retry later", func() {
+				var b bytes.Buffer
+				writer := multipart.NewWriter(&b)
+				part, _ := writer.CreateFormFile("file", "test.jpg")
+				part.Write([]byte("fake image data"))
+				writer.Close()
+
+				resp, err := http.Post(ghttpServer.URL()+"/api/receipts/scan", writer.FormDataContentType(), &b)
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+				Expect(resp.Header.Get("Retry-After")).To(Equal("42"))
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring("Please retry in 42s."))
+			})
+		})
+
 		When("upload succeeds with PNG file", func() {
 			It("should return status OK", func() {
 				var b bytes.Buffer
@@ -359,6 +458,19 @@ var _ = Describe("Server", func() {
 			})
 		})
 
+		When("the patient isn't a household member", func() {
+			It("should return status Bad Request", func() {
+				bodyBytes, _ := json.Marshal(&Receipt{ID: "test-id", Title: "Test Receipt", PatientID: "stranger"})
+				resp, err := http.Post(ghttpServer.URL()+"/api/receipts", "application/json", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring("unknown patient: stranger"))
+			})
+		})
+
 		When("invalid JSON body", func() {
 			It("should return status Bad Request", func() {
 				resp, err := http.Post(ghttpServer.URL()+"/api/receipts", "application/json", bytes.NewBufferString("invalid json"))
@@ -573,15 +685,15 @@ var _ = Describe("Server", func() {
 				resp.Body.Close()
 			})
 
-			It("should remove the receipt from the database", func() {
+			It("should move the receipt to the trash", func() {
 				req, err := http.NewRequest("DELETE", ghttpServer.URL()+"/api/receipts/test-id", nil)
 				Expect(err).NotTo(HaveOccurred())
 				resp, err := http.DefaultClient.Do(req)
 				Expect(err).NotTo(HaveOccurred())
 				resp.Body.Close()
-				// Verify deletion by attempting to get the receipt
-				_, getErr := service.GetReceipt("test-id")
-				Expect(getErr).To(HaveOccurred())
+				receipt, getErr := service.GetReceipt("test-id")
+				Expect(getErr).NotTo(HaveOccurred())
+				Expect(receipt.IsDeleted()).To(BeTrue())
 			})
 		})
 
@@ -854,6 +966,78 @@ var _ = Describe("Server", func() {
 			})
 		})
 
+		When("the receipt is not eligible for the account", func() {
+			BeforeEach(func() {
+				db := newMockDB()
+				db.receipts["receipt1"] = &Receipt{ID: "receipt1", Amount: 5000, Category: CategoryMedical}
+				db.accounts["fsa"] = &Account{ID: "fsa", Name: "Limited FSA", Type: AccountTypeLPFSA}
+				service = NewService(db, newMockScanner(), newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should return status Bad Request", func() {
+				bodyBytes, _ := json.Marshal(map[string]interface{}{
+					"account_id":  "fsa",
+					"receipt_ids": []string{"receipt1"},
+				})
+				resp, err := http.Post(ghttpServer.URL()+"/api/reimbursements", "application/json", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+
+		When("the receipt predates the HSA", func() {
+			BeforeEach(func() {
+				db := newMockDB()
+				db.receipts["receipt1"] = &Receipt{ID: "receipt1", Amount: 5000, Date: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)}
+				db.accounts["hsa"] = &Account{ID: "hsa", Name: "Family HSA", Type: AccountTypeHSA, EstablishedDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+				service = NewService(db, newMockScanner(), newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should explain the error in JSON", func() {
+				bodyBytes, _ := json.Marshal(map[string][]string{"receipt_ids": {"receipt1"}})
+				resp, err := http.Post(ghttpServer.URL()+"/api/reimbursements", "application/json", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+				var response map[string]string
+				respBody, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(respBody, &response)).NotTo(HaveOccurred())
+				Expect(response["error"]).To(ContainSubstring("before Family HSA was established on 2024-01-01"))
+			})
+		})
+
+		When("allocating a partial amount", func() {
+			BeforeEach(func() {
+				db := newMockDB()
+				db.receipts["receipt1"] = &Receipt{ID: "receipt1", Amount: 5000}
+				service = NewService(db, newMockScanner(), newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should return the allocated total", func() {
+				body := map[string][]Allocation{
+					"allocations": {{ReceiptID: "receipt1", Amount: 2000}},
+				}
+				bodyBytes, _ := json.Marshal(body)
+				resp, err := http.Post(ghttpServer.URL()+"/api/reimbursements", "application/json", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+				var reimbursement Reimbursement
+				respBody, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(respBody, &reimbursement)).NotTo(HaveOccurred())
+				Expect(reimbursement.TotalAmount).To(Equal(2000))
+			})
+		})
+
 		When("invalid JSON body", func() {
 			It("should return status Bad Request", func() {
 				resp, err := http.Post(ghttpServer.URL()+"/api/reimbursements", "application/json", bytes.NewBufferString("invalid json"))
@@ -924,12 +1108,81 @@ var _ = Describe("Server", func() {
 		})
 	})
 
+	Describe("handleUpdateReimbursement", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.reimbursements["test-id"] = &Reimbursement{ID: "test-id", TotalAmount: 2500}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		When("the payment details are valid", func() {
+			It("should return the updated reimbursement", func() {
+				body := `{"paid_date":"2024-01-12T00:00:00Z","payment_method":"check","reference":"1042"}`
+				req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/reimbursements/test-id", bytes.NewBufferString(body))
+				Expect(err).NotTo(HaveOccurred())
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				var reimbursement Reimbursement
+				data, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(data, &reimbursement)).NotTo(HaveOccurred())
+				Expect(reimbursement.PaymentMethod).To(Equal(PaymentCheck))
+			})
+		})
+
+		When("the payment method is invalid", func() {
+			It("should return status Bad Request", func() {
+				req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/reimbursements/test-id", bytes.NewBufferString(`{"payment_method":"cash"}`))
+				Expect(err).NotTo(HaveOccurred())
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+	})
+
+	Describe("handleTransitionReimbursement", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.reimbursements["test-id"] = &Reimbursement{ID: "test-id", ReceiptIDs: []string{}, Status: ReimbursementPaid}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		When("voiding a paid reimbursement", func() {
+			It("should return the voided reimbursement", func() {
+				resp, err := http.Post(ghttpServer.URL()+"/api/reimbursements/test-id/void", "application/json", bytes.NewBufferString(`{"note":"wrong receipts"}`))
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				var reimbursement Reimbursement
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(body, &reimbursement)).NotTo(HaveOccurred())
+				Expect(reimbursement.Status).To(Equal(ReimbursementVoided))
+			})
+		})
+
+		When("the transition isn't allowed", func() {
+			It("should return status Bad Request", func() {
+				resp, err := http.Post(ghttpServer.URL()+"/api/reimbursements/test-id/reject", "application/json", nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+	})
+
 	Describe("handleGetReimbursement", func() {
 		When("reimbursement exists", func() {
 			BeforeEach(func() {
 				db := newMockDB()
 				reimbursement := &Reimbursement{ID: "test-id"}
-				receipt := &Receipt{ID: "receipt1", ReimbursementID: "test-id"}
+				receipt := &Receipt{ID: "receipt1", Allocations: []Allocation{{ReimbursementID: "test-id", ReceiptID: "receipt1", Amount: 100}}}
 				db.reimbursements["test-id"] = reimbursement
 				db.receipts["receipt1"] = receipt
 				service = NewService(db, newMockScanner(), newMockStorage())
@@ -964,6 +1217,31 @@ var _ = Describe("Server", func() {
 			})
 		})
 
+		When("one of its receipts no longer exists", func() {
+			BeforeEach(func() {
+				db := newMockDB()
+				db.reimbursements["test-id"] = &Reimbursement{ID: "test-id", ReceiptIDs: []string{"receipt1", "purged"}}
+				db.receipts["receipt1"] = &Receipt{ID: "receipt1"}
+				service = NewService(db, newMockScanner(), newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should return the receipts it has and list the missing ones", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/reimbursements/test-id")
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				var response struct {
+					Receipts          []*Receipt `json:"receipts"`
+					MissingReceiptIDs []string   `json:"missing_receipt_ids"`
+				}
+				Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+				Expect(response.Receipts).To(HaveLen(1))
+				Expect(response.MissingReceiptIDs).To(Equal([]string{"purged"}))
+			})
+		})
+
 		When("reimbursement does not exist", func() {
 			It("should return status Not Found", func() {
 				resp, err := http.Get(ghttpServer.URL() + "/api/reimbursements/nonexistent")
@@ -1000,6 +1278,570 @@ var _ = Describe("Server", func() {
 		})
 	})
 
+	Describe("handleListReceipts with patient filter", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["id1"] = &Receipt{ID: "id1", PatientID: "member-1"}
+			db.receipts["id2"] = &Receipt{ID: "id2", PatientID: "member-2"}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		It("should only return the patient's receipts", func() {
+			resp, err := http.Get(ghttpServer.URL() + "/api/receipts?patient_id=member-1")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			var receipts []*Receipt
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(body, &receipts)).NotTo(HaveOccurred())
+			Expect(receipts).To(HaveLen(1))
+			Expect(receipts[0].ID).To(Equal("id1"))
+		})
+	})
+
+	Describe("handleUpdateReceipt", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["test-id"] = &Receipt{ID: "test-id", Title: "Test", Category: CategoryOther}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		When("overriding the category", func() {
+			It("should return the updated category", func() {
+				bodyBytes, _ := json.Marshal(&Receipt{Title: "Test", Category: CategoryDental})
+				req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/receipts/test-id", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				var updated Receipt
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(body, &updated)).NotTo(HaveOccurred())
+				Expect(updated.Category).To(Equal(CategoryDental))
+			})
+		})
+
+		When("the patient isn't a household member", func() {
+			It("should return status Bad Request", func() {
+				bodyBytes, _ := json.Marshal(&Receipt{Title: "Test", Category: CategoryOther, PatientID: "stranger"})
+				req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/receipts/test-id", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+
+		When("the category is invalid", func() {
+			It("should return status Bad Request", func() {
+				bodyBytes, _ := json.Marshal(&Receipt{Title: "Test", Category: "groceries"})
+				req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/receipts/test-id", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+	})
+
+	Describe("draft receipts", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["draft-id"] = &Receipt{ID: "draft-id", Title: "Scanned", Status: ReceiptDraft}
+			db.receipts["done-id"] = &Receipt{ID: "done-id", Title: "Reviewed", Status: ReceiptConfirmed}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		It("should list the drafts waiting for review", func() {
+			resp, err := http.Get(ghttpServer.URL() + "/api/receipts/drafts")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			var drafts []*Receipt
+			Expect(json.NewDecoder(resp.Body).Decode(&drafts)).To(Succeed())
+			Expect(drafts).To(ConsistOf(HaveField("ID", "draft-id")))
+		})
+
+		It("should confirm a draft", func() {
+			bodyBytes, _ := json.Marshal(&Receipt{Title: "Reviewed Title", Category: CategoryOther})
+			resp, err := http.Post(ghttpServer.URL()+"/api/receipts/draft-id/confirm", "application/json", bytes.NewBuffer(bodyBytes))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var confirmed Receipt
+			Expect(json.NewDecoder(resp.Body).Decode(&confirmed)).To(Succeed())
+			Expect(confirmed.Status).To(Equal(ReceiptConfirmed))
+		})
+
+		It("should return status Conflict when the receipt isn't a draft", func() {
+			bodyBytes, _ := json.Marshal(&Receipt{Title: "Reviewed", Category: CategoryOther})
+			resp, err := http.Post(ghttpServer.URL()+"/api/receipts/done-id/confirm", "application/json", bytes.NewBuffer(bodyBytes))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+	})
+
+	Describe("locked receipts", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["locked"] = &Receipt{
+				ID:          "locked",
+				Title:       "Pharmacy",
+				Amount:      2599,
+				Category:    CategoryOther,
+				Allocations: []Allocation{{ReimbursementID: "r1", ReceiptID: "locked", Amount: 2599}},
+			}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		It("should return status Conflict when changing the amount", func() {
+			bodyBytes, _ := json.Marshal(&Receipt{Title: "Pharmacy", Amount: 100, Category: CategoryOther})
+			req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/receipts/locked", bytes.NewBuffer(bodyBytes))
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+
+		It("should return status Conflict when deleting", func() {
+			req, err := http.NewRequest("DELETE", ghttpServer.URL()+"/api/receipts/locked", nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+
+		It("should unlock the receipt with a reason", func() {
+			resp, err := http.Post(ghttpServer.URL()+"/api/receipts/locked/unlock", "application/json", bytes.NewBufferString(`{"reason":"amount was misread"}`))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var unlocked Receipt
+			Expect(json.NewDecoder(resp.Body).Decode(&unlocked)).To(Succeed())
+			Expect(unlocked.Unlocked).To(BeTrue())
+		})
+
+		It("should return status Bad Request when unlocking without a reason", func() {
+			resp, err := http.Post(ghttpServer.URL()+"/api/receipts/locked/unlock", "application/json", bytes.NewBufferString(`{}`))
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("handleReceiptHistory", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["test-id"] = &Receipt{ID: "test-id", Title: "Test", Category: CategoryOther}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			Expect(service.WithActor("alice").UpdateReceipt(&Receipt{ID: "test-id", Title: "Edited", Category: CategoryOther})).To(Succeed())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		When("the receipt has history", func() {
+			It("should return its changes", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/receipts/test-id/history")
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				var events []*ReceiptEvent
+				Expect(json.NewDecoder(resp.Body).Decode(&events)).To(Succeed())
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Action).To(Equal(ActionUpdated))
+				Expect(events[0].Actor).To(Equal("alice"))
+				Expect(events[0].Before.Title).To(Equal("Test"))
+				Expect(events[0].After.Title).To(Equal("Edited"))
+			})
+		})
+
+		When("the receipt doesn't exist", func() {
+			It("should return status Not Found", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/receipts/missing/history")
+				Expect(err).NotTo(HaveOccurred())
+				resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		When("a signed-in user edits a receipt", func() {
+			BeforeEach(func() {
+				auth = BasicAuth{Username: "bob", Password: "secret"}
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should record them as the actor", func() {
+				bodyBytes, _ := json.Marshal(&Receipt{Title: "Edited again", Category: CategoryOther})
+				req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/receipts/test-id", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				req.SetBasicAuth("bob", "secret")
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				resp.Body.Close()
+				events, err := service.ReceiptHistory("test-id")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events[len(events)-1].Actor).To(Equal("bob"))
+			})
+		})
+	})
+
+	Describe("trash", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["trashed"] = &Receipt{ID: "trashed", Title: "Trashed", DeletedAt: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)}
+			db.receipts["active"] = &Receipt{ID: "active", Title: "Active"}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		It("should list the receipts in the trash", func() {
+			resp, err := http.Get(ghttpServer.URL() + "/api/trash")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			var trash []*Receipt
+			Expect(json.NewDecoder(resp.Body).Decode(&trash)).To(Succeed())
+			Expect(trash).To(ConsistOf(HaveField("ID", "trashed")))
+		})
+
+		It("should restore a receipt", func() {
+			resp, err := http.Post(ghttpServer.URL()+"/api/trash/trashed/restore", "application/json", nil)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			var restored Receipt
+			Expect(json.NewDecoder(resp.Body).Decode(&restored)).To(Succeed())
+			Expect(restored.IsDeleted()).To(BeFalse())
+		})
+
+		It("should purge a receipt", func() {
+			req, err := http.NewRequest("DELETE", ghttpServer.URL()+"/api/trash/trashed", nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+			_, getErr := service.GetReceipt("trashed")
+			Expect(getErr).To(HaveOccurred())
+		})
+
+		It("should return status Conflict when purging a receipt that isn't in the trash", func() {
+			req, err := http.NewRequest("DELETE", ghttpServer.URL()+"/api/trash/active", nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+	})
+
+	Describe("handleListReceipts with category filter", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["id1"] = &Receipt{ID: "id1", Category: CategoryPharmacy}
+			db.receipts["id2"] = &Receipt{ID: "id2", Category: CategoryDental}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		It("should only return receipts in the category", func() {
+			resp, err := http.Get(ghttpServer.URL() + "/api/receipts?category=dental")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			var receipts []*Receipt
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(body, &receipts)).NotTo(HaveOccurred())
+			Expect(receipts).To(HaveLen(1))
+			Expect(receipts[0].ID).To(Equal("id2"))
+		})
+	})
+
+	Describe("handleCreateMember", func() {
+		When("creation succeeds", func() {
+			It("should return status Created", func() {
+				bodyBytes, _ := json.Marshal(&Member{Name: "Alex", Relationship: "dependent"})
+				resp, err := http.Post(ghttpServer.URL()+"/api/members", "application/json", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+				resp.Body.Close()
+			})
+		})
+
+		When("the name is missing", func() {
+			It("should return error in JSON", func() {
+				bodyBytes, _ := json.Marshal(&Member{})
+				resp, err := http.Post(ghttpServer.URL()+"/api/members", "application/json", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				var response map[string]string
+				respBody, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(respBody, &response)).NotTo(HaveOccurred())
+				Expect(response["error"]).To(Equal("member name is required"))
+			})
+		})
+	})
+
+	Describe("handleGetMember", func() {
+		When("member does not exist", func() {
+			It("should return status Not Found", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/members/nonexistent")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+				resp.Body.Close()
+			})
+		})
+	})
+
+	Describe("handleDeleteMember", func() {
+		When("deletion succeeds", func() {
+			BeforeEach(func() {
+				db := newMockDB()
+				db.members["member-1"] = &Member{ID: "member-1", Name: "Alex"}
+				service = NewService(db, newMockScanner(), newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should return status No Content", func() {
+				req, err := http.NewRequest("DELETE", ghttpServer.URL()+"/api/members/member-1", nil)
+				Expect(err).NotTo(HaveOccurred())
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+				resp.Body.Close()
+			})
+		})
+	})
+
+	Describe("handleMemberTotals", func() {
+		When("year is invalid", func() {
+			It("should return status Bad Request", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/members/totals?year=abc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+
+		When("totals are requested", func() {
+			BeforeEach(func() {
+				db := newMockDB()
+				db.members["member-1"] = &Member{ID: "member-1", Name: "Alex"}
+				db.receipts["id1"] = &Receipt{ID: "id1", PatientID: "member-1", Amount: 1200}
+				service = NewService(db, newMockScanner(), newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should return the member totals", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/members/totals")
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				var totals []*MemberTotal
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(body, &totals)).NotTo(HaveOccurred())
+				Expect(totals).To(HaveLen(1))
+				Expect(totals[0].TotalAmount).To(Equal(1200))
+			})
+		})
+	})
+
+	Describe("handleCreateAccount", func() {
+		When("the account type is invalid", func() {
+			It("should return error in JSON", func() {
+				bodyBytes, _ := json.Marshal(&Account{Name: "Savings", Type: "ira"})
+				resp, err := http.Post(ghttpServer.URL()+"/api/accounts", "application/json", bytes.NewBuffer(bodyBytes))
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				var response map[string]string
+				respBody, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(respBody, &response)).NotTo(HaveOccurred())
+				Expect(response["error"]).To(Equal("invalid account type: ira"))
+			})
+		})
+	})
+
+	Describe("handleListReimbursements grouped by account", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.accounts["hsa"] = &Account{ID: "hsa", Name: "Family HSA", Type: AccountTypeHSA}
+			db.reimbursements["r1"] = &Reimbursement{ID: "r1", AccountID: "hsa", TotalAmount: 900}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		It("should return the account groups", func() {
+			resp, err := http.Get(ghttpServer.URL() + "/api/reimbursements?group_by=account")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			var groups []*AccountReimbursements
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(body, &groups)).NotTo(HaveOccurred())
+			Expect(groups).To(HaveLen(1))
+			Expect(groups[0].TotalAmount).To(Equal(900))
+		})
+	})
+
+	Describe("handleContributionSummary", func() {
+		When("year is invalid", func() {
+			It("should return status Bad Request", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/contributions/summary?year=abc")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+
+		When("a year is over the limit", func() {
+			BeforeEach(func() {
+				db := newMockDB()
+				db.accounts["hsa"] = &Account{ID: "hsa", Name: "Family HSA", Type: AccountTypeHSA, Coverage: CoverageSelfOnly}
+				db.contributions["c1"] = &Contribution{ID: "c1", AccountID: "hsa", Source: ContributionEmployee, Amount: 500000, TaxYear: 2024}
+				service = NewService(db, newMockScanner(), newMockStorage())
+				server = NewServerWithMux(service, auth, http.NewServeMux())
+				setupServer()
+			})
+
+			It("should flag the summary", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/contributions/summary?year=2024")
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				var summaries []*ContributionSummary
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(json.Unmarshal(body, &summaries)).NotTo(HaveOccurred())
+				Expect(summaries).To(HaveLen(1))
+				Expect(summaries[0].OverLimit).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("receipt attachments", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["receipt1"] = &Receipt{ID: "receipt1", Filename: "receipt1_bill.jpg", ContentType: "image/jpeg"}
+			storage := newMockStorage()
+			storage.files["receipt1_bill.jpg"] = []byte("fake image data")
+			service = NewService(db, newMockScanner(), storage)
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		It("should store an uploaded attachment", func() {
+			var b bytes.Buffer
+			writer := multipart.NewWriter(&b)
+			Expect(writer.WriteField("role", "eob")).To(Succeed())
+			part, _ := writer.CreateFormFile("file", "eob.pdf")
+			part.Write([]byte("fake pdf data"))
+			writer.Close()
+
+			resp, err := http.Post(ghttpServer.URL()+"/api/receipts/receipt1/attachments", writer.FormDataContentType(), &b)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var attachment Attachment
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(body, &attachment)).NotTo(HaveOccurred())
+			Expect(attachment.Role).To(Equal(AttachmentEOB))
+		})
+
+		It("should serve the file of the scanned receipt attachment", func() {
+			resp, err := http.Get(ghttpServer.URL() + "/api/receipts/receipt1/attachments/receipt1/file")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			data, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("fake image data"))
+		})
+
+		When("the attachment does not exist", func() {
+			It("should return status Not Found", func() {
+				resp, err := http.Get(ghttpServer.URL() + "/api/receipts/receipt1/attachments/missing/file")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+				resp.Body.Close()
+			})
+		})
+	})
+
+	Describe("explanations of benefits", func() {
+		BeforeEach(func() {
+			db := newMockDB()
+			db.receipts["bill"] = &Receipt{ID: "bill", Title: "Riverside Orthopedics", Date: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), Amount: 45000}
+			db.eobs["eob-1"] = &EOB{ID: "eob-1", PatientResponsibility: 6000}
+			service = NewService(db, newMockScanner(), newMockStorage())
+			server = NewServerWithMux(service, auth, http.NewServeMux())
+			setupServer()
+		})
+
+		It("should suggest matching receipts for a scanned eob", func() {
+			var b bytes.Buffer
+			writer := multipart.NewWriter(&b)
+			part, _ := writer.CreateFormFile("file", "eob.pdf")
+			part.Write([]byte("fake pdf data"))
+			writer.Close()
+
+			resp, err := http.Post(ghttpServer.URL()+"/api/eobs/scan", writer.FormDataContentType(), &b)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+			var result struct {
+				Matches []EOBMatch `json:"matches"`
+			}
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(body, &result)).NotTo(HaveOccurred())
+			Expect(result.Matches[0].ReceiptID).To(Equal("bill"))
+		})
+
+		It("should match an eob to a receipt", func() {
+			req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/eobs/eob-1/match", bytes.NewBufferString(`{"receipt_id":"bill"}`))
+			Expect(err).NotTo(HaveOccurred())
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			var receipt Receipt
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(body, &receipt)).NotTo(HaveOccurred())
+			Expect(receipt.PatientResponsibility).To(Equal(6000))
+		})
+
+		When("the receipt is missing from the match request", func() {
+			It("should return status Bad Request", func() {
+				req, err := http.NewRequest("PUT", ghttpServer.URL()+"/api/eobs/eob-1/match", bytes.NewBufferString(`{}`))
+				Expect(err).NotTo(HaveOccurred())
+				resp, err := http.DefaultClient.Do(req)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+				resp.Body.Close()
+			})
+		})
+	})
+
 	Describe("handleStaticCSS", func() {
 		When("request is GET", func() {
 			It("should return status OK", func() {
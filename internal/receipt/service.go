@@ -1,10 +1,17 @@
 package receipt
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -42,8 +49,12 @@ type Service struct {
 	storage     Storage
 	idGenerator IDGenerator
 	timeSource  TimeSource
+	actor       string // Recorded in receipt history as who made each change
 }
 
+// systemActor is the history actor for changes not made on behalf of a user
+const systemActor = "system"
+
 // NewService creates a new Service with default ID generator and time source
 func NewService(db DB, scanner scanning.Scanner, storage Storage) *Service {
 	return &Service{
@@ -52,6 +63,7 @@ func NewService(db DB, scanner scanning.Scanner, storage Storage) *Service {
 		storage:     storage,
 		idGenerator: &defaultIDGenerator{},
 		timeSource:  &defaultTimeSource{},
+		actor:       systemActor,
 	}
 }
 
@@ -63,7 +75,42 @@ func NewServiceWithDeps(db DB, scanner scanning.Scanner, storage Storage, idGen
 		storage:     storage,
 		idGenerator: idGen,
 		timeSource:  timeSrc,
+		actor:       systemActor,
+	}
+}
+
+// WithActor returns a copy of the service that records changes in receipt history as made by actor
+func (s *Service) WithActor(actor string) *Service {
+	actorService := *s
+	actorService.actor = actor
+	return &actorService
+}
+
+// recordHistory appends a change to the history of the receipt it's about
+// Snapshots are taken when the event is recorded, so before must not share memory the caller goes on to modify.
+func (s *Service) recordHistory(event *ReceiptEvent) error {
+	switch {
+	case event.After != nil:
+		event.ReceiptID = event.After.ID
+	case event.Before != nil:
+		event.ReceiptID = event.Before.ID
 	}
+	event.Actor = s.actor
+	event.At = s.timeSource.Now()
+	if err := s.db.AppendReceiptEvent(event); err != nil {
+		return fmt.Errorf("recording receipt history: %w", err)
+	}
+	return nil
+}
+
+// inTransaction runs fn with a copy of the service whose database calls all share one transaction
+// Everything fn saves is committed together, or not at all if it returns an error.
+func (s *Service) inTransaction(fn func(s *Service) error) error {
+	return s.db.Transaction(func(tx DB) error {
+		txService := *s
+		txService.db = tx
+		return fn(&txService)
+	})
 }
 
 // sanitizeFilename cleans up a filename by removing special characters and truncating length
@@ -97,8 +144,13 @@ func sanitizeFilename(filename string) string {
 	return base + ext
 }
 
-// ScanReceipt uploads a receipt, scans it, and returns the extracted data without saving to DB
-func (s *Service) ScanReceipt(filename string, data []byte, contentType string) (*Receipt, error) {
+// dollarsToCents converts a dollar amount from the scanner to whole cents
+func dollarsToCents(dollars float64) int {
+	return int(math.Round(dollars * 100))
+}
+
+// ScanReceipt uploads a receipt, scans it, and saves the extracted data as a draft for review
+func (s *Service) ScanReceipt(filename string, data []byte, contentType string, docType scanning.DocumentType) (*Receipt, error) {
 	// Generate unique ID
 	id := s.idGenerator.Generate()
 	now := s.timeSource.Now()
@@ -113,7 +165,7 @@ func (s *Service) ScanReceipt(filename string, data []byte, contentType string)
 	}
 
 	// Scan receipt
-	receiptData, err := s.scanner.ScanReceipt(data, contentType)
+	receiptData, err := s.scanner.ScanReceipt(data, contentType, docType)
 	if err != nil {
 		// Log the scanning error with details
 		slog.Error("Failed to scan receipt",
@@ -127,14 +179,34 @@ func (s *Service) ScanReceipt(filename string, data []byte, contentType string)
 		return nil, fmt.Errorf("scanning receipt: %w", err)
 	}
 
+	fields := receiptFields(receiptData.Fields)
+
 	// Parse date
 	date, err := time.Parse("2006-01-02", receiptData.Date)
 	if err != nil {
 		date = now
+		fields.Date = FieldConfidence{Provenance: ProvenanceDefaulted}
 	}
 
 	// Convert amount from dollars (float) to cents (int)
-	amountCents := int(receiptData.Amount * 100)
+	amountCents := dollarsToCents(receiptData.Amount)
+
+	var lineItems []LineItem
+	for _, item := range receiptData.LineItems {
+		lineItems = append(lineItems, LineItem{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			Amount:      dollarsToCents(item.Amount),
+			Eligible:    item.Eligible,
+		})
+	}
+
+	// Fall back to "other" when the scanner proposes something outside the taxonomy
+	category := Category(receiptData.Category)
+	if !category.Valid() {
+		category = CategoryOther
+		fields.Category = FieldConfidence{Provenance: ProvenanceDefaulted}
+	}
 
 	// Create receipt model
 	receipt := &Receipt{
@@ -144,13 +216,80 @@ func (s *Service) ScanReceipt(filename string, data []byte, contentType string)
 		Amount:      amountCents,
 		Filename:    savedPath,
 		ContentType: contentType,
+		Category:    category,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+
+		LineItems:         lineItems,
+		LineItemsMismatch: receiptData.LineItemsMismatch,
+		Fields:            fields,
+		NeedsReview:       fields.needsReview(),
+		DocumentType:      string(receiptData.DocumentType),
+		PromptVersion:     receiptData.PromptVersion,
+		RawText:           receiptData.RawText,
+	}
+	receipt.Attachments = []Attachment{receipt.primaryAttachment()}
+
+	// Fingerprint the file so later uploads of the same receipt can be recognized
+	contentHash := sha256.Sum256(data)
+	receipt.ContentHash = hex.EncodeToString(contentHash[:])
+	if imageHash, err := scanning.PerceptualHash(data, contentType); err != nil {
+		slog.Warn("Failed to compute perceptual hash", "filename", filename, "error", err)
+	} else {
+		receipt.ImageHash = strconv.FormatUint(imageHash, 16)
+	}
+
+	// Keep the scan as a draft until it's reviewed, so the file is never left unreferenced
+	receipt.Status = ReceiptDraft
+	err = s.inTransaction(func(s *Service) error {
+		if err := s.db.SaveReceipt(receipt); err != nil {
+			return fmt.Errorf("saving draft receipt to database: %w", err)
+		}
+		// Keep what the scanner extracted so it can be told apart from later edits
+		return s.recordHistory(&ReceiptEvent{Action: ActionScanned, After: receipt})
+	})
+	if err != nil {
+		s.storage.Delete(savedPath)
+		return nil, err
 	}
 
 	return receipt, nil
 }
 
+// receiptFields converts the scanner's field confidences to the receipt model
+func receiptFields(fields scanning.Fields) *ReceiptFields {
+	convert := func(field scanning.FieldConfidence) FieldConfidence {
+		return FieldConfidence{Provenance: Provenance(field.Provenance), Confidence: field.Confidence, Disputed: field.Disputed}
+	}
+	return &ReceiptFields{
+		Title:    convert(fields.Title),
+		Date:     convert(fields.Date),
+		Amount:   convert(fields.Amount),
+		Category: convert(fields.Category),
+	}
+}
+
+// FindDuplicates returns saved receipts that look like the same expense as the given receipt
+// Receipts match on an identical file, a visually similar image, or the same date and amount.
+// Drafts are compared too, since uploading the same receipt twice leaves two drafts to review.
+func (s *Service) FindDuplicates(receipt *Receipt) ([]*Duplicate, error) {
+	receipts, err := s.db.ListReceipts()
+	if err != nil {
+		return nil, fmt.Errorf("listing receipts: %w", err)
+	}
+
+	duplicates := make([]*Duplicate, 0)
+	for _, existing := range receipts {
+		if existing.ID == receipt.ID || existing.IsDeleted() {
+			continue
+		}
+		if duplicate, ok := receipt.duplicateOf(existing); ok {
+			duplicates = append(duplicates, duplicate)
+		}
+	}
+	return duplicates, nil
+}
+
 // CreateReceipt saves a receipt to the database
 func (s *Service) CreateReceipt(receipt *Receipt) error {
 	// Ensure timestamps are set
@@ -160,36 +299,152 @@ func (s *Service) CreateReceipt(receipt *Receipt) error {
 	}
 	receipt.UpdatedAt = now
 
-	// Save to database
-	if err := s.db.SaveReceipt(receipt); err != nil {
-		return fmt.Errorf("saving receipt to database: %w", err)
+	receipt.Status = ReceiptConfirmed
+
+	// A new receipt isn't reimbursed yet, so there's nothing to unlock or restore
+	receipt.Unlocked = false
+	receipt.DeletedAt = time.Time{}
+
+	// Receipts entered by hand have nothing scanned to review
+	receipt.Fields = nil
+	receipt.NeedsReview = false
+	receipt.DocumentType = ""
+	receipt.PromptVersion = ""
+	receipt.RawText = ""
+
+	// Allocations are only recorded by reimbursements
+	receipt.Allocations = nil
+	receipt.PredatesHSA = false
+	receipt.EOBID = ""
+	receipt.PatientResponsibility = 0
+
+	// A new receipt starts with just its scanned file; more are added with AddAttachment
+	receipt.Attachments = nil
+	if receipt.Filename != "" {
+		receipt.Attachments = []Attachment{receipt.primaryAttachment()}
 	}
 
-	return nil
+	if err := validateCategory(receipt.Category); err != nil {
+		return err
+	}
+	if err := s.validatePatient(receipt.PatientID); err != nil {
+		return err
+	}
+
+	return s.inTransaction(func(s *Service) error {
+		if err := s.db.SaveReceipt(receipt); err != nil {
+			return fmt.Errorf("saving receipt to database: %w", err)
+		}
+		return s.recordHistory(&ReceiptEvent{Action: ActionCreated, After: receipt})
+	})
 }
 
 // UpdateReceipt updates an existing receipt
 func (s *Service) UpdateReceipt(receipt *Receipt) error {
-	// Verify receipt exists
-	existing, err := s.db.GetReceipt(receipt.ID)
+	return s.inTransaction(func(s *Service) error {
+		// Verify receipt exists
+		existing, err := s.db.GetReceipt(receipt.ID)
+		if err != nil {
+			return fmt.Errorf("getting receipt: %w", err)
+		}
+
+		receipt.Status = existing.Status
+		return s.saveReviewed(receipt, existing, ActionUpdated)
+	})
+}
+
+// ConfirmReceipt saves the reviewed details of a draft receipt and moves it out of the review queue
+func (s *Service) ConfirmReceipt(receipt *Receipt) error {
+	return s.inTransaction(func(s *Service) error {
+		existing, err := s.db.GetReceipt(receipt.ID)
+		if err != nil {
+			return fmt.Errorf("getting receipt: %w", err)
+		}
+		if !existing.IsDraft() {
+			return fmt.Errorf("%w: %s", ErrNotDraft, receipt.ID)
+		}
+
+		receipt.Status = ReceiptConfirmed
+		return s.saveReviewed(receipt, existing, ActionConfirmed)
+	})
+}
+
+// ListDrafts returns scanned receipts waiting for review, newest first
+func (s *Service) ListDrafts() ([]*Receipt, error) {
+	receipts, err := s.db.ListReceipts()
 	if err != nil {
-		return fmt.Errorf("getting receipt: %w", err)
+		return nil, fmt.Errorf("listing receipts: %w", err)
+	}
+
+	drafts := make([]*Receipt, 0)
+	for _, receipt := range receipts {
+		if receipt.IsDraft() {
+			drafts = append(drafts, receipt)
+		}
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].CreatedAt.After(drafts[j].CreatedAt)
+	})
+	return drafts, nil
+}
+
+// saveReviewed saves user-editable receipt details over an existing receipt
+// Fields that are only changed through other operations are kept from existing, so it
+// must be called in the transaction existing was read in.
+func (s *Service) saveReviewed(receipt, existing *Receipt, action ReceiptAction) error {
+	if existing.IsDeleted() {
+		return fmt.Errorf("%w: %s", ErrReceiptDeleted, existing.ID)
 	}
+	if existing.IsLocked() {
+		if fields := existing.lockedChanges(receipt); len(fields) > 0 {
+			return fmt.Errorf("%w: %s can't change its %s", ErrReceiptLocked, existing.ID, strings.Join(fields, ", "))
+		}
+	}
+	// An unlock only allows a single change
+	receipt.Unlocked = false
 
 	// Preserve original CreatedAt and Filename
 	receipt.CreatedAt = existing.CreatedAt
 	receipt.Filename = existing.Filename
 	receipt.ContentType = existing.ContentType
 
+	// Receipts only move in and out of the trash through DeleteReceipt and RestoreReceipt
+	receipt.DeletedAt = existing.DeletedAt
+
+	// Allocations are only changed by reimbursements
+	receipt.Allocations = existing.Allocations
+	receipt.PredatesHSA = false
+
+	// Attachments are only changed through AddAttachment and RemoveAttachment
+	receipt.Attachments = existing.Attachments
+	receipt.ContentHash = existing.ContentHash
+	receipt.ImageHash = existing.ImageHash
+
+	// Details the person changed no longer need review
+	receipt.Fields = existing.Fields.reviewed(existing, receipt)
+	receipt.NeedsReview = receipt.Fields.needsReview()
+	receipt.DocumentType = existing.DocumentType
+	receipt.PromptVersion = existing.PromptVersion
+	receipt.RawText = existing.RawText
+
+	// The EOB link is only changed through MatchEOB and UnmatchEOB
+	receipt.EOBID = existing.EOBID
+	receipt.PatientResponsibility = existing.PatientResponsibility
+
+	if err := validateCategory(receipt.Category); err != nil {
+		return err
+	}
+	if err := s.validatePatient(receipt.PatientID); err != nil {
+		return err
+	}
+
 	// Update timestamp
 	receipt.UpdatedAt = s.timeSource.Now()
 
-	// Save to database
 	if err := s.db.SaveReceipt(receipt); err != nil {
 		return fmt.Errorf("saving receipt to database: %w", err)
 	}
-
-	return nil
+	return s.recordHistory(&ReceiptEvent{Action: action, Before: existing, After: receipt})
 }
 
 // GetReceipt retrieves a receipt by ID
@@ -198,139 +453,1478 @@ func (s *Service) GetReceipt(id string) (*Receipt, error) {
 	if err != nil {
 		return nil, fmt.Errorf("getting receipt: %w", err)
 	}
+	if err := s.flagPredatesHSA([]*Receipt{receipt}); err != nil {
+		return nil, err
+	}
 	return receipt, nil
 }
 
-// ListReceipts returns all receipts
-func (s *Service) ListReceipts() ([]*Receipt, error) {
-	receipts, err := s.db.ListReceipts()
+// earliestHSA returns the HSA with the earliest establishment date, or nil if none has one recorded
+func (s *Service) earliestHSA() (*Account, error) {
+	accounts, err := s.db.ListAccounts()
 	if err != nil {
-		return nil, fmt.Errorf("listing receipts: %w", err)
+		return nil, fmt.Errorf("listing accounts: %w", err)
+	}
+
+	var earliest *Account
+	for _, account := range accounts {
+		if account.Type != AccountTypeHSA || account.EstablishedDate.IsZero() {
+			continue
+		}
+		if earliest == nil || account.EstablishedDate.Before(earliest.EstablishedDate) {
+			earliest = account
+		}
 	}
-	return receipts, nil
+	return earliest, nil
 }
 
-// DeleteReceipt removes a receipt and its file
-func (s *Service) DeleteReceipt(id string) error {
-	receipt, err := s.db.GetReceipt(id)
+// flagPredatesHSA marks receipts incurred before any of the household's HSAs was established
+func (s *Service) flagPredatesHSA(receipts []*Receipt) error {
+	hsa, err := s.earliestHSA()
 	if err != nil {
-		return fmt.Errorf("getting receipt for deletion: %w", err)
+		return err
+	}
+	for _, receipt := range receipts {
+		receipt.PredatesHSA = hsa != nil && receipt.Date.Before(hsa.EstablishedDate)
 	}
+	return nil
+}
 
-	// Delete file
-	if err := s.storage.Delete(receipt.Filename); err != nil {
-		// Log error but continue with database deletion
-		slog.Warn("Failed to delete file", "filename", receipt.Filename, "error", err)
+// validateCategory ensures a receipt's category, if set, is part of the taxonomy
+func validateCategory(category Category) error {
+	if category == "" || category.Valid() {
+		return nil
 	}
+	return fmt.Errorf("%w: %s", ErrInvalidCategory, category)
+}
 
-	// Delete from database
-	if err := s.db.DeleteReceipt(id); err != nil {
-		return fmt.Errorf("deleting receipt from database: %w", err)
+// validatePatient ensures a receipt's patient, if set, is a known household member
+func (s *Service) validatePatient(patientID string) error {
+	if patientID == "" {
+		return nil
+	}
+	_, err := s.db.GetMember(patientID)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrUnknownPatient, patientID)
+	}
+	if err != nil {
+		return fmt.Errorf("getting patient %s: %w", patientID, err)
 	}
 	return nil
 }
 
-// GetReceiptFile retrieves the file data for a receipt
-func (s *Service) GetReceiptFile(id string) ([]byte, string, error) {
-	receipt, err := s.db.GetReceipt(id)
+// ListReceipts returns all receipts matching the filter
+func (s *Service) ListReceipts(filter ReceiptFilter) ([]*Receipt, error) {
+	receipts, err := s.db.ListReceipts()
 	if err != nil {
-		return nil, "", fmt.Errorf("getting receipt: %w", err)
+		return nil, fmt.Errorf("listing receipts: %w", err)
 	}
 
-	data, err := s.storage.Get(receipt.Filename)
+	filtered := make([]*Receipt, 0, len(receipts))
+	for _, receipt := range receipts {
+		// Drafts are listed separately until they're reviewed, and deleted receipts are in the trash
+		if receipt.IsDraft() || receipt.IsDeleted() {
+			continue
+		}
+		if filter.PatientID != "" && receipt.PatientID != filter.PatientID {
+			continue
+		}
+		if filter.Category != "" && receipt.Category != filter.Category {
+			continue
+		}
+		if filter.NeedsReview && !receipt.NeedsReview {
+			continue
+		}
+		filtered = append(filtered, receipt)
+	}
+	if err := s.flagPredatesHSA(filtered); err != nil {
+		return nil, err
+	}
+	return filtered, nil
+}
+
+// DeleteReceipt moves a receipt and its files to the trash, where it can be restored until it's purged
+// Drafts were never reviewed, so they're purged straight away instead.
+func (s *Service) DeleteReceipt(id string) error {
+	var (
+		receipt *Receipt
+		trashed bool
+	)
+	err := s.inTransaction(func(s *Service) error {
+		var err error
+		receipt, err = s.db.GetReceipt(id)
+		if err != nil {
+			return fmt.Errorf("getting receipt for deletion: %w", err)
+		}
+		if receipt.IsDraft() {
+			return s.purgeReceipt(receipt)
+		}
+		if receipt.IsDeleted() {
+			return nil
+		}
+		// Unlocking only allows a correction; a reimbursed receipt is kept to substantiate its reimbursement
+		if receipt.IsReimbursed() {
+			return fmt.Errorf("%w: %s can't be deleted", ErrReceiptLocked, id)
+		}
+
+		before := *receipt
+		now := s.timeSource.Now()
+		receipt.DeletedAt = now
+		receipt.UpdatedAt = now
+		if err := s.db.SaveReceipt(receipt); err != nil {
+			return fmt.Errorf("saving receipt to database: %w", err)
+		}
+		trashed = true
+		return s.recordHistory(&ReceiptEvent{Action: ActionDeleted, Before: &before, After: receipt})
+	})
 	if err != nil {
-		return nil, "", fmt.Errorf("getting receipt file: %w", err)
+		return err
 	}
 
-	return data, receipt.ContentType, nil
+	switch {
+	case receipt.IsDraft():
+		s.removeFiles(receipt)
+	case trashed:
+		for _, filename := range receipt.storedFiles() {
+			if err := s.storage.Trash(filename); err != nil {
+				// Log error; the receipt is already in the trash
+				slog.Warn("Failed to move file to trash", "filename", filename, "error", err)
+			}
+		}
+	}
+	return nil
 }
 
-// CreateReimbursement creates a new reimbursement and marks the specified receipts as reimbursed
-func (s *Service) CreateReimbursement(receiptIDs []string) (*Reimbursement, error) {
-	if len(receiptIDs) == 0 {
-		return nil, fmt.Errorf("at least one receipt is required")
+// UnlockReceipt allows one change to a reimbursed receipt, recording the reason in its history
+// The receipt locks again after its next update or attachment removal.
+func (s *Service) UnlockReceipt(id, reason string) (*Receipt, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to unlock a receipt")
 	}
 
-	now := s.timeSource.Now()
-	id := s.idGenerator.Generate()
-
-	// Validate all receipts exist and calculate total
-	var totalAmount int
-	for _, receiptID := range receiptIDs {
-		receipt, err := s.db.GetReceipt(receiptID)
+	var receipt *Receipt
+	err := s.inTransaction(func(s *Service) error {
+		var err error
+		receipt, err = s.db.GetReceipt(id)
 		if err != nil {
-			return nil, fmt.Errorf("getting receipt %s: %w", receiptID, err)
+			return fmt.Errorf("getting receipt: %w", err)
 		}
-		if receipt.ReimbursementID != "" {
-			return nil, fmt.Errorf("receipt %s is already reimbursed", receiptID)
+		if !receipt.IsLocked() {
+			return fmt.Errorf("receipt %s isn't locked", id)
+		}
+
+		before := *receipt
+		receipt.Unlocked = true
+		receipt.UpdatedAt = s.timeSource.Now()
+		if err := s.db.SaveReceipt(receipt); err != nil {
+			return fmt.Errorf("saving receipt to database: %w", err)
 		}
-		totalAmount += receipt.Amount
+		return s.recordHistory(&ReceiptEvent{Action: ActionUnlocked, Before: &before, After: receipt, Note: reason})
+	})
+	if err != nil {
+		return nil, err
 	}
+	return receipt, nil
+}
 
-	// Create reimbursement
-	reimbursement := &Reimbursement{
-		ID:          id,
-		ReceiptIDs:  receiptIDs,
-		TotalAmount: totalAmount,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+// ListTrash returns the receipts in the trash, most recently deleted first
+func (s *Service) ListTrash() ([]*Receipt, error) {
+	receipts, err := s.db.ListReceipts()
+	if err != nil {
+		return nil, fmt.Errorf("listing receipts: %w", err)
 	}
 
-	// Save reimbursement
-	if err := s.db.SaveReimbursement(reimbursement); err != nil {
-		return nil, fmt.Errorf("saving reimbursement: %w", err)
+	trash := make([]*Receipt, 0)
+	for _, receipt := range receipts {
+		if receipt.IsDeleted() {
+			trash = append(trash, receipt)
+		}
 	}
+	sort.Slice(trash, func(i, j int) bool {
+		return trash[i].DeletedAt.After(trash[j].DeletedAt)
+	})
+	return trash, nil
+}
 
-	// Mark receipts as reimbursed
-	for _, receiptID := range receiptIDs {
-		receipt, err := s.db.GetReceipt(receiptID)
+// RestoreReceipt takes a receipt and its files back out of the trash
+func (s *Service) RestoreReceipt(id string) (*Receipt, error) {
+	var receipt *Receipt
+	err := s.inTransaction(func(s *Service) error {
+		var err error
+		receipt, err = s.db.GetReceipt(id)
 		if err != nil {
-			return nil, fmt.Errorf("getting receipt %s for update: %w", receiptID, err)
+			return fmt.Errorf("getting receipt: %w", err)
 		}
-		receipt.ReimbursementID = id
-		receipt.UpdatedAt = now
+		if !receipt.IsDeleted() {
+			return fmt.Errorf("%w: %s", ErrNotInTrash, id)
+		}
+
+		before := *receipt
+		receipt.DeletedAt = time.Time{}
+		receipt.UpdatedAt = s.timeSource.Now()
 		if err := s.db.SaveReceipt(receipt); err != nil {
-			return nil, fmt.Errorf("updating receipt %s: %w", receiptID, err)
+			return fmt.Errorf("saving receipt to database: %w", err)
 		}
+		return s.recordHistory(&ReceiptEvent{Action: ActionRestored, Before: &before, After: receipt})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return reimbursement, nil
+	for _, filename := range receipt.storedFiles() {
+		if err := s.storage.Restore(filename); err != nil {
+			// Log error; the receipt is already restored
+			slog.Warn("Failed to restore file from trash", "filename", filename, "error", err)
+		}
+	}
+	return receipt, nil
 }
 
-// GetReimbursement retrieves a reimbursement by ID
-func (s *Service) GetReimbursement(id string) (*Reimbursement, error) {
-	reimbursement, err := s.db.GetReimbursement(id)
+// PurgeReceipt permanently removes a receipt in the trash and its files
+func (s *Service) PurgeReceipt(id string) error {
+	var receipt *Receipt
+	err := s.inTransaction(func(s *Service) error {
+		var err error
+		receipt, err = s.db.GetReceipt(id)
+		if err != nil {
+			return fmt.Errorf("getting receipt: %w", err)
+		}
+		if !receipt.IsDeleted() {
+			return fmt.Errorf("%w: %s", ErrNotInTrash, id)
+		}
+		return s.purgeReceipt(receipt)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("getting reimbursement: %w", err)
+		return err
 	}
-	return reimbursement, nil
+
+	s.removeFiles(receipt)
+	return nil
 }
 
-// GetReimbursementWithReceipts retrieves a reimbursement with its associated receipts
-func (s *Service) GetReimbursementWithReceipts(id string) (*Reimbursement, []*Receipt, error) {
-	reimbursement, err := s.db.GetReimbursement(id)
+// PurgeTrash permanently removes receipts that have been in the trash longer than retention
+// It returns how many receipts were purged.
+func (s *Service) PurgeTrash(retention time.Duration) (int, error) {
+	trash, err := s.ListTrash()
 	if err != nil {
-		return nil, nil, fmt.Errorf("getting reimbursement: %w", err)
+		return 0, err
 	}
 
-	// Get all receipts for this reimbursement
-	receipts := make([]*Receipt, 0, len(reimbursement.ReceiptIDs))
-	for _, receiptID := range reimbursement.ReceiptIDs {
-		receipt, err := s.db.GetReceipt(receiptID)
+	cutoff := s.timeSource.Now().Add(-retention)
+	purged := 0
+	for _, receipt := range trash {
+		if !receipt.DeletedAt.Before(cutoff) {
+			continue
+		}
+		err := s.PurgeReceipt(receipt.ID)
+		if errors.Is(err, ErrReceiptLocked) {
+			// Left in the trash until its reimbursement is voided
+			slog.Warn("Not purging receipt a reimbursement depends on", "receipt_id", receipt.ID, "error", err)
+			continue
+		}
+		if errors.Is(err, ErrNotInTrash) {
+			// Restored since the trash was listed
+			continue
+		}
 		if err != nil {
-			return nil, nil, fmt.Errorf("getting receipt %s: %w", receiptID, err)
+			return purged, fmt.Errorf("purging receipt %s: %w", receipt.ID, err)
 		}
-		receipts = append(receipts, receipt)
+		purged++
 	}
-
-	return reimbursement, receipts, nil
+	return purged, nil
 }
 
-// ListReimbursements returns all reimbursements
-func (s *Service) ListReimbursements() ([]*Reimbursement, error) {
+// purgeReceipt removes a receipt from the database, and must be called in a transaction
+// Its files are left for removeFiles once the transaction commits, so a failed delete doesn't
+// leave the receipt without them. Its history is kept as the record of what was removed.
+// Receipts a reimbursement still depends on are kept, so the reimbursement can be read,
+// voided and substantiated.
+func (s *Service) purgeReceipt(receipt *Receipt) error {
+	if receipt.IsReimbursed() {
+		return fmt.Errorf("%w: %s can't be purged", ErrReceiptLocked, receipt.ID)
+	}
 	reimbursements, err := s.db.ListReimbursements()
 	if err != nil {
-		return nil, fmt.Errorf("listing reimbursements: %w", err)
+		return fmt.Errorf("listing reimbursements: %w", err)
 	}
-	return reimbursements, nil
+	for _, reimbursement := range reimbursements {
+		if !reimbursement.Status.Releases() && slices.Contains(reimbursement.ReceiptIDs, receipt.ID) {
+			return fmt.Errorf("%w: %s is part of reimbursement %s", ErrReceiptLocked, receipt.ID, reimbursement.ID)
+		}
+	}
+
+	// A matched EOB stays on file for the next receipt it's matched to
+	if receipt.EOBID != "" {
+		if eob, err := s.db.GetEOB(receipt.EOBID); err == nil && eob.ReceiptID == receipt.ID {
+			eob.ReceiptID = ""
+			eob.UpdatedAt = s.timeSource.Now()
+			if err := s.db.SaveEOB(eob); err != nil {
+				return fmt.Errorf("unmatching eob: %w", err)
+			}
+		}
+	}
+
+	if err := s.db.DeleteReceipt(receipt.ID); err != nil {
+		return fmt.Errorf("deleting receipt from database: %w", err)
+	}
+	return s.recordHistory(&ReceiptEvent{Action: ActionPurged, Before: receipt})
+}
+
+// removeFiles deletes the files of a purged receipt from storage, or from the trash if it was deleted
+func (s *Service) removeFiles(receipt *Receipt) {
+	remove := s.storage.Delete
+	if receipt.IsDeleted() {
+		remove = s.storage.Purge
+	}
+	for _, filename := range receipt.storedFiles() {
+		if err := remove(filename); err != nil {
+			// Log error; the receipt is already deleted
+			slog.Warn("Failed to delete file", "filename", filename, "error", err)
+		}
+	}
+}
+
+// ReceiptHistory returns every recorded change to a receipt, oldest first
+// History outlives the receipt, so it can still be read after the receipt is deleted.
+func (s *Service) ReceiptHistory(id string) ([]*ReceiptEvent, error) {
+	events, err := s.db.ListReceiptHistory(id)
+	if err != nil {
+		return nil, fmt.Errorf("listing receipt history: %w", err)
+	}
+	// Receipts saved before history was recorded have none
+	if len(events) == 0 {
+		if _, err := s.db.GetReceipt(id); err != nil {
+			return nil, fmt.Errorf("getting receipt: %w", err)
+		}
+	}
+	return events, nil
+}
+
+// GetReceiptFile retrieves the file data for a receipt
+func (s *Service) GetReceiptFile(id string) ([]byte, string, error) {
+	receipt, err := s.db.GetReceipt(id)
+	if err != nil {
+		return nil, "", fmt.Errorf("getting receipt: %w", err)
+	}
+
+	data, err := s.storage.Get(receipt.Filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("getting receipt file: %w", err)
+	}
+
+	return data, receipt.ContentType, nil
+}
+
+// AddAttachment stores another document for a receipt
+func (s *Service) AddAttachment(receiptID string, role AttachmentRole, name string, data []byte, contentType string) (*Attachment, error) {
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid attachment role: %s", role)
+	}
+
+	receipt, err := s.db.GetReceipt(receiptID)
+	if err != nil {
+		return nil, fmt.Errorf("getting receipt: %w", err)
+	}
+	if receipt.IsDeleted() {
+		return nil, fmt.Errorf("%w: %s", ErrReceiptDeleted, receiptID)
+	}
+
+	id := s.idGenerator.Generate()
+	cleanName := sanitizeFilename(name)
+	savedPath, err := s.storage.Save(fmt.Sprintf("%s_%s_%s", receiptID, id, cleanName), data)
+	if err != nil {
+		return nil, fmt.Errorf("saving file: %w", err)
+	}
+
+	before := *receipt
+	now := s.timeSource.Now()
+	attachment := Attachment{
+		ID:          id,
+		Role:        role,
+		Name:        cleanName,
+		Filename:    savedPath,
+		ContentType: contentType,
+		CreatedAt:   now,
+	}
+	receipt.Attachments = append(receipt.Attachments, attachment)
+	receipt.UpdatedAt = now
+
+	err = s.inTransaction(func(s *Service) error {
+		if err := s.db.SaveReceipt(receipt); err != nil {
+			return fmt.Errorf("saving receipt to database: %w", err)
+		}
+		return s.recordHistory(&ReceiptEvent{Action: ActionUpdated, Before: &before, After: receipt})
+	})
+	if err != nil {
+		// Clean up the saved file since the receipt doesn't reference it
+		s.storage.Delete(savedPath)
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// RemoveAttachment deletes a document from a receipt
+// The scanned receipt file can only be removed by deleting the receipt.
+func (s *Service) RemoveAttachment(receiptID, attachmentID string) error {
+	receipt, err := s.db.GetReceipt(receiptID)
+	if err != nil {
+		return fmt.Errorf("getting receipt: %w", err)
+	}
+	if receipt.IsDeleted() {
+		return fmt.Errorf("%w: %s", ErrReceiptDeleted, receiptID)
+	}
+	if receipt.IsLocked() {
+		return fmt.Errorf("%w: %s can't have files removed", ErrReceiptLocked, receiptID)
+	}
+	attachment, ok := receipt.Attachment(attachmentID)
+	if !ok {
+		return fmt.Errorf("attachment not found: %s", attachmentID)
+	}
+	if attachment.Filename == receipt.Filename {
+		return fmt.Errorf("the scanned receipt file can't be removed")
+	}
+	filename := attachment.Filename
+	before := *receipt
+
+	attachments := make([]Attachment, 0, len(receipt.Attachments)-1)
+	for _, a := range receipt.Attachments {
+		if a.ID != attachmentID {
+			attachments = append(attachments, a)
+		}
+	}
+	receipt.Attachments = attachments
+	receipt.Unlocked = false
+	receipt.UpdatedAt = s.timeSource.Now()
+
+	err = s.inTransaction(func(s *Service) error {
+		if err := s.db.SaveReceipt(receipt); err != nil {
+			return fmt.Errorf("saving receipt to database: %w", err)
+		}
+		return s.recordHistory(&ReceiptEvent{Action: ActionUpdated, Before: &before, After: receipt})
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.storage.Delete(filename); err != nil {
+		// The receipt no longer references the file, so just log it
+		slog.Warn("Failed to delete file", "filename", filename, "error", err)
+	}
+	return nil
+}
+
+// ListAttachments returns the documents stored for a receipt
+func (s *Service) ListAttachments(receiptID string) ([]Attachment, error) {
+	receipt, err := s.db.GetReceipt(receiptID)
+	if err != nil {
+		return nil, fmt.Errorf("getting receipt: %w", err)
+	}
+	if len(receipt.Attachments) == 0 && receipt.Filename != "" {
+		return []Attachment{receipt.primaryAttachment()}, nil
+	}
+	return receipt.Attachments, nil
+}
+
+// GetAttachmentFile retrieves the file data of one of a receipt's attachments
+func (s *Service) GetAttachmentFile(receiptID, attachmentID string) ([]byte, string, error) {
+	attachments, err := s.ListAttachments(receiptID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, attachment := range attachments {
+		if attachment.ID != attachmentID {
+			continue
+		}
+		data, err := s.storage.Get(attachment.Filename)
+		if err != nil {
+			return nil, "", fmt.Errorf("getting attachment file: %w", err)
+		}
+		return data, attachment.ContentType, nil
+	}
+	return nil, "", fmt.Errorf("attachment not found: %s", attachmentID)
+}
+
+// CreateReimbursement creates a new reimbursement and allocates it against the specified receipts
+// An allocation with a zero Amount reimburses the receipt's full outstanding balance.
+// When accountID is set, every receipt must be eligible for that account.
+// The reimbursement and its receipts are saved in one transaction, so a receipt's
+// outstanding balance can't be claimed twice by concurrent requests.
+func (s *Service) CreateReimbursement(accountID string, status ReimbursementStatus, allocations []Allocation) (*Reimbursement, error) {
+	var reimbursement *Reimbursement
+	err := s.inTransaction(func(s *Service) error {
+		var err error
+		reimbursement, err = s.createReimbursement(accountID, status, allocations)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reimbursement, nil
+}
+
+// createReimbursement validates and records a reimbursement and its allocations
+func (s *Service) createReimbursement(accountID string, status ReimbursementStatus, allocations []Allocation) (*Reimbursement, error) {
+	if len(allocations) == 0 {
+		return nil, fmt.Errorf("at least one receipt is required")
+	}
+
+	// Reimbursements are recorded as paid unless they start earlier in the lifecycle
+	if status == "" {
+		status = ReimbursementPaid
+	}
+	switch status {
+	case ReimbursementDraft, ReimbursementRequested, ReimbursementPaid:
+	default:
+		return nil, fmt.Errorf("a reimbursement can't be created as %s", status)
+	}
+
+	now := s.timeSource.Now()
+	id := s.idGenerator.Generate()
+
+	var account, hsa *Account
+	if accountID != "" {
+		var err error
+		account, err = s.db.GetAccount(accountID)
+		if err != nil {
+			return nil, fmt.Errorf("getting account %s: %w", accountID, err)
+		}
+		if !account.ClaimDeadline.IsZero() && now.After(account.ClaimDeadline) {
+			return nil, fmt.Errorf("the claim deadline for account %s has passed", account.Name)
+		}
+	} else {
+		// Without a specific account, a receipt must not predate every HSA
+		var err error
+		hsa, err = s.earliestHSA()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Validate all receipts exist and have enough outstanding to cover their allocation
+	var totalAmount int
+	receiptIDs := make([]string, 0, len(allocations))
+	resolved := make([]Allocation, 0, len(allocations))
+	seen := make(map[string]bool, len(allocations))
+	for _, allocation := range allocations {
+		receiptID := allocation.ReceiptID
+		if seen[receiptID] {
+			return nil, fmt.Errorf("receipt %s is listed more than once", receiptID)
+		}
+		seen[receiptID] = true
+
+		receipt, err := s.db.GetReceipt(receiptID)
+		if err != nil {
+			return nil, fmt.Errorf("getting receipt %s: %w", receiptID, err)
+		}
+		if receipt.IsDraft() {
+			return nil, fmt.Errorf("receipt %s is a draft that hasn't been reviewed", receiptID)
+		}
+		if receipt.IsDeleted() {
+			return nil, fmt.Errorf("%w: %s", ErrReceiptDeleted, receiptID)
+		}
+		if account != nil {
+			if err := account.CheckEligible(receipt); err != nil {
+				return nil, err
+			}
+		} else if hsa != nil {
+			if err := hsa.checkEstablished(receipt); err != nil {
+				return nil, err
+			}
+		}
+
+		outstanding := receipt.OutstandingAmount()
+		if receipt.IsReimbursed() && outstanding == 0 {
+			return nil, fmt.Errorf("receipt %s is already fully reimbursed", receiptID)
+		}
+
+		amount := allocation.Amount
+		switch {
+		case amount == 0:
+			amount = outstanding
+		case amount < 0:
+			return nil, fmt.Errorf("reimbursement amount for receipt %s must be positive", receiptID)
+		case amount > outstanding:
+			return nil, fmt.Errorf("receipt %s only has %d cents outstanding", receiptID, outstanding)
+		}
+
+		receiptIDs = append(receiptIDs, receiptID)
+		resolved = append(resolved, Allocation{ReimbursementID: id, ReceiptID: receiptID, Amount: amount})
+		totalAmount += amount
+	}
+
+	// Create reimbursement
+	reimbursement := &Reimbursement{
+		ID:          id,
+		AccountID:   accountID,
+		ReceiptIDs:  receiptIDs,
+		Allocations: resolved,
+		TotalAmount: totalAmount,
+		Status:      status,
+		History:     []StatusChange{{To: status, At: now}},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if status == ReimbursementPaid {
+		reimbursement.PaidDate = now
+	}
+
+	// Save reimbursement
+	if err := s.db.SaveReimbursement(reimbursement); err != nil {
+		return nil, fmt.Errorf("saving reimbursement: %w", err)
+	}
+
+	// Record the allocation on each receipt
+	for _, allocation := range resolved {
+		receipt, err := s.db.GetReceipt(allocation.ReceiptID)
+		if err != nil {
+			return nil, fmt.Errorf("getting receipt %s for update: %w", allocation.ReceiptID, err)
+		}
+		before := *receipt
+		receipt.Allocations = append(receipt.Allocations, allocation)
+		receipt.UpdatedAt = now
+		if err := s.db.SaveReceipt(receipt); err != nil {
+			return nil, fmt.Errorf("updating receipt %s: %w", allocation.ReceiptID, err)
+		}
+		event := &ReceiptEvent{Action: ActionReimbursed, Before: &before, After: receipt, ReimbursementID: id}
+		if err := s.recordHistory(event); err != nil {
+			return nil, err
+		}
+	}
+
+	return reimbursement, nil
+}
+
+// GetReimbursement retrieves a reimbursement by ID
+func (s *Service) GetReimbursement(id string) (*Reimbursement, error) {
+	reimbursement, err := s.db.GetReimbursement(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting reimbursement: %w", err)
+	}
+	return reimbursement, nil
+}
+
+// TransitionReimbursement moves a reimbursement to a new status
+// Rejecting or voiding a reimbursement releases its amounts so the receipts are outstanding again;
+// the reimbursement itself is kept, with its history, as the record of what happened.
+func (s *Service) TransitionReimbursement(id string, status ReimbursementStatus, note string) (*Reimbursement, error) {
+	var reimbursement *Reimbursement
+	err := s.inTransaction(func(s *Service) error {
+		var err error
+		reimbursement, err = s.transitionReimbursement(id, status, note)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reimbursement, nil
+}
+
+// transitionReimbursement changes a reimbursement's status and releases its receipts if needed
+func (s *Service) transitionReimbursement(id string, status ReimbursementStatus, note string) (*Reimbursement, error) {
+	reimbursement, err := s.db.GetReimbursement(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting reimbursement: %w", err)
+	}
+
+	now := s.timeSource.Now()
+	if err := reimbursement.transition(status, note, now); err != nil {
+		return nil, err
+	}
+	if status == ReimbursementPaid && reimbursement.PaidDate.IsZero() {
+		reimbursement.PaidDate = now
+	}
+
+	if status.Releases() {
+		for _, receiptID := range reimbursement.ReceiptIDs {
+			receipt, err := s.db.GetReceipt(receiptID)
+			if errors.Is(err, ErrNotFound) {
+				// Nothing left to release
+				slog.Warn("Reimbursed receipt is missing", "reimbursement_id", reimbursement.ID, "receipt_id", receiptID)
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("getting receipt %s for update: %w", receiptID, err)
+			}
+			before := *receipt
+			allocations := make([]Allocation, 0, len(receipt.Allocations))
+			for _, allocation := range receipt.Allocations {
+				if allocation.ReimbursementID != reimbursement.ID {
+					allocations = append(allocations, allocation)
+				}
+			}
+			receipt.Allocations = allocations
+			receipt.UpdatedAt = now
+			if err := s.db.SaveReceipt(receipt); err != nil {
+				return nil, fmt.Errorf("updating receipt %s: %w", receiptID, err)
+			}
+			event := &ReceiptEvent{Action: ActionReleased, Before: &before, After: receipt, ReimbursementID: reimbursement.ID}
+			if err := s.recordHistory(event); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.db.SaveReimbursement(reimbursement); err != nil {
+		return nil, fmt.Errorf("saving reimbursement: %w", err)
+	}
+	return reimbursement, nil
+}
+
+// UpdateReimbursement records the payment details of an existing reimbursement
+// The receipts and amounts of a reimbursement can't be changed.
+func (s *Service) UpdateReimbursement(reimbursement *Reimbursement) error {
+	existing, err := s.db.GetReimbursement(reimbursement.ID)
+	if err != nil {
+		return fmt.Errorf("getting reimbursement: %w", err)
+	}
+
+	if reimbursement.PaymentMethod != "" && !reimbursement.PaymentMethod.Valid() {
+		return fmt.Errorf("invalid payment method: %s", reimbursement.PaymentMethod)
+	}
+
+	// Preserve everything except the payment details
+	reimbursement.AccountID = existing.AccountID
+	reimbursement.ReceiptIDs = existing.ReceiptIDs
+	reimbursement.Allocations = existing.Allocations
+	reimbursement.TotalAmount = existing.TotalAmount
+	reimbursement.Status = existing.Status
+	reimbursement.History = existing.History
+	reimbursement.CreatedAt = existing.CreatedAt
+	reimbursement.Reference = strings.TrimSpace(reimbursement.Reference)
+	reimbursement.UpdatedAt = s.timeSource.Now()
+
+	if err := s.db.SaveReimbursement(reimbursement); err != nil {
+		return fmt.Errorf("saving reimbursement: %w", err)
+	}
+	return nil
+}
+
+// GetReimbursementWithReceipts retrieves a reimbursement with its associated receipts
+// The IDs of receipts that no longer exist are returned as missing, so a damaged
+// reimbursement can still be viewed and voided.
+func (s *Service) GetReimbursementWithReceipts(id string) (*Reimbursement, []*Receipt, []string, error) {
+	reimbursement, err := s.db.GetReimbursement(id)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("getting reimbursement: %w", err)
+	}
+
+	// Get all receipts for this reimbursement
+	receipts := make([]*Receipt, 0, len(reimbursement.ReceiptIDs))
+	missing := make([]string, 0)
+	for _, receiptID := range reimbursement.ReceiptIDs {
+		receipt, err := s.db.GetReceipt(receiptID)
+		if errors.Is(err, ErrNotFound) {
+			missing = append(missing, receiptID)
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("getting receipt %s: %w", receiptID, err)
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return reimbursement, receipts, missing, nil
+}
+
+// ListReimbursements returns all reimbursements
+func (s *Service) ListReimbursements() ([]*Reimbursement, error) {
+	reimbursements, err := s.db.ListReimbursements()
+	if err != nil {
+		return nil, fmt.Errorf("listing reimbursements: %w", err)
+	}
+	return reimbursements, nil
+}
+
+// ListReimbursementsByAccount returns all reimbursements grouped by the account they were drawn from
+// Every account is included, even without reimbursements; reimbursements without an account come last.
+func (s *Service) ListReimbursementsByAccount() ([]*AccountReimbursements, error) {
+	accounts, err := s.db.ListAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("listing accounts: %w", err)
+	}
+	reimbursements, err := s.db.ListReimbursements()
+	if err != nil {
+		return nil, fmt.Errorf("listing reimbursements: %w", err)
+	}
+
+	groups := make([]*AccountReimbursements, 0, len(accounts)+1)
+	byAccount := make(map[string]*AccountReimbursements, len(accounts))
+	for _, account := range accounts {
+		group := &AccountReimbursements{Account: account, Reimbursements: []*Reimbursement{}}
+		groups = append(groups, group)
+		byAccount[account.ID] = group
+	}
+
+	var unassigned *AccountReimbursements
+	for _, reimbursement := range reimbursements {
+		group, ok := byAccount[reimbursement.AccountID]
+		if !ok {
+			if unassigned == nil {
+				unassigned = &AccountReimbursements{Reimbursements: []*Reimbursement{}}
+			}
+			group = unassigned
+		}
+		group.Reimbursements = append(group.Reimbursements, reimbursement)
+		if !reimbursement.Status.Releases() {
+			group.TotalAmount += reimbursement.TotalAmount
+		}
+	}
+	if unassigned != nil {
+		groups = append(groups, unassigned)
+	}
+
+	return groups, nil
+}
+
+// CreateMember adds a new household member
+func (s *Service) CreateMember(member *Member) error {
+	member.Name = strings.TrimSpace(member.Name)
+	if member.Name == "" {
+		return fmt.Errorf("member name is required")
+	}
+
+	now := s.timeSource.Now()
+	member.ID = s.idGenerator.Generate()
+	member.CreatedAt = now
+	member.UpdatedAt = now
+
+	if err := s.db.SaveMember(member); err != nil {
+		return fmt.Errorf("saving member: %w", err)
+	}
+	return nil
+}
+
+// UpdateMember updates an existing household member
+func (s *Service) UpdateMember(member *Member) error {
+	existing, err := s.db.GetMember(member.ID)
+	if err != nil {
+		return fmt.Errorf("getting member: %w", err)
+	}
+
+	member.Name = strings.TrimSpace(member.Name)
+	if member.Name == "" {
+		return fmt.Errorf("member name is required")
+	}
+
+	member.CreatedAt = existing.CreatedAt
+	member.UpdatedAt = s.timeSource.Now()
+
+	if err := s.db.SaveMember(member); err != nil {
+		return fmt.Errorf("saving member: %w", err)
+	}
+	return nil
+}
+
+// GetMember retrieves a household member by ID
+func (s *Service) GetMember(id string) (*Member, error) {
+	member, err := s.db.GetMember(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting member: %w", err)
+	}
+	return member, nil
+}
+
+// ListMembers returns all household members
+func (s *Service) ListMembers() ([]*Member, error) {
+	members, err := s.db.ListMembers()
+	if err != nil {
+		return nil, fmt.Errorf("listing members: %w", err)
+	}
+	return members, nil
+}
+
+// DeleteMember removes a household member that has no receipts assigned
+func (s *Service) DeleteMember(id string) error {
+	if _, err := s.db.GetMember(id); err != nil {
+		return fmt.Errorf("getting member for deletion: %w", err)
+	}
+
+	receipts, err := s.db.ListReceipts()
+	if err != nil {
+		return fmt.Errorf("listing receipts: %w", err)
+	}
+	for _, receipt := range receipts {
+		if receipt.PatientID == id {
+			return fmt.Errorf("member %s still has receipts assigned", id)
+		}
+	}
+
+	if err := s.db.DeleteMember(id); err != nil {
+		return fmt.Errorf("deleting member from database: %w", err)
+	}
+	return nil
+}
+
+// MemberTotals sums receipt amounts per household member
+// A year of 0 includes receipts from all years. Receipts without a patient are
+// reported under an entry with an empty MemberID.
+func (s *Service) MemberTotals(year int) ([]*MemberTotal, error) {
+	members, err := s.db.ListMembers()
+	if err != nil {
+		return nil, fmt.Errorf("listing members: %w", err)
+	}
+	receipts, err := s.db.ListReceipts()
+	if err != nil {
+		return nil, fmt.Errorf("listing receipts: %w", err)
+	}
+
+	totals := make([]*MemberTotal, 0, len(members)+1)
+	byMember := make(map[string]*MemberTotal, len(members))
+	for _, member := range members {
+		total := &MemberTotal{MemberID: member.ID, Name: member.Name}
+		totals = append(totals, total)
+		byMember[member.ID] = total
+	}
+
+	for _, receipt := range receipts {
+		if receipt.IsDraft() || receipt.IsDeleted() || (year != 0 && receipt.Date.Year() != year) {
+			continue
+		}
+		total, ok := byMember[receipt.PatientID]
+		if !ok {
+			total = &MemberTotal{MemberID: receipt.PatientID, Name: "Unassigned"}
+			totals = append(totals, total)
+			byMember[receipt.PatientID] = total
+		}
+		total.ReceiptCount++
+		total.TotalAmount += receipt.Amount
+	}
+
+	return totals, nil
+}
+
+// validateAccount checks the fields of an account before it is saved
+func (s *Service) validateAccount(account *Account) error {
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Name == "" {
+		return fmt.Errorf("account name is required")
+	}
+	if !account.Type.Valid() {
+		return fmt.Errorf("invalid account type: %s", account.Type)
+	}
+	if !account.PlanYearStart.IsZero() && !account.PlanYearEnd.IsZero() && account.PlanYearEnd.Before(account.PlanYearStart) {
+		return fmt.Errorf("plan year end must not be before plan year start")
+	}
+	if !account.EstablishedDate.IsZero() && account.Type != AccountTypeHSA {
+		return fmt.Errorf("an establishment date only applies to hsa accounts")
+	}
+	if account.Coverage != "" && !account.Coverage.Valid() {
+		return fmt.Errorf("invalid coverage type: %s", account.Coverage)
+	}
+	if account.OwnerID != "" {
+		if _, err := s.db.GetMember(account.OwnerID); err != nil {
+			return fmt.Errorf("getting owner %s: %w", account.OwnerID, err)
+		}
+	}
+	return nil
+}
+
+// CreateAccount adds a new benefit account
+func (s *Service) CreateAccount(account *Account) error {
+	if err := s.validateAccount(account); err != nil {
+		return err
+	}
+
+	now := s.timeSource.Now()
+	account.ID = s.idGenerator.Generate()
+	account.CreatedAt = now
+	account.UpdatedAt = now
+
+	if err := s.db.SaveAccount(account); err != nil {
+		return fmt.Errorf("saving account: %w", err)
+	}
+	return nil
+}
+
+// UpdateAccount updates an existing benefit account
+func (s *Service) UpdateAccount(account *Account) error {
+	existing, err := s.db.GetAccount(account.ID)
+	if err != nil {
+		return fmt.Errorf("getting account: %w", err)
+	}
+	if err := s.validateAccount(account); err != nil {
+		return err
+	}
+
+	account.CreatedAt = existing.CreatedAt
+	account.UpdatedAt = s.timeSource.Now()
+
+	if err := s.db.SaveAccount(account); err != nil {
+		return fmt.Errorf("saving account: %w", err)
+	}
+	return nil
+}
+
+// GetAccount retrieves a benefit account by ID
+func (s *Service) GetAccount(id string) (*Account, error) {
+	account, err := s.db.GetAccount(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting account: %w", err)
+	}
+	return account, nil
+}
+
+// ListAccounts returns all benefit accounts
+func (s *Service) ListAccounts() ([]*Account, error) {
+	accounts, err := s.db.ListAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("listing accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// DeleteAccount removes a benefit account that no reimbursement was drawn from
+func (s *Service) DeleteAccount(id string) error {
+	if _, err := s.db.GetAccount(id); err != nil {
+		return fmt.Errorf("getting account for deletion: %w", err)
+	}
+
+	reimbursements, err := s.db.ListReimbursements()
+	if err != nil {
+		return fmt.Errorf("listing reimbursements: %w", err)
+	}
+	for _, reimbursement := range reimbursements {
+		if reimbursement.AccountID == id {
+			return fmt.Errorf("account %s still has reimbursements", id)
+		}
+	}
+
+	contributions, err := s.db.ListContributions()
+	if err != nil {
+		return fmt.Errorf("listing contributions: %w", err)
+	}
+	for _, contribution := range contributions {
+		if contribution.AccountID == id {
+			return fmt.Errorf("account %s still has contributions", id)
+		}
+	}
+
+	if err := s.db.DeleteAccount(id); err != nil {
+		return fmt.Errorf("deleting account from database: %w", err)
+	}
+	return nil
+}
+
+// validateContribution checks the fields of a contribution before it is saved
+// A zero TaxYear defaults to the year of the deposit.
+func (s *Service) validateContribution(contribution *Contribution) error {
+	if contribution.AccountID == "" {
+		return fmt.Errorf("account is required")
+	}
+	account, err := s.db.GetAccount(contribution.AccountID)
+	if err != nil {
+		return fmt.Errorf("getting account %s: %w", contribution.AccountID, err)
+	}
+	if account.Type != AccountTypeHSA {
+		return fmt.Errorf("contributions can only be recorded for hsa accounts, not %s", account.Type)
+	}
+	if !contribution.Source.Valid() {
+		return fmt.Errorf("invalid contribution source: %s", contribution.Source)
+	}
+	if contribution.Amount <= 0 {
+		return fmt.Errorf("contribution amount must be positive")
+	}
+	if contribution.Date.IsZero() {
+		return fmt.Errorf("contribution date is required")
+	}
+
+	// Contributions for the prior tax year may be made until the filing deadline
+	if contribution.TaxYear == 0 {
+		contribution.TaxYear = contribution.Date.Year()
+	}
+	if contribution.TaxYear != contribution.Date.Year() && contribution.TaxYear != contribution.Date.Year()-1 {
+		return fmt.Errorf("a contribution made on %s can't count toward tax year %d", contribution.Date.Format("2006-01-02"), contribution.TaxYear)
+	}
+	return nil
+}
+
+// CreateContribution records a new HSA contribution
+func (s *Service) CreateContribution(contribution *Contribution) error {
+	if err := s.validateContribution(contribution); err != nil {
+		return err
+	}
+
+	now := s.timeSource.Now()
+	contribution.ID = s.idGenerator.Generate()
+	contribution.CreatedAt = now
+	contribution.UpdatedAt = now
+
+	if err := s.db.SaveContribution(contribution); err != nil {
+		return fmt.Errorf("saving contribution: %w", err)
+	}
+	return nil
+}
+
+// UpdateContribution updates an existing HSA contribution
+func (s *Service) UpdateContribution(contribution *Contribution) error {
+	existing, err := s.db.GetContribution(contribution.ID)
+	if err != nil {
+		return fmt.Errorf("getting contribution: %w", err)
+	}
+	if err := s.validateContribution(contribution); err != nil {
+		return err
+	}
+
+	contribution.CreatedAt = existing.CreatedAt
+	contribution.UpdatedAt = s.timeSource.Now()
+
+	if err := s.db.SaveContribution(contribution); err != nil {
+		return fmt.Errorf("saving contribution: %w", err)
+	}
+	return nil
+}
+
+// GetContribution retrieves an HSA contribution by ID
+func (s *Service) GetContribution(id string) (*Contribution, error) {
+	contribution, err := s.db.GetContribution(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting contribution: %w", err)
+	}
+	return contribution, nil
+}
+
+// ListContributions returns all HSA contributions, optionally limited to a single tax year
+func (s *Service) ListContributions(taxYear int) ([]*Contribution, error) {
+	contributions, err := s.db.ListContributions()
+	if err != nil {
+		return nil, fmt.Errorf("listing contributions: %w", err)
+	}
+	if taxYear == 0 {
+		return contributions, nil
+	}
+
+	filtered := make([]*Contribution, 0, len(contributions))
+	for _, contribution := range contributions {
+		if contribution.TaxYear == taxYear {
+			filtered = append(filtered, contribution)
+		}
+	}
+	return filtered, nil
+}
+
+// DeleteContribution removes an HSA contribution
+func (s *Service) DeleteContribution(id string) error {
+	if _, err := s.db.GetContribution(id); err != nil {
+		return fmt.Errorf("getting contribution for deletion: %w", err)
+	}
+	if err := s.db.DeleteContribution(id); err != nil {
+		return fmt.Errorf("deleting contribution from database: %w", err)
+	}
+	return nil
+}
+
+// ContributionSummaries totals contributions per account and tax year and checks them against the IRS limits
+// A zero taxYear summarizes every year that has contributions.
+func (s *Service) ContributionSummaries(taxYear int) ([]*ContributionSummary, error) {
+	contributions, err := s.ListContributions(taxYear)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.db.ListAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("listing accounts: %w", err)
+	}
+	members, err := s.db.ListMembers()
+	if err != nil {
+		return nil, fmt.Errorf("listing members: %w", err)
+	}
+
+	accountsByID := make(map[string]*Account, len(accounts))
+	for _, account := range accounts {
+		accountsByID[account.ID] = account
+	}
+	birthDates := make(map[string]time.Time, len(members))
+	for _, member := range members {
+		birthDates[member.ID] = member.BirthDate
+	}
+
+	type summaryKey struct {
+		accountID string
+		taxYear   int
+	}
+	summaries := make([]*ContributionSummary, 0)
+	byKey := make(map[summaryKey]*ContributionSummary)
+	for _, contribution := range contributions {
+		key := summaryKey{accountID: contribution.AccountID, taxYear: contribution.TaxYear}
+		summary, ok := byKey[key]
+		if !ok {
+			summary = &ContributionSummary{TaxYear: contribution.TaxYear, AccountID: contribution.AccountID}
+			if account, ok := accountsByID[contribution.AccountID]; ok {
+				summary.AccountName = account.Name
+				summary.Coverage = account.Coverage
+				summary.CatchUpEligible = CatchUpEligible(birthDates[account.OwnerID], contribution.TaxYear)
+			}
+			summaries = append(summaries, summary)
+			byKey[key] = summary
+		}
+		summary.add(contribution)
+	}
+
+	for _, summary := range summaries {
+		summary.checkLimit()
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].TaxYear != summaries[j].TaxYear {
+			return summaries[i].TaxYear > summaries[j].TaxYear
+		}
+		return summaries[i].AccountName < summaries[j].AccountName
+	})
+
+	return summaries, nil
+}
+
+// ScanEOB stores and scans an insurance explanation of benefits
+// The EOB is saved unmatched; use SuggestEOBMatches and MatchEOB to link it to a receipt.
+func (s *Service) ScanEOB(filename string, data []byte, contentType string) (*EOB, error) {
+	id := s.idGenerator.Generate()
+	now := s.timeSource.Now()
+
+	savedPath, err := s.storage.Save(fmt.Sprintf("eob_%s_%s", id, sanitizeFilename(filename)), data)
+	if err != nil {
+		return nil, fmt.Errorf("saving file: %w", err)
+	}
+
+	eobData, err := s.scanner.ScanEOB(data, contentType)
+	if err != nil {
+		slog.Error("Failed to scan EOB",
+			"filename", filename,
+			"content_type", contentType,
+			"file_size", len(data),
+			"error", err,
+		)
+		s.storage.Delete(savedPath)
+		return nil, fmt.Errorf("scanning eob: %w", err)
+	}
+
+	// Leave the service date unset rather than guessing; it's only used for matching
+	var serviceDate time.Time
+	if eobData.ServiceDate != "" {
+		serviceDate, _ = time.Parse("2006-01-02", eobData.ServiceDate)
+	}
+
+	eob := &EOB{
+		ID:                    id,
+		Provider:              eobData.Provider,
+		ServiceDate:           serviceDate,
+		ClaimNumber:           eobData.ClaimNumber,
+		BilledAmount:          dollarsToCents(eobData.Billed),
+		AllowedAmount:         dollarsToCents(eobData.Allowed),
+		InsurerPaidAmount:     dollarsToCents(eobData.InsurerPaid),
+		PatientResponsibility: dollarsToCents(eobData.PatientResponsibility),
+		AmountsMismatch:       eobData.AmountsMismatch,
+		Filename:              savedPath,
+		ContentType:           contentType,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+
+	if err := s.db.SaveEOB(eob); err != nil {
+		s.storage.Delete(savedPath)
+		return nil, fmt.Errorf("saving eob to database: %w", err)
+	}
+	return eob, nil
+}
+
+// GetEOB retrieves an explanation of benefits by ID
+func (s *Service) GetEOB(id string) (*EOB, error) {
+	eob, err := s.db.GetEOB(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting eob: %w", err)
+	}
+	return eob, nil
+}
+
+// ListEOBs returns all explanations of benefits, newest service date first
+func (s *Service) ListEOBs() ([]*EOB, error) {
+	eobs, err := s.db.ListEOBs()
+	if err != nil {
+		return nil, fmt.Errorf("listing eobs: %w", err)
+	}
+	sort.Slice(eobs, func(i, j int) bool {
+		return eobs[i].ServiceDate.After(eobs[j].ServiceDate)
+	})
+	return eobs, nil
+}
+
+// GetEOBFile retrieves the scanned file for an explanation of benefits
+func (s *Service) GetEOBFile(id string) ([]byte, string, error) {
+	eob, err := s.db.GetEOB(id)
+	if err != nil {
+		return nil, "", fmt.Errorf("getting eob: %w", err)
+	}
+
+	data, err := s.storage.Get(eob.Filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("getting eob file: %w", err)
+	}
+	return data, eob.ContentType, nil
+}
+
+// SuggestEOBMatches returns the receipts most likely to be the bill an EOB covers, best match first
+// Receipts already matched to a different EOB are skipped.
+func (s *Service) SuggestEOBMatches(eobID string) ([]*EOBMatch, error) {
+	eob, err := s.db.GetEOB(eobID)
+	if err != nil {
+		return nil, fmt.Errorf("getting eob: %w", err)
+	}
+	receipts, err := s.db.ListReceipts()
+	if err != nil {
+		return nil, fmt.Errorf("listing receipts: %w", err)
+	}
+
+	matches := make([]*EOBMatch, 0)
+	for _, receipt := range receipts {
+		if receipt.IsDraft() || receipt.IsDeleted() || (receipt.EOBID != "" && receipt.EOBID != eob.ID) {
+			continue
+		}
+		if match := eob.match(receipt); match.Score >= minEOBMatchScore {
+			matches = append(matches, match)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Date.After(matches[j].Date)
+	})
+	if len(matches) > maxEOBMatches {
+		matches = matches[:maxEOBMatches]
+	}
+	return matches, nil
+}
+
+// MatchEOB links an explanation of benefits to the receipt for the same claim
+// The receipt's reimbursable amount becomes the EOB's patient responsibility.
+func (s *Service) MatchEOB(eobID, receiptID string) (*Receipt, error) {
+	var receipt *Receipt
+	err := s.inTransaction(func(s *Service) error {
+		var err error
+		receipt, err = s.matchEOB(eobID, receiptID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// matchEOB links the EOB and receipt, unlinking any receipt the EOB was matched to before
+func (s *Service) matchEOB(eobID, receiptID string) (*Receipt, error) {
+	eob, err := s.db.GetEOB(eobID)
+	if err != nil {
+		return nil, fmt.Errorf("getting eob: %w", err)
+	}
+	receipt, err := s.db.GetReceipt(receiptID)
+	if err != nil {
+		return nil, fmt.Errorf("getting receipt: %w", err)
+	}
+	if receipt.IsDeleted() {
+		return nil, fmt.Errorf("%w: %s", ErrReceiptDeleted, receipt.ID)
+	}
+	if receipt.EOBID != "" && receipt.EOBID != eob.ID {
+		return nil, fmt.Errorf("receipt %s is already matched to eob %s", receipt.ID, receipt.EOBID)
+	}
+	if receipt.ReimbursedAmount() > eob.PatientResponsibility {
+		return nil, fmt.Errorf("receipt %s has been reimbursed %d cents, more than the %d cents patient responsibility",
+			receipt.ID, receipt.ReimbursedAmount(), eob.PatientResponsibility)
+	}
+
+	now := s.timeSource.Now()
+	if eob.ReceiptID != "" && eob.ReceiptID != receipt.ID {
+		if err := s.unlinkEOBReceipt(eob.ReceiptID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	before := *receipt
+	receipt.EOBID = eob.ID
+	receipt.PatientResponsibility = eob.PatientResponsibility
+	receipt.UpdatedAt = now
+	if err := s.db.SaveReceipt(receipt); err != nil {
+		return nil, fmt.Errorf("saving receipt to database: %w", err)
+	}
+	if err := s.recordHistory(&ReceiptEvent{Action: ActionUpdated, Before: &before, After: receipt}); err != nil {
+		return nil, err
+	}
+
+	eob.ReceiptID = receipt.ID
+	eob.UpdatedAt = now
+	if err := s.db.SaveEOB(eob); err != nil {
+		return nil, fmt.Errorf("saving eob to database: %w", err)
+	}
+	return receipt, nil
+}
+
+// UnmatchEOB removes the link between an explanation of benefits and its receipt
+func (s *Service) UnmatchEOB(eobID string) error {
+	return s.inTransaction(func(s *Service) error {
+		return s.unmatchEOB(eobID)
+	})
+}
+
+// unmatchEOB clears the link on both the EOB and its receipt
+func (s *Service) unmatchEOB(eobID string) error {
+	eob, err := s.db.GetEOB(eobID)
+	if err != nil {
+		return fmt.Errorf("getting eob: %w", err)
+	}
+	if eob.ReceiptID == "" {
+		return nil
+	}
+
+	now := s.timeSource.Now()
+	if err := s.unlinkEOBReceipt(eob.ReceiptID, now); err != nil {
+		return err
+	}
+
+	eob.ReceiptID = ""
+	eob.UpdatedAt = now
+	if err := s.db.SaveEOB(eob); err != nil {
+		return fmt.Errorf("saving eob to database: %w", err)
+	}
+	return nil
+}
+
+// unlinkEOBReceipt clears the EOB details from a receipt so its full amount is reimbursable again
+func (s *Service) unlinkEOBReceipt(receiptID string, now time.Time) error {
+	receipt, err := s.db.GetReceipt(receiptID)
+	if err != nil {
+		return fmt.Errorf("getting receipt: %w", err)
+	}
+	before := *receipt
+	receipt.EOBID = ""
+	receipt.PatientResponsibility = 0
+	receipt.UpdatedAt = now
+	if err := s.db.SaveReceipt(receipt); err != nil {
+		return fmt.Errorf("saving receipt to database: %w", err)
+	}
+	return s.recordHistory(&ReceiptEvent{Action: ActionUpdated, Before: &before, After: receipt})
+}
+
+// DeleteEOB removes an explanation of benefits and its file, unmatching its receipt
+func (s *Service) DeleteEOB(id string) error {
+	var eob *EOB
+	err := s.inTransaction(func(s *Service) error {
+		if err := s.unmatchEOB(id); err != nil {
+			return err
+		}
+		var err error
+		eob, err = s.db.GetEOB(id)
+		if err != nil {
+			return fmt.Errorf("getting eob for deletion: %w", err)
+		}
+		if err := s.db.DeleteEOB(id); err != nil {
+			return fmt.Errorf("deleting eob from database: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.Delete(eob.Filename); err != nil {
+		// Log error; the eob is already deleted
+		slog.Warn("Failed to delete file", "filename", eob.Filename, "error", err)
+	}
+	return nil
 }
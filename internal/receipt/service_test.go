@@ -2,6 +2,7 @@ package receipt
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"testing"
@@ -25,6 +26,13 @@ func TestService(t *testing.T) {
 type mockDB struct {
 	receipts              map[string]*Receipt
 	reimbursements        map[string]*Reimbursement
+	members               map[string]*Member
+	accounts              map[string]*Account
+	contributions         map[string]*Contribution
+	eobs                  map[string]*EOB
+	history               map[string][]*ReceiptEvent
+	transactions          int
+	beforeTransaction     func() // Stands in for a write another request commits just before a transaction starts
 	saveErr               error
 	getErr                error
 	listErr               error
@@ -32,12 +40,19 @@ type mockDB struct {
 	saveReimbursementErr  error
 	getReimbursementErr   error
 	listReimbursementsErr error
+	saveMemberErr         error
+	listMembersErr        error
 }
 
 func newMockDB() *mockDB {
 	return &mockDB{
 		receipts:       make(map[string]*Receipt),
 		reimbursements: make(map[string]*Reimbursement),
+		members:        make(map[string]*Member),
+		accounts:       make(map[string]*Account),
+		contributions:  make(map[string]*Contribution),
+		eobs:           make(map[string]*EOB),
+		history:        make(map[string][]*ReceiptEvent),
 	}
 }
 
@@ -55,7 +70,7 @@ func (m *mockDB) GetReceipt(id string) (*Receipt, error) {
 	}
 	receipt, ok := m.receipts[id]
 	if !ok {
-		return nil, errors.New("receipt not found")
+		return nil, fmt.Errorf("receipt %w: %s", ErrNotFound, id)
 	}
 	return receipt, nil
 }
@@ -96,7 +111,7 @@ func (m *mockDB) GetReimbursement(id string) (*Reimbursement, error) {
 	}
 	reimbursement, ok := m.reimbursements[id]
 	if !ok {
-		return nil, errors.New("reimbursement not found")
+		return nil, fmt.Errorf("reimbursement %w", ErrNotFound)
 	}
 	return reimbursement, nil
 }
@@ -112,6 +127,133 @@ func (m *mockDB) ListReimbursements() ([]*Reimbursement, error) {
 	return reimbursements, nil
 }
 
+func (m *mockDB) SaveMember(member *Member) error {
+	if m.saveMemberErr != nil {
+		return m.saveMemberErr
+	}
+	m.members[member.ID] = member
+	return nil
+}
+
+func (m *mockDB) GetMember(id string) (*Member, error) {
+	member, ok := m.members[id]
+	if !ok {
+		return nil, fmt.Errorf("member %w", ErrNotFound)
+	}
+	return member, nil
+}
+
+func (m *mockDB) ListMembers() ([]*Member, error) {
+	if m.listMembersErr != nil {
+		return nil, m.listMembersErr
+	}
+	members := make([]*Member, 0, len(m.members))
+	for _, member := range m.members {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (m *mockDB) DeleteMember(id string) error {
+	delete(m.members, id)
+	return nil
+}
+
+func (m *mockDB) SaveAccount(account *Account) error {
+	m.accounts[account.ID] = account
+	return nil
+}
+
+func (m *mockDB) GetAccount(id string) (*Account, error) {
+	account, ok := m.accounts[id]
+	if !ok {
+		return nil, errors.New("account not found")
+	}
+	return account, nil
+}
+
+func (m *mockDB) ListAccounts() ([]*Account, error) {
+	accounts := make([]*Account, 0, len(m.accounts))
+	for _, account := range m.accounts {
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func (m *mockDB) DeleteAccount(id string) error {
+	delete(m.accounts, id)
+	return nil
+}
+
+func (m *mockDB) SaveContribution(contribution *Contribution) error {
+	m.contributions[contribution.ID] = contribution
+	return nil
+}
+
+func (m *mockDB) GetContribution(id string) (*Contribution, error) {
+	contribution, ok := m.contributions[id]
+	if !ok {
+		return nil, errors.New("contribution not found")
+	}
+	return contribution, nil
+}
+
+func (m *mockDB) ListContributions() ([]*Contribution, error) {
+	contributions := make([]*Contribution, 0, len(m.contributions))
+	for _, contribution := range m.contributions {
+		contributions = append(contributions, contribution)
+	}
+	return contributions, nil
+}
+
+func (m *mockDB) DeleteContribution(id string) error {
+	delete(m.contributions, id)
+	return nil
+}
+
+func (m *mockDB) SaveEOB(eob *EOB) error {
+	m.eobs[eob.ID] = eob
+	return nil
+}
+
+func (m *mockDB) GetEOB(id string) (*EOB, error) {
+	eob, ok := m.eobs[id]
+	if !ok {
+		return nil, errors.New("eob not found")
+	}
+	return eob, nil
+}
+
+func (m *mockDB) ListEOBs() ([]*EOB, error) {
+	eobs := make([]*EOB, 0, len(m.eobs))
+	for _, eob := range m.eobs {
+		eobs = append(eobs, eob)
+	}
+	return eobs, nil
+}
+
+func (m *mockDB) DeleteEOB(id string) error {
+	delete(m.eobs, id)
+	return nil
+}
+
+func (m *mockDB) AppendReceiptEvent(event *ReceiptEvent) error {
+	m.history[event.ReceiptID] = append(m.history[event.ReceiptID], event)
+	return nil
+}
+
+func (m *mockDB) ListReceiptHistory(receiptID string) ([]*ReceiptEvent, error) {
+	return m.history[receiptID], nil
+}
+
+func (m *mockDB) Transaction(fn func(tx DB) error) error {
+	m.transactions++
+	if m.beforeTransaction != nil {
+		m.beforeTransaction()
+	}
+	return fn(m)
+}
+
 func (m *mockDB) Close() error {
 	return nil
 }
@@ -119,14 +261,19 @@ func (m *mockDB) Close() error {
 // mockStorage is a mock implementation of Storage
 type mockStorage struct {
 	files     map[string][]byte
+	trash     map[string][]byte
+	modTimes  map[string]time.Time
 	saveErr   error
 	getErr    error
 	deleteErr error
+	listErr   error
 }
 
 func newMockStorage() *mockStorage {
 	return &mockStorage{
-		files: make(map[string][]byte),
+		files:    make(map[string][]byte),
+		trash:    make(map[string][]byte),
+		modTimes: make(map[string]time.Time),
 	}
 }
 
@@ -160,27 +307,93 @@ func (m *mockStorage) Delete(path string) error {
 	return nil
 }
 
+func (m *mockStorage) List() ([]StoredFile, error) {
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	files := make([]StoredFile, 0, len(m.files))
+	for path := range m.files {
+		files = append(files, StoredFile{Path: path, ModTime: m.modTimes[path]})
+	}
+	return files, nil
+}
+
+func (m *mockStorage) Trash(path string) error {
+	data, ok := m.files[path]
+	if !ok {
+		return errors.New("file not found")
+	}
+	m.trash[path] = data
+	delete(m.files, path)
+	return nil
+}
+
+func (m *mockStorage) Restore(path string) error {
+	data, ok := m.trash[path]
+	if !ok {
+		return errors.New("file not found")
+	}
+	m.files[path] = data
+	delete(m.trash, path)
+	return nil
+}
+
+func (m *mockStorage) Purge(path string) error {
+	if _, ok := m.trash[path]; !ok {
+		return errors.New("file not found")
+	}
+	delete(m.trash, path)
+	return nil
+}
+
 // mockScanner is a mock implementation of scanning.Scanner
 type mockScanner struct {
 	scanErr     error
 	receiptData *scanning.ReceiptData
+	eobData     *scanning.EOBData
 }
 
 func newMockScanner() *mockScanner {
 	return &mockScanner{
 		receiptData: &scanning.ReceiptData{
-			Title:  "Test Receipt",
-			Date:   "2024-01-15",
-			Amount: 25.99,
+			Title:    "Test Receipt",
+			Date:     "2024-01-15",
+			Amount:   25.99,
+			Category: "pharmacy",
+			Fields: scanning.Fields{
+				Title:    scanning.FieldConfidence{Provenance: scanning.ProvenanceExtracted, Confidence: 0.9},
+				Date:     scanning.FieldConfidence{Provenance: scanning.ProvenanceExtracted, Confidence: 0.9},
+				Amount:   scanning.FieldConfidence{Provenance: scanning.ProvenanceExtracted, Confidence: 0.9},
+				Category: scanning.FieldConfidence{Provenance: scanning.ProvenanceInferred, Confidence: 0.8},
+			},
+		},
+		eobData: &scanning.EOBData{
+			Provider:              "Riverside Orthopedics",
+			ServiceDate:           "2024-01-10",
+			ClaimNumber:           "CLM-1001",
+			Billed:                450.00,
+			Allowed:               300.00,
+			InsurerPaid:           240.00,
+			PatientResponsibility: 60.00,
 		},
 	}
 }
 
-func (m *mockScanner) ScanReceipt(imageData []byte, contentType string) (*scanning.ReceiptData, error) {
+func (m *mockScanner) ScanReceipt(imageData []byte, contentType string, docType scanning.DocumentType) (*scanning.ReceiptData, error) {
 	if m.scanErr != nil {
 		return nil, m.scanErr
 	}
-	return m.receiptData, nil
+	data := *m.receiptData
+	data.DocumentType = docType
+	data.PromptVersion = "test-prompt-1"
+	return &data, nil
+}
+
+func (m *mockScanner) ScanEOB(imageData []byte, contentType string) (*scanning.EOBData, error) {
+	if m.scanErr != nil {
+		return nil, m.scanErr
+	}
+	return m.eobData, nil
 }
 
 func (m *mockScanner) Close() error {
@@ -240,7 +453,7 @@ var _ = Describe("Service", func() {
 		})
 
 		JustBeforeEach(func() {
-			receipt, err = service.ScanReceipt(filename, data, contentType)
+			receipt, err = service.ScanReceipt(filename, data, contentType, scanning.DocumentInvoice)
 		})
 
 		When("processing succeeds", func() {
@@ -260,18 +473,97 @@ var _ = Describe("Service", func() {
 				Expect(receipt.Amount).To(Equal(2599))
 			})
 
+			It("should set the category proposed by the scanner", func() {
+				Expect(receipt.Category).To(Equal(CategoryPharmacy))
+			})
+
 			It("should set the filename with ID prefix", func() {
 				Expect(receipt.Filename).To(Equal("test-id-123_receipt.jpg"))
 			})
 
-			It("should NOT save the receipt to the database yet", func() {
-				_, getErr := db.GetReceipt("test-id-123")
-				Expect(getErr).To(HaveOccurred())
+			It("should save the receipt to the database as a draft", func() {
+				saved, getErr := db.GetReceipt("test-id-123")
+				Expect(getErr).NotTo(HaveOccurred())
+				Expect(saved.Status).To(Equal(ReceiptDraft))
 			})
 
 			It("should save the file to storage", func() {
 				Expect(storage.files).To(HaveKey("test-id-123_receipt.jpg"))
 			})
+
+			It("should record the content hash of the file", func() {
+				Expect(receipt.ContentHash).To(Equal("5b3397652358a6663a0225ee76466d4e4fd6c58d484d1aa25170bb617d6bb086"))
+			})
+
+			It("should record where each detail came from", func() {
+				Expect(receipt.Fields.Amount).To(Equal(FieldConfidence{Provenance: ProvenanceExtracted, Confidence: 0.9}))
+				Expect(receipt.Fields.Category).To(Equal(FieldConfidence{Provenance: ProvenanceInferred, Confidence: 0.8}))
+			})
+
+			It("should not need review", func() {
+				Expect(receipt.NeedsReview).To(BeFalse())
+			})
+
+			It("should record the document type and prompt version it was scanned with", func() {
+				Expect(receipt.DocumentType).To(Equal("invoice"))
+				Expect(receipt.PromptVersion).To(Equal("test-prompt-1"))
+			})
+		})
+
+		When("the scanner read the text with OCR", func() {
+			BeforeEach(func() {
+				scanner.receiptData.RawText = "CORNER DRUG\nTOTAL 25.99"
+			})
+
+			It("should store the text with the receipt", func() {
+				saved, getErr := db.GetReceipt("test-id-123")
+				Expect(getErr).NotTo(HaveOccurred())
+				Expect(saved.RawText).To(Equal("CORNER DRUG\nTOTAL 25.99"))
+			})
+		})
+
+		When("the scanner defaulted a detail", func() {
+			BeforeEach(func() {
+				scanner.receiptData.Title = "Unknown Expense"
+				scanner.receiptData.Fields.Title = scanning.FieldConfidence{Provenance: scanning.ProvenanceDefaulted}
+			})
+
+			It("should need review", func() {
+				Expect(receipt.NeedsReview).To(BeTrue())
+			})
+		})
+
+		When("the scanner isn't confident about a detail", func() {
+			BeforeEach(func() {
+				scanner.receiptData.Fields.Amount.Confidence = 0.3
+			})
+
+			It("should need review", func() {
+				Expect(receipt.NeedsReview).To(BeTrue())
+			})
+		})
+
+		When("the scanners disagreed about a detail", func() {
+			BeforeEach(func() {
+				scanner.receiptData.Fields.Date.Disputed = true
+			})
+
+			It("should mark the detail as disputed and need review", func() {
+				Expect(receipt.Fields.Date.Disputed).To(BeTrue())
+				Expect(receipt.NeedsReview).To(BeTrue())
+			})
+		})
+
+		When("the scanned date can't be parsed", func() {
+			BeforeEach(func() {
+				scanner.receiptData.Date = "last Tuesday"
+			})
+
+			It("should use today's date and mark it as defaulted", func() {
+				Expect(receipt.Date).To(Equal(timeSrc.now))
+				Expect(receipt.Fields.Date).To(Equal(FieldConfidence{Provenance: ProvenanceDefaulted}))
+				Expect(receipt.NeedsReview).To(BeTrue())
+			})
 		})
 
 		When("storage save fails", func() {
@@ -287,6 +579,54 @@ var _ = Describe("Service", func() {
 			})
 		})
 
+		When("saving the draft fails", func() {
+			BeforeEach(func() {
+				db.saveErr = errors.New("database error")
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(MatchError(ContainSubstring("database error")))
+			})
+
+			It("cleans up the saved file", func() {
+				Expect(storage.files).NotTo(HaveKey("test-id-123_receipt.jpg"))
+			})
+		})
+
+		When("scanner extracts line items", func() {
+			BeforeEach(func() {
+				scanner.receiptData.LineItems = []scanning.LineItem{
+					{Description: "Amoxicillin", Quantity: 1, Amount: 19.99, Eligible: true},
+					{Description: "Shampoo", Quantity: 1, Amount: 6.00, Eligible: false},
+				}
+			})
+
+			It("should convert line item amounts to cents", func() {
+				Expect(receipt.LineItems).To(Equal([]LineItem{
+					{Description: "Amoxicillin", Quantity: 1, Amount: 1999, Eligible: true},
+					{Description: "Shampoo", Quantity: 1, Amount: 600, Eligible: false},
+				}))
+			})
+
+			It("should limit the reimbursable amount to eligible items", func() {
+				Expect(receipt.ReimbursableAmount()).To(Equal(1999))
+			})
+		})
+
+		When("scanner proposes an unknown category", func() {
+			BeforeEach(func() {
+				scanner.receiptData.Category = "groceries"
+			})
+
+			It("should fall back to other", func() {
+				Expect(receipt.Category).To(Equal(CategoryOther))
+			})
+
+			It("should mark the category as defaulted", func() {
+				Expect(receipt.Fields.Category.Provenance).To(Equal(ProvenanceDefaulted))
+			})
+		})
+
 		When("scanner fails", func() {
 			var setupErr error
 
@@ -305,6 +645,111 @@ var _ = Describe("Service", func() {
 		})
 	})
 
+	Describe("FindDuplicates", func() {
+		var (
+			candidate  *Receipt
+			duplicates []*Duplicate
+			err        error
+		)
+
+		BeforeEach(func() {
+			db.receipts["photo"] = &Receipt{
+				ID:          "photo",
+				Title:       "CVS Pharmacy",
+				Date:        time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+				Amount:      2599,
+				ContentHash: "abc123",
+				ImageHash:   "f0f0f0f0f0f0f0f0",
+			}
+			db.receipts["other"] = &Receipt{
+				ID:     "other",
+				Title:  "Eye Care Center",
+				Date:   time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+				Amount: 12000,
+			}
+			candidate = &Receipt{
+				ID:     "new",
+				Title:  "CVS/pharmacy #1234",
+				Date:   time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+				Amount: 2599,
+			}
+		})
+
+		JustBeforeEach(func() {
+			duplicates, err = service.FindDuplicates(candidate)
+		})
+
+		When("the emailed copy has a similar provider, date and amount", func() {
+			It("should report the earlier receipt", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(duplicates).To(Equal([]*Duplicate{{ReceiptID: "photo", Title: "CVS Pharmacy", Reasons: []string{"similar provider, same date and amount"}}}))
+			})
+		})
+
+		When("the title is the same", func() {
+			BeforeEach(func() {
+				candidate.Title = "cvs pharmacy"
+			})
+
+			It("should say the title, date and amount are the same", func() {
+				Expect(duplicates[0].Reasons).To(Equal([]string{"same title, date and amount"}))
+			})
+		})
+
+		When("the earlier upload is still a draft", func() {
+			BeforeEach(func() {
+				db.receipts["photo"].Status = ReceiptDraft
+			})
+
+			It("should report the draft", func() {
+				Expect(duplicates).To(HaveLen(1))
+				Expect(duplicates[0].ReceiptID).To(Equal("photo"))
+			})
+		})
+
+		When("the earlier receipt is in the trash", func() {
+			BeforeEach(func() {
+				db.receipts["photo"].DeletedAt = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+			})
+
+			It("should not report it", func() {
+				Expect(duplicates).To(BeEmpty())
+			})
+		})
+
+		When("the file is identical", func() {
+			BeforeEach(func() {
+				candidate.ContentHash = "abc123"
+				candidate.Amount = 0
+			})
+
+			It("should say the file is identical", func() {
+				Expect(duplicates[0].Reasons).To(Equal([]string{"the file is identical"}))
+			})
+		})
+
+		When("the image is visually similar", func() {
+			BeforeEach(func() {
+				candidate.ImageHash = "f0f0f0f0f0f0f0f1"
+				candidate.Amount = 0
+			})
+
+			It("should say the image looks the same", func() {
+				Expect(duplicates[0].Reasons).To(Equal([]string{"the image looks the same"}))
+			})
+		})
+
+		When("nothing matches", func() {
+			BeforeEach(func() {
+				candidate.Amount = 999
+			})
+
+			It("should report no duplicates", func() {
+				Expect(duplicates).To(BeEmpty())
+			})
+		})
+	})
+
 	Describe("CreateReceipt", func() {
 		var (
 			receipt *Receipt
@@ -352,6 +797,53 @@ var _ = Describe("Service", func() {
 				Expect(err).To(MatchError(setupErr))
 			})
 		})
+
+		When("the receipt claims to be unlocked and deleted", func() {
+			BeforeEach(func() {
+				receipt.Unlocked = true
+				receipt.DeletedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			})
+
+			It("should save it locked and out of the trash", func() {
+				Expect(db.receipts["test-id-123"].Unlocked).To(BeFalse())
+				Expect(db.receipts["test-id-123"].IsDeleted()).To(BeFalse())
+			})
+		})
+
+		When("the patient is a known member", func() {
+			BeforeEach(func() {
+				db.members["member-1"] = &Member{ID: "member-1", Name: "Alex"}
+				receipt.PatientID = "member-1"
+			})
+
+			It("should save the receipt with the patient", func() {
+				Expect(db.receipts["test-id-123"].PatientID).To(Equal("member-1"))
+			})
+		})
+
+		When("the category is invalid", func() {
+			BeforeEach(func() {
+				receipt.Category = "groceries"
+			})
+
+			It("returns an invalid category error", func() {
+				Expect(err).To(MatchError(ErrInvalidCategory))
+			})
+		})
+
+		When("the patient is unknown", func() {
+			BeforeEach(func() {
+				receipt.PatientID = "missing"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ErrUnknownPatient))
+			})
+
+			It("should not save the receipt", func() {
+				Expect(db.receipts).NotTo(HaveKey("test-id-123"))
+			})
+		})
 	})
 
 	Describe("GetReceipt", func() {
@@ -404,8 +896,14 @@ var _ = Describe("Service", func() {
 			err      error
 		)
 
+		var filter ReceiptFilter
+
+		BeforeEach(func() {
+			filter = ReceiptFilter{}
+		})
+
 		JustBeforeEach(func() {
-			receipts, err = service.ListReceipts()
+			receipts, err = service.ListReceipts(filter)
 		})
 
 		When("receipts exist", func() {
@@ -422,109 +920,1970 @@ var _ = Describe("Service", func() {
 				Expect(receipts).To(HaveLen(2))
 			})
 		})
-	})
 
-	Describe("DeleteReceipt", func() {
-		var (
-			receiptID string
-			err       error
-		)
+		When("filtering by patient", func() {
+			BeforeEach(func() {
+				db.receipts["id1"] = &Receipt{ID: "id1", PatientID: "member-1"}
+				db.receipts["id2"] = &Receipt{ID: "id2", PatientID: "member-2"}
+				db.receipts["id3"] = &Receipt{ID: "id3"}
+				filter = ReceiptFilter{PatientID: "member-1"}
+			})
+
+			It("should only return that patient's receipts", func() {
+				Expect(receipts).To(ConsistOf(HaveField("ID", "id1")))
+			})
+		})
+
+		When("filtering by category", func() {
+			BeforeEach(func() {
+				db.receipts["id1"] = &Receipt{ID: "id1", Category: CategoryDental}
+				db.receipts["id2"] = &Receipt{ID: "id2", Category: CategoryVision}
+				filter = ReceiptFilter{Category: CategoryVision}
+			})
+
+			It("should only return receipts in that category", func() {
+				Expect(receipts).To(ConsistOf(HaveField("ID", "id2")))
+			})
+		})
+
+		When("filtering by receipts that need review", func() {
+			BeforeEach(func() {
+				db.receipts["id1"] = &Receipt{ID: "id1", NeedsReview: true}
+				db.receipts["id2"] = &Receipt{ID: "id2"}
+				filter = ReceiptFilter{NeedsReview: true}
+			})
+
+			It("should only return receipts that need review", func() {
+				Expect(receipts).To(ConsistOf(HaveField("ID", "id1")))
+			})
+		})
+
+		When("some receipts are drafts", func() {
+			BeforeEach(func() {
+				db.receipts["id1"] = &Receipt{ID: "id1", Status: ReceiptConfirmed}
+				db.receipts["id2"] = &Receipt{ID: "id2", Status: ReceiptDraft}
+				db.receipts["id3"] = &Receipt{ID: "id3"}
+			})
+
+			It("should leave out the drafts", func() {
+				Expect(receipts).To(ConsistOf(HaveField("ID", "id1"), HaveField("ID", "id3")))
+			})
+		})
+
+		When("some receipts are in the trash", func() {
+			BeforeEach(func() {
+				db.receipts["id1"] = &Receipt{ID: "id1"}
+				db.receipts["id2"] = &Receipt{ID: "id2", DeletedAt: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)}
+			})
+
+			It("should leave them out", func() {
+				Expect(receipts).To(ConsistOf(HaveField("ID", "id1")))
+			})
+		})
+	})
+
+	Describe("ListDrafts", func() {
+		var (
+			drafts []*Receipt
+			err    error
+		)
+
+		BeforeEach(func() {
+			db.receipts["older"] = &Receipt{ID: "older", Status: ReceiptDraft, CreatedAt: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)}
+			db.receipts["newer"] = &Receipt{ID: "newer", Status: ReceiptDraft, CreatedAt: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)}
+			db.receipts["confirmed"] = &Receipt{ID: "confirmed", Status: ReceiptConfirmed}
+		})
 
 		JustBeforeEach(func() {
-			err = service.DeleteReceipt(receiptID)
+			drafts, err = service.ListDrafts()
 		})
 
-		When("deletion succeeds", func() {
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return only drafts, newest first", func() {
+			Expect(drafts).To(HaveLen(2))
+			Expect(drafts[0].ID).To(Equal("newer"))
+			Expect(drafts[1].ID).To(Equal("older"))
+		})
+	})
+
+	Describe("ConfirmReceipt", func() {
+		var (
+			receipt *Receipt
+			err     error
+		)
+
+		BeforeEach(func() {
+			db.receipts["draft-1"] = &Receipt{
+				ID:          "draft-1",
+				Title:       "Scanned Title",
+				Filename:    "draft-1_receipt.jpg",
+				ContentHash: "abc123",
+				Status:      ReceiptDraft,
+				CreatedAt:   time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+			}
+			receipt = &Receipt{ID: "draft-1", Title: "Reviewed Title", Category: CategoryPharmacy}
+		})
+
+		JustBeforeEach(func() {
+			err = service.ConfirmReceipt(receipt)
+		})
+
+		When("the receipt is a draft", func() {
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should save the reviewed details as confirmed", func() {
+				saved := db.receipts["draft-1"]
+				Expect(saved.Title).To(Equal("Reviewed Title"))
+				Expect(saved.Status).To(Equal(ReceiptConfirmed))
+			})
+
+			It("should keep the details recorded by the scan", func() {
+				saved := db.receipts["draft-1"]
+				Expect(saved.Filename).To(Equal("draft-1_receipt.jpg"))
+				Expect(saved.ContentHash).To(Equal("abc123"))
+				Expect(saved.CreatedAt).To(Equal(time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)))
+			})
+		})
+
+		When("a scanned detail needed review", func() {
 			BeforeEach(func() {
-				receiptID = "test-id"
-				db.receipts["test-id"] = &Receipt{
-					ID:       "test-id",
-					Filename: "test-file.jpg",
+				db.receipts["draft-1"].Fields = &ReceiptFields{
+					Title:    FieldConfidence{Provenance: ProvenanceDefaulted},
+					Amount:   FieldConfidence{Provenance: ProvenanceExtracted, Confidence: 0.3},
+					Category: FieldConfidence{Provenance: ProvenanceDefaulted},
 				}
-				storage.files["test-file.jpg"] = []byte("data")
+				db.receipts["draft-1"].NeedsReview = true
 			})
 
-			It("should not return an error", func() {
+			It("should mark the details that were changed as edited", func() {
+				saved := db.receipts["draft-1"]
+				Expect(saved.Fields.Title).To(Equal(FieldConfidence{Provenance: ProvenanceEdited}))
+				Expect(saved.Fields.Category).To(Equal(FieldConfidence{Provenance: ProvenanceEdited}))
+			})
+
+			It("should still need review for details that weren't changed", func() {
+				saved := db.receipts["draft-1"]
+				Expect(saved.Fields.Amount.Provenance).To(Equal(ProvenanceExtracted))
+				Expect(saved.NeedsReview).To(BeTrue())
+			})
+		})
+
+		When("the reviewed details include a deleted time", func() {
+			BeforeEach(func() {
+				receipt.DeletedAt = time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+			})
+
+			It("should not move the receipt to the trash", func() {
 				Expect(err).NotTo(HaveOccurred())
+				Expect(db.receipts["draft-1"].IsDeleted()).To(BeFalse())
 			})
+		})
 
-			It("should remove the receipt from the database", func() {
-				Expect(db.receipts).NotTo(HaveKey("test-id"))
+		When("the receipt was already confirmed", func() {
+			BeforeEach(func() {
+				db.receipts["draft-1"].Status = ReceiptConfirmed
 			})
 
-			It("should remove the file from storage", func() {
-				Expect(storage.files).NotTo(HaveKey("test-file.jpg"))
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ErrNotDraft))
+			})
+		})
+	})
+
+	Describe("reimbursed receipts", func() {
+		var date time.Time
+
+		BeforeEach(func() {
+			date = time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+			db.receipts["locked"] = &Receipt{
+				ID:          "locked",
+				Title:       "Pharmacy",
+				Date:        date,
+				Amount:      2599,
+				Category:    CategoryPharmacy,
+				Filename:    "locked.jpg",
+				Allocations: []Allocation{{ReimbursementID: "r1", ReceiptID: "locked", Amount: 2599}},
+				Attachments: []Attachment{
+					{ID: "locked", Filename: "locked.jpg"},
+					{ID: "statement", Filename: "statement.pdf"},
+				},
+			}
+		})
+
+		When("updating details that weren't reimbursed", func() {
+			It("should save the change", func() {
+				updated := &Receipt{ID: "locked", Title: "CVS Pharmacy", Date: date, Amount: 2599, Category: CategoryPharmacy}
+				Expect(service.UpdateReceipt(updated)).To(Succeed())
+				Expect(db.receipts["locked"].Title).To(Equal("CVS Pharmacy"))
+			})
+		})
+
+		When("changing the amount and date", func() {
+			It("returns an error naming the locked fields", func() {
+				updated := &Receipt{ID: "locked", Title: "Pharmacy", Date: date.AddDate(0, 0, 1), Amount: 100, Category: CategoryPharmacy}
+				err := service.UpdateReceipt(updated)
+				Expect(err).To(MatchError(ErrReceiptLocked))
+				Expect(err).To(MatchError(ContainSubstring("amount, date")))
+			})
+		})
+
+		When("deleting the receipt", func() {
+			It("returns an error", func() {
+				Expect(service.DeleteReceipt("locked")).To(MatchError(ErrReceiptLocked))
+				Expect(db.receipts["locked"].IsDeleted()).To(BeFalse())
+			})
+		})
+
+		When("an update sets a deleted time", func() {
+			It("should keep the receipt out of the trash", func() {
+				updated := &Receipt{ID: "locked", Title: "Pharmacy", Date: date, Amount: 2599, Category: CategoryPharmacy, DeletedAt: date}
+				Expect(service.UpdateReceipt(updated)).To(Succeed())
+				Expect(db.receipts["locked"].IsDeleted()).To(BeFalse())
+			})
+		})
+
+		When("removing a file", func() {
+			It("returns an error", func() {
+				Expect(service.RemoveAttachment("locked", "statement")).To(MatchError(ErrReceiptLocked))
 			})
 		})
 
-		When("storage delete fails", func() {
+		When("the receipt is unlocked", func() {
 			BeforeEach(func() {
-				receiptID = "test-id"
-				storage.deleteErr = errors.New("storage delete error")
-				db.receipts["test-id"] = &Receipt{
-					ID:       "test-id",
-					Filename: "test-file.jpg",
+				_, err := service.UnlockReceipt("locked", "amount was misread")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should allow one change", func() {
+				updated := &Receipt{ID: "locked", Title: "Pharmacy", Date: date, Amount: 2499, Category: CategoryPharmacy}
+				Expect(service.UpdateReceipt(updated)).To(Succeed())
+				Expect(db.receipts["locked"].Amount).To(Equal(2499))
+
+				again := &Receipt{ID: "locked", Title: "Pharmacy", Date: date, Amount: 2399, Category: CategoryPharmacy}
+				Expect(service.UpdateReceipt(again)).To(MatchError(ErrReceiptLocked))
+			})
+
+			It("should still refuse to delete it", func() {
+				Expect(service.DeleteReceipt("locked")).To(MatchError(ErrReceiptLocked))
+				Expect(db.receipts["locked"].IsDeleted()).To(BeFalse())
+			})
+		})
+
+		When("a reimbursement is recorded while an edit is being saved", func() {
+			It("should keep the new allocation", func() {
+				db.receipts["open"] = &Receipt{ID: "open", Title: "Pharmacy", Date: date, Amount: 1000, Category: CategoryPharmacy}
+				db.beforeTransaction = func() {
+					reimbursed := *db.receipts["open"]
+					reimbursed.Allocations = []Allocation{{ReimbursementID: "r2", ReceiptID: "open", Amount: 1000}}
+					db.receipts["open"] = &reimbursed
 				}
+
+				updated := &Receipt{ID: "open", Title: "CVS Pharmacy", Date: date, Amount: 1000, Category: CategoryPharmacy}
+				Expect(service.UpdateReceipt(updated)).To(Succeed())
+				Expect(db.receipts["open"].Title).To(Equal("CVS Pharmacy"))
+				Expect(db.receipts["open"].Allocations).To(HaveLen(1))
 			})
+		})
 
-			It("should not return an error", func() {
+		When("a reimbursement is recorded while the receipt is being unlocked", func() {
+			It("should keep the new allocation", func() {
+				db.beforeTransaction = func() {
+					reimbursed := *db.receipts["locked"]
+					reimbursed.Allocations = []Allocation{
+						{ReimbursementID: "r1", ReceiptID: "locked", Amount: 2599},
+						{ReimbursementID: "r2", ReceiptID: "locked", Amount: 100},
+					}
+					db.receipts["locked"] = &reimbursed
+				}
+
+				_, err := service.UnlockReceipt("locked", "amount was misread")
 				Expect(err).NotTo(HaveOccurred())
+				Expect(db.receipts["locked"].Unlocked).To(BeTrue())
+				Expect(db.receipts["locked"].Allocations).To(HaveLen(2))
 			})
+		})
 
-			It("should still remove the receipt from the database", func() {
-				Expect(db.receipts).NotTo(HaveKey("test-id"))
+		When("unlocking without a reason", func() {
+			It("returns an error", func() {
+				_, err := service.UnlockReceipt("locked", " ")
+				Expect(err).To(MatchError("a reason is required to unlock a receipt"))
+			})
+		})
+
+		When("unlocking a receipt that isn't reimbursed", func() {
+			It("returns an error", func() {
+				db.receipts["open"] = &Receipt{ID: "open"}
+				_, err := service.UnlockReceipt("open", "typo")
+				Expect(err).To(MatchError("receipt open isn't locked"))
 			})
 		})
 	})
 
-	Describe("GetReceiptFile", func() {
+	Describe("ReceiptHistory", func() {
 		var (
-			receiptID   string
-			data        []byte
-			contentType string
-			err         error
+			events []*ReceiptEvent
+			err    error
 		)
 
 		JustBeforeEach(func() {
-			data, contentType, err = service.GetReceiptFile(receiptID)
+			events, err = service.ReceiptHistory("test-id-123")
 		})
 
-		When("receipt and file exist", func() {
+		When("a scanned receipt is reviewed, reimbursed and deleted", func() {
 			BeforeEach(func() {
-				receiptID = "test-id"
-				db.receipts["test-id"] = &Receipt{
-					ID:          "test-id",
-					Filename:    "test-file.jpg",
-					ContentType: "image/jpeg",
-				}
-				storage.files["test-file.jpg"] = []byte("file data")
+				_, scanErr := service.ScanReceipt("receipt.jpg", []byte("fake image data"), "image/jpeg", scanning.DocumentReceipt)
+				Expect(scanErr).NotTo(HaveOccurred())
+				reviewed := &Receipt{ID: "test-id-123", Title: "Corrected Title", Amount: 2599, Category: CategoryPharmacy}
+				Expect(service.WithActor("alice").ConfirmReceipt(reviewed)).To(Succeed())
+				_, reimburseErr := service.WithActor("alice").CreateReimbursement("", ReimbursementPaid, []Allocation{{ReceiptID: "test-id-123"}})
+				Expect(reimburseErr).NotTo(HaveOccurred())
 			})
 
 			It("should not return an error", func() {
 				Expect(err).NotTo(HaveOccurred())
 			})
 
-			It("should return the file data", func() {
-				Expect(string(data)).To(Equal("file data"))
+			It("should record each change in order", func() {
+				Expect(events).To(HaveLen(3))
+				Expect(events[0].Action).To(Equal(ActionScanned))
+				Expect(events[1].Action).To(Equal(ActionConfirmed))
+				Expect(events[2].Action).To(Equal(ActionReimbursed))
 			})
 
-			It("should return the content type", func() {
-				Expect(contentType).To(Equal("image/jpeg"))
+			It("should keep what the scanner extracted apart from the user's edits", func() {
+				Expect(events[0].After.Title).To(Equal("Test Receipt"))
+				Expect(events[1].Before.Title).To(Equal("Test Receipt"))
+				Expect(events[1].After.Title).To(Equal("Corrected Title"))
+			})
+
+			It("should record who made each change", func() {
+				Expect(events[0].Actor).To(Equal("system"))
+				Expect(events[1].Actor).To(Equal("alice"))
+			})
+
+			It("should record the reimbursement that claimed the receipt", func() {
+				Expect(events[2].ReimbursementID).To(Equal("test-id-123"))
+				Expect(events[2].Before.Allocations).To(BeEmpty())
+				Expect(events[2].After.Allocations).To(HaveLen(1))
+			})
+
+			When("the receipt is unlocked", func() {
+				BeforeEach(func() {
+					_, unlockErr := service.WithActor("alice").UnlockReceipt("test-id-123", "uploaded the wrong file")
+					Expect(unlockErr).NotTo(HaveOccurred())
+				})
+
+				It("should record the unlock with its reason", func() {
+					Expect(events).To(HaveLen(4))
+					Expect(events[3].Action).To(Equal(ActionUnlocked))
+					Expect(events[3].Actor).To(Equal("alice"))
+					Expect(events[3].Note).To(Equal("uploaded the wrong file"))
+				})
+			})
+
+			When("the reimbursement is voided and the receipt deleted", func() {
+				BeforeEach(func() {
+					_, voidErr := service.TransitionReimbursement("test-id-123", ReimbursementVoided, "recorded twice")
+					Expect(voidErr).NotTo(HaveOccurred())
+					Expect(service.DeleteReceipt("test-id-123")).To(Succeed())
+				})
+
+				It("should record the release and the move to the trash", func() {
+					Expect(events).To(HaveLen(5))
+					Expect(events[3].Action).To(Equal(ActionReleased))
+					Expect(events[4].Action).To(Equal(ActionDeleted))
+					Expect(events[4].After.DeletedAt).NotTo(BeZero())
+				})
+
+				When("it's purged from the trash", func() {
+					BeforeEach(func() {
+						Expect(service.PurgeReceipt("test-id-123")).To(Succeed())
+					})
+
+					It("should still return the history, ending with the purge", func() {
+						Expect(err).NotTo(HaveOccurred())
+						Expect(events).To(HaveLen(6))
+						Expect(events[5].Action).To(Equal(ActionPurged))
+						Expect(events[5].After).To(BeNil())
+					})
+				})
 			})
 		})
 
-		When("receipt does not exist", func() {
-			var setupErr error
+		When("the receipt doesn't exist", func() {
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ContainSubstring("receipt not found")))
+			})
+		})
+	})
 
+	Describe("SweepAbandoned", func() {
+		var (
+			result *SweepResult
+			err    error
+		)
+
+		BeforeEach(func() {
+			old := time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)
+			recent := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+
+			db.receipts["old-draft"] = &Receipt{ID: "old-draft", Filename: "old-draft.jpg", Status: ReceiptDraft, CreatedAt: old}
+			db.receipts["new-draft"] = &Receipt{ID: "new-draft", Filename: "new-draft.jpg", Status: ReceiptDraft, CreatedAt: recent}
+			db.receipts["kept"] = &Receipt{ID: "kept", Filename: "kept.jpg", Status: ReceiptConfirmed, CreatedAt: old}
+			db.eobs["eob-1"] = &EOB{ID: "eob-1", Filename: "eob-1.pdf"}
+
+			for path, modTime := range map[string]time.Time{
+				"old-draft.jpg":             old,
+				"new-draft.jpg":             recent,
+				"kept.jpg":                  old,
+				"eob-1.pdf":                 old,
+				"in-progress.jpg":           recent,
+				"1705000000_orphan.jpg":     old,
+				"eob_1705000001_orphan.pdf": old,
+				"hsa-tracker.db":            old,
+				"taxes-2023.pdf":            old,
+			} {
+				storage.files[path] = []byte("data")
+				storage.modTimes[path] = modTime
+			}
+		})
+
+		JustBeforeEach(func() {
+			result, err = service.SweepAbandoned(24 * time.Hour)
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should delete drafts older than the max age", func() {
+			Expect(db.receipts).NotTo(HaveKey("old-draft"))
+			Expect(db.receipts).To(HaveKey("new-draft"))
+			Expect(db.receipts).To(HaveKey("kept"))
+		})
+
+		It("should delete old uploads that nothing references", func() {
+			Expect(storage.files).NotTo(HaveKey("1705000000_orphan.jpg"))
+			Expect(storage.files).NotTo(HaveKey("eob_1705000001_orphan.pdf"))
+			Expect(storage.files).To(HaveKey("new-draft.jpg"))
+			Expect(storage.files).To(HaveKey("kept.jpg"))
+			Expect(storage.files).To(HaveKey("eob-1.pdf"))
+			Expect(storage.files).To(HaveKey("in-progress.jpg"))
+		})
+
+		It("should leave files the service didn't upload", func() {
+			Expect(storage.files).To(HaveKey("hsa-tracker.db"))
+			Expect(storage.files).To(HaveKey("taxes-2023.pdf"))
+		})
+
+		It("should count what was deleted", func() {
+			Expect(result).To(Equal(&SweepResult{DraftsDeleted: 1, FilesDeleted: 2}))
+		})
+
+		When("listing stored files fails", func() {
 			BeforeEach(func() {
-				receiptID = "nonexistent"
-				setupErr = errors.New("receipt not found")
-				db.getErr = setupErr
+				storage.listErr = errors.New("storage error")
 			})
 
 			It("returns the error", func() {
-				Expect(err).To(MatchError(setupErr))
+				Expect(err).To(MatchError(ContainSubstring("storage error")))
+			})
+		})
+	})
+
+	Describe("AddAttachment", func() {
+		var (
+			role       AttachmentRole
+			attachment *Attachment
+			err        error
+		)
+
+		BeforeEach(func() {
+			role = AttachmentEOB
+			db.receipts["receipt-1"] = &Receipt{ID: "receipt-1", Filename: "receipt-1_bill.jpg"}
+		})
+
+		JustBeforeEach(func() {
+			attachment, err = service.AddAttachment("receipt-1", role, "eob (1).pdf", []byte("pdf"), "application/pdf")
+		})
+
+		When("the attachment is valid", func() {
+			It("should save the file to storage", func() {
+				Expect(storage.files).To(HaveKey("receipt-1_test-id-123_eob 1.pdf"))
+			})
+
+			It("should record the attachment on the receipt", func() {
+				Expect(db.receipts["receipt-1"].Attachments).To(ContainElement(*attachment))
+			})
+		})
+
+		When("the role is unknown", func() {
+			BeforeEach(func() {
+				role = "invoice"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("invalid attachment role: invoice"))
+			})
+		})
+
+		When("saving the receipt fails", func() {
+			BeforeEach(func() {
+				db.saveErr = errors.New("database error")
+			})
+
+			It("should remove the saved file", func() {
+				Expect(storage.files).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("RemoveAttachment", func() {
+		var (
+			attachmentID string
+			err          error
+		)
+
+		BeforeEach(func() {
+			db.receipts["receipt-1"] = &Receipt{
+				ID:       "receipt-1",
+				Filename: "receipt-1_bill.jpg",
+				Attachments: []Attachment{
+					{ID: "receipt-1", Role: AttachmentReceipt, Filename: "receipt-1_bill.jpg"},
+					{ID: "eob", Role: AttachmentEOB, Filename: "receipt-1_eob_eob.pdf"},
+				},
+			}
+			storage.files["receipt-1_eob_eob.pdf"] = []byte("pdf")
+		})
+
+		JustBeforeEach(func() {
+			err = service.RemoveAttachment("receipt-1", attachmentID)
+		})
+
+		When("removing a supporting document", func() {
+			BeforeEach(func() {
+				attachmentID = "eob"
+			})
+
+			It("should drop it from the receipt", func() {
+				Expect(db.receipts["receipt-1"].Attachments).To(HaveLen(1))
+			})
+
+			It("should delete the file", func() {
+				Expect(storage.files).NotTo(HaveKey("receipt-1_eob_eob.pdf"))
+			})
+		})
+
+		When("removing the scanned receipt file", func() {
+			BeforeEach(func() {
+				attachmentID = "receipt-1"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("the scanned receipt file can't be removed"))
 			})
 		})
 	})
+
+	Describe("DeleteReceipt", func() {
+		var (
+			receiptID string
+			err       error
+		)
+
+		JustBeforeEach(func() {
+			err = service.DeleteReceipt(receiptID)
+		})
+
+		When("deletion succeeds", func() {
+			BeforeEach(func() {
+				receiptID = "test-id"
+				db.receipts["test-id"] = &Receipt{
+					ID:       "test-id",
+					Filename: "test-file.jpg",
+				}
+				storage.files["test-file.jpg"] = []byte("data")
+			})
+
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should move the receipt to the trash", func() {
+				Expect(db.receipts["test-id"].DeletedAt).To(Equal(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)))
+			})
+
+			It("should move the file to the trash", func() {
+				Expect(storage.files).NotTo(HaveKey("test-file.jpg"))
+				Expect(storage.trash).To(HaveKey("test-file.jpg"))
+			})
+		})
+
+		When("the receipt has attachments", func() {
+			BeforeEach(func() {
+				receiptID = "test-id"
+				db.receipts["test-id"] = &Receipt{
+					ID:       "test-id",
+					Filename: "test-file.jpg",
+					Attachments: []Attachment{
+						{ID: "test-id", Filename: "test-file.jpg"},
+						{ID: "eob", Filename: "test-eob.pdf"},
+					},
+				}
+				storage.files["test-file.jpg"] = []byte("data")
+				storage.files["test-eob.pdf"] = []byte("data")
+			})
+
+			It("should move every file to the trash", func() {
+				Expect(storage.files).To(BeEmpty())
+				Expect(storage.trash).To(HaveLen(2))
+			})
+		})
+
+		When("moving the file to the trash fails", func() {
+			BeforeEach(func() {
+				receiptID = "test-id"
+				db.receipts["test-id"] = &Receipt{
+					ID:       "test-id",
+					Filename: "test-file.jpg",
+				}
+			})
+
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should still move the receipt to the trash", func() {
+				Expect(db.receipts["test-id"].IsDeleted()).To(BeTrue())
+			})
+		})
+
+		When("the receipt is a draft", func() {
+			BeforeEach(func() {
+				receiptID = "test-id"
+				db.receipts["test-id"] = &Receipt{
+					ID:       "test-id",
+					Filename: "test-file.jpg",
+					Status:   ReceiptDraft,
+				}
+				storage.files["test-file.jpg"] = []byte("data")
+			})
+
+			It("should remove the receipt from the database", func() {
+				Expect(db.receipts).NotTo(HaveKey("test-id"))
+			})
+
+			It("should delete the file instead of trashing it", func() {
+				Expect(storage.files).To(BeEmpty())
+				Expect(storage.trash).To(BeEmpty())
+			})
+		})
+
+		When("a reimbursement is recorded while the receipt is being deleted", func() {
+			BeforeEach(func() {
+				receiptID = "test-id"
+				db.receipts["test-id"] = &Receipt{
+					ID:       "test-id",
+					Filename: "test-file.jpg",
+				}
+				storage.files["test-file.jpg"] = []byte("data")
+				db.beforeTransaction = func() {
+					reimbursed := *db.receipts["test-id"]
+					reimbursed.Allocations = []Allocation{{ReimbursementID: "r1", ReceiptID: "test-id", Amount: 1000}}
+					db.receipts["test-id"] = &reimbursed
+				}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ErrReceiptLocked))
+			})
+
+			It("should keep the receipt, its allocation and its file", func() {
+				Expect(db.receipts["test-id"].IsDeleted()).To(BeFalse())
+				Expect(db.receipts["test-id"].Allocations).To(HaveLen(1))
+				Expect(storage.files).To(HaveKey("test-file.jpg"))
+			})
+		})
+
+		When("storage delete fails for a draft", func() {
+			BeforeEach(func() {
+				receiptID = "test-id"
+				storage.deleteErr = errors.New("storage delete error")
+				db.receipts["test-id"] = &Receipt{
+					ID:       "test-id",
+					Filename: "test-file.jpg",
+					Status:   ReceiptDraft,
+				}
+			})
+
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should still remove the receipt from the database", func() {
+				Expect(db.receipts).NotTo(HaveKey("test-id"))
+			})
+		})
+	})
+
+	Describe("RestoreReceipt", func() {
+		var (
+			restored *Receipt
+			err      error
+		)
+
+		BeforeEach(func() {
+			db.receipts["test-id"] = &Receipt{
+				ID:        "test-id",
+				Filename:  "test-file.jpg",
+				DeletedAt: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+			}
+			storage.trash["test-file.jpg"] = []byte("data")
+		})
+
+		JustBeforeEach(func() {
+			restored, err = service.RestoreReceipt("test-id")
+		})
+
+		When("the receipt is in the trash", func() {
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should take the receipt out of the trash", func() {
+				Expect(restored.IsDeleted()).To(BeFalse())
+				Expect(db.receipts["test-id"].IsDeleted()).To(BeFalse())
+			})
+
+			It("should move the file back", func() {
+				Expect(storage.files).To(HaveKey("test-file.jpg"))
+				Expect(storage.trash).To(BeEmpty())
+			})
+		})
+
+		When("the receipt isn't in the trash", func() {
+			BeforeEach(func() {
+				db.receipts["test-id"].DeletedAt = time.Time{}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ErrNotInTrash))
+			})
+		})
+
+		When("another request restores the receipt first", func() {
+			BeforeEach(func() {
+				db.beforeTransaction = func() {
+					restored := *db.receipts["test-id"]
+					restored.DeletedAt = time.Time{}
+					db.receipts["test-id"] = &restored
+				}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ErrNotInTrash))
+			})
+
+			It("should not restore the files again", func() {
+				Expect(storage.trash).To(HaveKey("test-file.jpg"))
+			})
+		})
+	})
+
+	Describe("PurgeReceipt", func() {
+		var err error
+
+		BeforeEach(func() {
+			db.receipts["test-id"] = &Receipt{
+				ID:        "test-id",
+				Filename:  "test-file.jpg",
+				EOBID:     "eob-1",
+				DeletedAt: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+			}
+			db.eobs["eob-1"] = &EOB{ID: "eob-1", ReceiptID: "test-id"}
+			storage.trash["test-file.jpg"] = []byte("data")
+		})
+
+		JustBeforeEach(func() {
+			err = service.PurgeReceipt("test-id")
+		})
+
+		When("the receipt is in the trash", func() {
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should remove the receipt from the database", func() {
+				Expect(db.receipts).NotTo(HaveKey("test-id"))
+			})
+
+			It("should remove the file from the trash", func() {
+				Expect(storage.trash).To(BeEmpty())
+			})
+
+			It("should unmatch the eob", func() {
+				Expect(db.eobs["eob-1"].ReceiptID).To(BeEmpty())
+			})
+		})
+
+		When("a reimbursement still depends on the receipt", func() {
+			BeforeEach(func() {
+				db.reimbursements["reimb-1"] = &Reimbursement{ID: "reimb-1", Status: ReimbursementRequested, ReceiptIDs: []string{"test-id"}}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ErrReceiptLocked))
+			})
+
+			It("should keep the receipt and its file", func() {
+				Expect(db.receipts).To(HaveKey("test-id"))
+				Expect(storage.trash).To(HaveKey("test-file.jpg"))
+			})
+		})
+
+		When("a reimbursement is recorded while the receipt is being purged", func() {
+			BeforeEach(func() {
+				db.beforeTransaction = func() {
+					db.reimbursements["reimb-1"] = &Reimbursement{ID: "reimb-1", Status: ReimbursementRequested, ReceiptIDs: []string{"test-id"}}
+				}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ErrReceiptLocked))
+			})
+
+			It("should keep the receipt", func() {
+				Expect(db.receipts).To(HaveKey("test-id"))
+			})
+		})
+
+		When("the reimbursement that listed the receipt was voided", func() {
+			BeforeEach(func() {
+				db.reimbursements["reimb-1"] = &Reimbursement{ID: "reimb-1", Status: ReimbursementVoided, ReceiptIDs: []string{"test-id"}}
+			})
+
+			It("should purge the receipt", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(db.receipts).NotTo(HaveKey("test-id"))
+			})
+		})
+
+		When("the receipt isn't in the trash", func() {
+			BeforeEach(func() {
+				db.receipts["test-id"].DeletedAt = time.Time{}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ErrNotInTrash))
+			})
+
+			It("should keep the receipt", func() {
+				Expect(db.receipts).To(HaveKey("test-id"))
+			})
+		})
+	})
+
+	Describe("PurgeTrash", func() {
+		var (
+			purged int
+			err    error
+		)
+
+		BeforeEach(func() {
+			db.receipts["old"] = &Receipt{ID: "old", DeletedAt: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)}
+			db.receipts["recent"] = &Receipt{ID: "recent", DeletedAt: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)}
+			db.receipts["active"] = &Receipt{ID: "active"}
+		})
+
+		JustBeforeEach(func() {
+			purged, err = service.PurgeTrash(30 * 24 * time.Hour)
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should purge receipts in the trash longer than the retention window", func() {
+			Expect(purged).To(Equal(1))
+			Expect(db.receipts).NotTo(HaveKey("old"))
+			Expect(db.receipts).To(HaveKey("recent"))
+			Expect(db.receipts).To(HaveKey("active"))
+		})
+
+		When("an old receipt in the trash is still reimbursed", func() {
+			BeforeEach(func() {
+				db.receipts["reimbursed"] = &Receipt{
+					ID:          "reimbursed",
+					DeletedAt:   time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC),
+					Allocations: []Allocation{{ReimbursementID: "reimb-1", Amount: 1000}},
+				}
+				db.reimbursements["reimb-1"] = &Reimbursement{ID: "reimb-1", Status: ReimbursementPaid, ReceiptIDs: []string{"reimbursed"}}
+			})
+
+			It("should skip it and purge the rest", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(purged).To(Equal(1))
+				Expect(db.receipts).To(HaveKey("reimbursed"))
+				Expect(db.receipts).NotTo(HaveKey("old"))
+			})
+		})
+	})
+
+	Describe("ListTrash", func() {
+		var (
+			trash []*Receipt
+			err   error
+		)
+
+		BeforeEach(func() {
+			db.receipts["older"] = &Receipt{ID: "older", DeletedAt: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)}
+			db.receipts["newer"] = &Receipt{ID: "newer", DeletedAt: time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)}
+			db.receipts["active"] = &Receipt{ID: "active"}
+		})
+
+		JustBeforeEach(func() {
+			trash, err = service.ListTrash()
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return deleted receipts, most recently deleted first", func() {
+			Expect(trash).To(HaveLen(2))
+			Expect(trash[0].ID).To(Equal("newer"))
+			Expect(trash[1].ID).To(Equal("older"))
+		})
+	})
+
+	Describe("GetReceiptFile", func() {
+		var (
+			receiptID   string
+			data        []byte
+			contentType string
+			err         error
+		)
+
+		JustBeforeEach(func() {
+			data, contentType, err = service.GetReceiptFile(receiptID)
+		})
+
+		When("receipt and file exist", func() {
+			BeforeEach(func() {
+				receiptID = "test-id"
+				db.receipts["test-id"] = &Receipt{
+					ID:          "test-id",
+					Filename:    "test-file.jpg",
+					ContentType: "image/jpeg",
+				}
+				storage.files["test-file.jpg"] = []byte("file data")
+			})
+
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should return the file data", func() {
+				Expect(string(data)).To(Equal("file data"))
+			})
+
+			It("should return the content type", func() {
+				Expect(contentType).To(Equal("image/jpeg"))
+			})
+		})
+
+		When("receipt does not exist", func() {
+			var setupErr error
+
+			BeforeEach(func() {
+				receiptID = "nonexistent"
+				setupErr = errors.New("receipt not found")
+				db.getErr = setupErr
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(MatchError(setupErr))
+			})
+		})
+	})
+
+	Describe("CreateMember", func() {
+		var (
+			member *Member
+			err    error
+		)
+
+		BeforeEach(func() {
+			member = &Member{Name: "  Alex  ", Relationship: "dependent"}
+		})
+
+		JustBeforeEach(func() {
+			err = service.CreateMember(member)
+		})
+
+		When("save succeeds", func() {
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should assign a generated ID", func() {
+				Expect(member.ID).To(Equal("test-id-123"))
+			})
+
+			It("should trim the name", func() {
+				Expect(db.members["test-id-123"].Name).To(Equal("Alex"))
+			})
+		})
+
+		When("the name is blank", func() {
+			BeforeEach(func() {
+				member.Name = "   "
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("member name is required"))
+			})
+		})
+
+		When("database save fails", func() {
+			var setupErr error
+
+			BeforeEach(func() {
+				setupErr = errors.New("database error")
+				db.saveMemberErr = setupErr
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(MatchError(setupErr))
+			})
+		})
+	})
+
+	Describe("DeleteMember", func() {
+		var err error
+
+		BeforeEach(func() {
+			db.members["member-1"] = &Member{ID: "member-1", Name: "Alex"}
+		})
+
+		JustBeforeEach(func() {
+			err = service.DeleteMember("member-1")
+		})
+
+		When("the member has no receipts", func() {
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should remove the member", func() {
+				Expect(db.members).NotTo(HaveKey("member-1"))
+			})
+		})
+
+		When("the member has receipts", func() {
+			BeforeEach(func() {
+				db.receipts["id1"] = &Receipt{ID: "id1", PatientID: "member-1"}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("member member-1 still has receipts assigned"))
+			})
+
+			It("should keep the member", func() {
+				Expect(db.members).To(HaveKey("member-1"))
+			})
+		})
+	})
+
+	Describe("MemberTotals", func() {
+		var (
+			year   int
+			totals []*MemberTotal
+			err    error
+		)
+
+		BeforeEach(func() {
+			year = 2024
+			db.members["member-1"] = &Member{ID: "member-1", Name: "Alex"}
+			db.receipts["id1"] = &Receipt{ID: "id1", PatientID: "member-1", Amount: 1000, Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+			db.receipts["id2"] = &Receipt{ID: "id2", PatientID: "member-1", Amount: 500, Date: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+			db.receipts["id3"] = &Receipt{ID: "id3", PatientID: "member-1", Amount: 700, Date: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)}
+			db.receipts["id4"] = &Receipt{ID: "id4", Amount: 250, Date: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+		})
+
+		JustBeforeEach(func() {
+			totals, err = service.MemberTotals(year)
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should sum the member's receipts for the year", func() {
+			Expect(totals).To(ContainElement(HaveValue(Equal(MemberTotal{MemberID: "member-1", Name: "Alex", ReceiptCount: 2, TotalAmount: 1500}))))
+		})
+
+		It("should report unassigned receipts separately", func() {
+			Expect(totals).To(ContainElement(HaveValue(Equal(MemberTotal{MemberID: "", Name: "Unassigned", ReceiptCount: 1, TotalAmount: 250}))))
+		})
+
+		When("no year is given", func() {
+			BeforeEach(func() {
+				year = 0
+			})
+
+			It("should include receipts from every year", func() {
+				Expect(totals).To(ContainElement(HaveValue(HaveField("TotalAmount", 2200))))
+			})
+		})
+	})
+
+	Describe("CreateReimbursement", func() {
+		var (
+			accountID     string
+			status        ReimbursementStatus
+			allocations   []Allocation
+			reimbursement *Reimbursement
+			err           error
+		)
+
+		BeforeEach(func() {
+			db.receipts["id1"] = &Receipt{ID: "id1", Amount: 1000}
+			db.receipts["id2"] = &Receipt{
+				ID:     "id2",
+				Amount: 2600,
+				LineItems: []LineItem{
+					{Description: "Amoxicillin", Quantity: 1, Amount: 2000, Eligible: true},
+					{Description: "Shampoo", Quantity: 1, Amount: 600, Eligible: false},
+				},
+			}
+			accountID = ""
+			status = ""
+			allocations = []Allocation{{ReceiptID: "id1"}, {ReceiptID: "id2"}}
+		})
+
+		JustBeforeEach(func() {
+			reimbursement, err = service.CreateReimbursement(accountID, status, allocations)
+		})
+
+		When("reimbursing receipts in full", func() {
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should only total the eligible amounts", func() {
+				Expect(reimbursement.TotalAmount).To(Equal(3000))
+			})
+
+			It("should save everything in one transaction", func() {
+				Expect(db.transactions).To(Equal(1))
+			})
+
+			It("should record the reimbursement as paid", func() {
+				Expect(reimbursement.Status).To(Equal(ReimbursementPaid))
+			})
+
+			It("should record the allocation on the receipts", func() {
+				Expect(db.receipts["id2"].Allocations).To(Equal([]Allocation{
+					{ReimbursementID: "test-id-123", ReceiptID: "id2", Amount: 2000},
+				}))
+			})
+
+			It("should leave nothing outstanding", func() {
+				Expect(db.receipts["id2"].OutstandingAmount()).To(Equal(0))
+			})
+		})
+
+		When("reimbursing part of a receipt", func() {
+			BeforeEach(func() {
+				allocations = []Allocation{{ReceiptID: "id1", Amount: 400}}
+			})
+
+			It("should total the allocated amount", func() {
+				Expect(reimbursement.TotalAmount).To(Equal(400))
+			})
+
+			It("should track the outstanding balance", func() {
+				Expect(db.receipts["id1"].OutstandingAmount()).To(Equal(600))
+			})
+		})
+
+		When("the receipt was partially reimbursed before", func() {
+			BeforeEach(func() {
+				db.receipts["id1"].Allocations = []Allocation{{ReimbursementID: "earlier", ReceiptID: "id1", Amount: 400}}
+				allocations = []Allocation{{ReceiptID: "id1"}}
+			})
+
+			It("should reimburse the remaining balance", func() {
+				Expect(reimbursement.TotalAmount).To(Equal(600))
+			})
+
+			It("should keep both allocations on the receipt", func() {
+				Expect(db.receipts["id1"].Allocations).To(HaveLen(2))
+			})
+		})
+
+		When("the allocation exceeds the outstanding balance", func() {
+			BeforeEach(func() {
+				allocations = []Allocation{{ReceiptID: "id1", Amount: 1500}}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("receipt id1 only has 1000 cents outstanding"))
+			})
+		})
+
+		When("the receipt is already fully reimbursed", func() {
+			BeforeEach(func() {
+				db.receipts["id1"].Allocations = []Allocation{{ReimbursementID: "earlier", ReceiptID: "id1", Amount: 1000}}
+				allocations = []Allocation{{ReceiptID: "id1"}}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("receipt id1 is already fully reimbursed"))
+			})
+		})
+
+		When("a receipt is listed twice", func() {
+			BeforeEach(func() {
+				allocations = []Allocation{{ReceiptID: "id1", Amount: 100}, {ReceiptID: "id1", Amount: 100}}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("receipt id1 is listed more than once"))
+			})
+		})
+
+		When("drawing from an account", func() {
+			BeforeEach(func() {
+				db.receipts["id1"].Category = CategoryDental
+				db.receipts["id1"].Date = time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+				db.accounts["fsa"] = &Account{
+					ID:            "fsa",
+					Name:          "Limited FSA",
+					Type:          AccountTypeLPFSA,
+					PlanYearStart: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					PlanYearEnd:   time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+				}
+				accountID = "fsa"
+				allocations = []Allocation{{ReceiptID: "id1"}}
+			})
+
+			It("should record the account on the reimbursement", func() {
+				Expect(reimbursement.AccountID).To(Equal("fsa"))
+			})
+
+			When("the receipt category is not eligible for the account", func() {
+				BeforeEach(func() {
+					db.receipts["id1"].Category = CategoryPharmacy
+				})
+
+				It("returns an error", func() {
+					Expect(err).To(MatchError("receipt id1: pharmacy expenses are not eligible for lpfsa accounts"))
+				})
+			})
+
+			When("the receipt falls outside the plan year", func() {
+				BeforeEach(func() {
+					db.receipts["id1"].Date = time.Date(2023, 12, 20, 0, 0, 0, 0, time.UTC)
+				})
+
+				It("returns an error", func() {
+					Expect(err).To(MatchError("receipt id1: incurred before the plan year of account Limited FSA"))
+				})
+			})
+
+			When("the receipt predates the HSA", func() {
+				BeforeEach(func() {
+					db.accounts["fsa"].Type = AccountTypeHSA
+					db.accounts["fsa"].EstablishedDate = time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+				})
+
+				It("returns an error", func() {
+					Expect(err).To(MatchError(ErrPredatesHSA))
+				})
+			})
+
+			When("the claim deadline has passed", func() {
+				BeforeEach(func() {
+					db.accounts["fsa"].ClaimDeadline = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				})
+
+				It("returns an error", func() {
+					Expect(err).To(MatchError("the claim deadline for account Limited FSA has passed"))
+				})
+			})
+		})
+	})
+
+	Describe("CreateReimbursement as a draft", func() {
+		var (
+			status        ReimbursementStatus
+			reimbursement *Reimbursement
+			err           error
+		)
+
+		BeforeEach(func() {
+			db.receipts["id1"] = &Receipt{ID: "id1", Amount: 1000}
+			status = ReimbursementDraft
+		})
+
+		JustBeforeEach(func() {
+			reimbursement, err = service.CreateReimbursement("", status, []Allocation{{ReceiptID: "id1"}})
+		})
+
+		It("should start the history with the draft status", func() {
+			Expect(reimbursement.History).To(Equal([]StatusChange{{To: ReimbursementDraft, At: timeSrc.now}}))
+		})
+
+		It("should hold the receipt's amount", func() {
+			Expect(db.receipts["id1"].OutstandingAmount()).To(Equal(0))
+		})
+
+		When("the starting status is voided", func() {
+			BeforeEach(func() {
+				status = ReimbursementVoided
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("a reimbursement can't be created as voided"))
+			})
+		})
+	})
+
+	Describe("TransitionReimbursement", func() {
+		var (
+			status        ReimbursementStatus
+			reimbursement *Reimbursement
+			err           error
+		)
+
+		BeforeEach(func() {
+			db.reimbursements["reimb-1"] = &Reimbursement{
+				ID:          "reimb-1",
+				ReceiptIDs:  []string{"id1"},
+				Allocations: []Allocation{{ReimbursementID: "reimb-1", ReceiptID: "id1", Amount: 1000}},
+				TotalAmount: 1000,
+				Status:      ReimbursementRequested,
+			}
+			db.receipts["id1"] = &Receipt{
+				ID:          "id1",
+				Amount:      1500,
+				Allocations: []Allocation{{ReimbursementID: "reimb-0", ReceiptID: "id1", Amount: 500}, {ReimbursementID: "reimb-1", ReceiptID: "id1", Amount: 1000}},
+			}
+		})
+
+		JustBeforeEach(func() {
+			reimbursement, err = service.TransitionReimbursement("reimb-1", status, "entered twice")
+		})
+
+		When("voiding a reimbursement", func() {
+			BeforeEach(func() {
+				status = ReimbursementVoided
+			})
+
+			It("should release the receipt's allocation", func() {
+				Expect(db.receipts["id1"].Allocations).To(Equal([]Allocation{{ReimbursementID: "reimb-0", ReceiptID: "id1", Amount: 500}}))
+			})
+
+			It("should keep the voided reimbursement", func() {
+				Expect(db.reimbursements["reimb-1"].Status).To(Equal(ReimbursementVoided))
+			})
+
+			When("one of its receipts no longer exists", func() {
+				BeforeEach(func() {
+					db.reimbursements["reimb-1"].ReceiptIDs = []string{"purged", "id1"}
+				})
+
+				It("should still void it and release the remaining receipts", func() {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(db.reimbursements["reimb-1"].Status).To(Equal(ReimbursementVoided))
+					Expect(db.receipts["id1"].Allocations).To(HaveLen(1))
+				})
+			})
+
+			It("should record the change in the history", func() {
+				Expect(reimbursement.History).To(ContainElement(StatusChange{From: ReimbursementRequested, To: ReimbursementVoided, At: timeSrc.now, Note: "entered twice"}))
+			})
+		})
+
+		When("marking a reimbursement paid", func() {
+			BeforeEach(func() {
+				status = ReimbursementPaid
+			})
+
+			It("should record the date the money arrived", func() {
+				Expect(reimbursement.PaidDate).To(Equal(timeSrc.now))
+			})
+
+			It("should keep the receipt's allocation", func() {
+				Expect(db.receipts["id1"].Allocations).To(HaveLen(2))
+			})
+		})
+
+		When("the transition isn't allowed", func() {
+			BeforeEach(func() {
+				db.reimbursements["reimb-1"].Status = ReimbursementVoided
+				status = ReimbursementPaid
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("a voided reimbursement can't be marked paid"))
+			})
+		})
+	})
+
+	Describe("UpdateReimbursement", func() {
+		var (
+			reimbursement *Reimbursement
+			err           error
+		)
+
+		BeforeEach(func() {
+			db.reimbursements["reimb-1"] = &Reimbursement{
+				ID:          "reimb-1",
+				ReceiptIDs:  []string{"id1"},
+				Allocations: []Allocation{{ReimbursementID: "reimb-1", ReceiptID: "id1", Amount: 1000}},
+				TotalAmount: 1000,
+				CreatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			reimbursement = &Reimbursement{
+				ID:            "reimb-1",
+				TotalAmount:   999999,
+				PaidDate:      time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC),
+				PaymentMethod: PaymentDirectDeposit,
+				Reference:     " TXN-42 ",
+				Notes:         "Arrived in checking",
+			}
+		})
+
+		JustBeforeEach(func() {
+			err = service.UpdateReimbursement(reimbursement)
+		})
+
+		When("the payment details are valid", func() {
+			It("should save the payment details", func() {
+				Expect(db.reimbursements["reimb-1"].Reference).To(Equal("TXN-42"))
+			})
+
+			It("should keep the original amounts", func() {
+				Expect(db.reimbursements["reimb-1"].TotalAmount).To(Equal(1000))
+			})
+		})
+
+		When("the payment method is unknown", func() {
+			BeforeEach(func() {
+				reimbursement.PaymentMethod = "cash"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("invalid payment method: cash"))
+			})
+		})
+
+		When("the reimbursement doesn't exist", func() {
+			BeforeEach(func() {
+				reimbursement.ID = "missing"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("getting reimbursement: reimbursement not found"))
+			})
+		})
+	})
+
+	Describe("CreateReimbursement without an account", func() {
+		var err error
+
+		BeforeEach(func() {
+			db.accounts["hsa"] = &Account{
+				ID:              "hsa",
+				Name:            "Family HSA",
+				Type:            AccountTypeHSA,
+				EstablishedDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			}
+			db.receipts["id1"] = &Receipt{ID: "id1", Amount: 1000, Date: time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)}
+		})
+
+		JustBeforeEach(func() {
+			_, err = service.CreateReimbursement("", "", []Allocation{{ReceiptID: "id1"}})
+		})
+
+		When("the receipt predates every HSA", func() {
+			It("returns an error", func() {
+				Expect(err).To(MatchError("expense was incurred before the HSA was established: receipt id1 was incurred on 2024-01-20, before Family HSA was established on 2024-02-01"))
+			})
+		})
+
+		When("the receipt was incurred after an HSA was established", func() {
+			BeforeEach(func() {
+				db.accounts["old"] = &Account{
+					ID:              "old",
+					Name:            "Old HSA",
+					Type:            AccountTypeHSA,
+					EstablishedDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				}
+			})
+
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ListReceipts with an HSA establishment date", func() {
+		var (
+			receipts []*Receipt
+			err      error
+		)
+
+		BeforeEach(func() {
+			db.accounts["hsa"] = &Account{
+				ID:              "hsa",
+				Name:            "Family HSA",
+				Type:            AccountTypeHSA,
+				EstablishedDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			}
+			db.receipts["id1"] = &Receipt{ID: "id1", Amount: 1000, Date: time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)}
+		})
+
+		JustBeforeEach(func() {
+			receipts, err = service.ListReceipts(ReceiptFilter{})
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should flag receipts that predate the HSA", func() {
+			Expect(receipts[0].PredatesHSA).To(BeTrue())
+		})
+
+		It("should exclude them from the reimbursable amount", func() {
+			Expect(receipts[0].OutstandingAmount()).To(Equal(0))
+		})
+	})
+
+	Describe("CreateAccount", func() {
+		var (
+			account *Account
+			err     error
+		)
+
+		BeforeEach(func() {
+			account = &Account{Name: "Family HSA", Type: AccountTypeHSA}
+		})
+
+		JustBeforeEach(func() {
+			err = service.CreateAccount(account)
+		})
+
+		When("the account is valid", func() {
+			It("should save the account", func() {
+				Expect(db.accounts).To(HaveKey("test-id-123"))
+			})
+		})
+
+		When("the type is unknown", func() {
+			BeforeEach(func() {
+				account.Type = "ira"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("invalid account type: ira"))
+			})
+		})
+
+		When("an establishment date is set on a non-HSA account", func() {
+			BeforeEach(func() {
+				account.Type = AccountTypeFSA
+				account.EstablishedDate = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("an establishment date only applies to hsa accounts"))
+			})
+		})
+
+		When("the owner does not exist", func() {
+			BeforeEach(func() {
+				account.OwnerID = "missing"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("getting owner missing: member not found"))
+			})
+		})
+	})
+
+	Describe("DeleteAccount", func() {
+		var err error
+
+		BeforeEach(func() {
+			db.accounts["hsa"] = &Account{ID: "hsa", Name: "Family HSA", Type: AccountTypeHSA}
+		})
+
+		JustBeforeEach(func() {
+			err = service.DeleteAccount("hsa")
+		})
+
+		When("reimbursements were drawn from the account", func() {
+			BeforeEach(func() {
+				db.reimbursements["r1"] = &Reimbursement{ID: "r1", AccountID: "hsa"}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("account hsa still has reimbursements"))
+			})
+		})
+
+		When("the account is unused", func() {
+			It("should remove the account", func() {
+				Expect(db.accounts).NotTo(HaveKey("hsa"))
+			})
+		})
+	})
+
+	Describe("ListReimbursementsByAccount", func() {
+		var (
+			groups []*AccountReimbursements
+			err    error
+		)
+
+		BeforeEach(func() {
+			db.accounts["hsa"] = &Account{ID: "hsa", Name: "Family HSA", Type: AccountTypeHSA}
+			db.reimbursements["r1"] = &Reimbursement{ID: "r1", AccountID: "hsa", TotalAmount: 500}
+			db.reimbursements["r2"] = &Reimbursement{ID: "r2", AccountID: "hsa", TotalAmount: 700}
+			db.reimbursements["r3"] = &Reimbursement{ID: "r3", TotalAmount: 100}
+		})
+
+		JustBeforeEach(func() {
+			groups, err = service.ListReimbursementsByAccount()
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should total each account", func() {
+			Expect(groups[0].TotalAmount).To(Equal(1200))
+		})
+
+		It("should put reimbursements without an account last", func() {
+			Expect(groups[1].Account).To(BeNil())
+		})
+	})
+
+	Describe("CreateContribution", func() {
+		var (
+			contribution *Contribution
+			err          error
+		)
+
+		BeforeEach(func() {
+			db.accounts["hsa"] = &Account{ID: "hsa", Name: "Family HSA", Type: AccountTypeHSA}
+			contribution = &Contribution{
+				AccountID: "hsa",
+				Source:    ContributionIndividual,
+				Amount:    100000,
+				Date:      time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			}
+		})
+
+		JustBeforeEach(func() {
+			err = service.CreateContribution(contribution)
+		})
+
+		When("the contribution is valid", func() {
+			It("should default the tax year to the deposit year", func() {
+				Expect(db.contributions["test-id-123"].TaxYear).To(Equal(2025))
+			})
+		})
+
+		When("it counts toward the prior tax year", func() {
+			BeforeEach(func() {
+				contribution.TaxYear = 2024
+			})
+
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		When("the tax year is too far from the deposit date", func() {
+			BeforeEach(func() {
+				contribution.TaxYear = 2023
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("a contribution made on 2025-03-01 can't count toward tax year 2023"))
+			})
+		})
+
+		When("the account is not an HSA", func() {
+			BeforeEach(func() {
+				db.accounts["hsa"].Type = AccountTypeFSA
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("contributions can only be recorded for hsa accounts, not fsa"))
+			})
+		})
+
+		When("the source is unknown", func() {
+			BeforeEach(func() {
+				contribution.Source = "gift"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("invalid contribution source: gift"))
+			})
+		})
+	})
+
+	Describe("ContributionSummaries", func() {
+		var (
+			summaries []*ContributionSummary
+			err       error
+		)
+
+		BeforeEach(func() {
+			db.members["member-1"] = &Member{ID: "member-1", Name: "Alex", BirthDate: time.Date(1965, 6, 1, 0, 0, 0, 0, time.UTC)}
+			db.accounts["hsa"] = &Account{ID: "hsa", Name: "Family HSA", Type: AccountTypeHSA, Coverage: CoverageSelfOnly}
+			db.contributions["c1"] = &Contribution{ID: "c1", AccountID: "hsa", Source: ContributionEmployee, Amount: 300000, TaxYear: 2024}
+			db.contributions["c2"] = &Contribution{ID: "c2", AccountID: "hsa", Source: ContributionEmployer, Amount: 100000, TaxYear: 2024}
+		})
+
+		JustBeforeEach(func() {
+			summaries, err = service.ContributionSummaries(2024)
+		})
+
+		When("contributions are under the limit", func() {
+			It("should not return an error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should report the remaining room", func() {
+				Expect(summaries[0].Remaining).To(Equal(15000))
+			})
+
+			It("should split the total by source", func() {
+				Expect(summaries[0].Employer).To(Equal(100000))
+			})
+		})
+
+		When("contributions exceed the limit", func() {
+			BeforeEach(func() {
+				db.contributions["c3"] = &Contribution{ID: "c3", AccountID: "hsa", Source: ContributionIndividual, Amount: 50000, TaxYear: 2024}
+			})
+
+			It("should warn about the excess", func() {
+				Expect(summaries[0].Warning).To(Equal("contributions exceed the 2024 limit by 35000 cents"))
+			})
+		})
+
+		When("the owner is eligible for catch-up contributions", func() {
+			BeforeEach(func() {
+				db.accounts["hsa"].OwnerID = "member-1"
+				db.contributions["c3"] = &Contribution{ID: "c3", AccountID: "hsa", Source: ContributionIndividual, Amount: 50000, TaxYear: 2024}
+			})
+
+			It("should raise the limit", func() {
+				Expect(summaries[0].OverLimit).To(BeFalse())
+			})
+		})
+
+		When("the account has no coverage type", func() {
+			BeforeEach(func() {
+				db.accounts["hsa"].Coverage = ""
+			})
+
+			It("should warn that the limit can't be checked", func() {
+				Expect(summaries[0].Warning).To(Equal("coverage is not set for account Family HSA; the IRS limit can't be checked"))
+			})
+		})
+	})
+
+	Describe("ScanEOB", func() {
+		var (
+			eob *EOB
+			err error
+		)
+
+		JustBeforeEach(func() {
+			eob, err = service.ScanEOB("eob.pdf", []byte("pdf"), "application/pdf")
+		})
+
+		When("the scan succeeds", func() {
+			It("should convert the amounts to cents", func() {
+				Expect(eob.PatientResponsibility).To(Equal(6000))
+			})
+
+			It("should save the eob", func() {
+				Expect(db.eobs).To(HaveKey("test-id-123"))
+			})
+
+			It("should save the file to storage", func() {
+				Expect(storage.files).To(HaveKey("eob_test-id-123_eob.pdf"))
+			})
+		})
+
+		When("the service date can't be read", func() {
+			BeforeEach(func() {
+				scanner.eobData.ServiceDate = ""
+			})
+
+			It("should leave the service date unset", func() {
+				Expect(eob.ServiceDate.IsZero()).To(BeTrue())
+			})
+		})
+
+		When("scanning fails", func() {
+			BeforeEach(func() {
+				scanner.scanErr = errors.New("scan error")
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("scanning eob: scan error"))
+			})
+
+			It("should remove the saved file", func() {
+				Expect(storage.files).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("SuggestEOBMatches", func() {
+		var (
+			matches []*EOBMatch
+			err     error
+		)
+
+		BeforeEach(func() {
+			db.eobs["eob-1"] = &EOB{
+				ID:                    "eob-1",
+				Provider:              "Riverside Orthopedics",
+				ServiceDate:           time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+				BilledAmount:          45000,
+				PatientResponsibility: 6000,
+			}
+			db.receipts["bill"] = &Receipt{ID: "bill", Title: "Riverside Orthopedics PC", Date: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), Amount: 45000}
+			db.receipts["copay"] = &Receipt{ID: "copay", Title: "Ortho visit", Date: time.Date(2024, 1, 24, 0, 0, 0, 0, time.UTC), Amount: 6000}
+			db.receipts["pharmacy"] = &Receipt{ID: "pharmacy", Title: "Corner Pharmacy", Date: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), Amount: 1299}
+		})
+
+		JustBeforeEach(func() {
+			matches, err = service.SuggestEOBMatches("eob-1")
+		})
+
+		It("should rank the best match first", func() {
+			Expect(matches[0].ReceiptID).To(Equal("bill"))
+		})
+
+		It("should leave out receipts that don't match", func() {
+			Expect(matches).To(HaveLen(2))
+		})
+
+		When("a receipt is already matched to another eob", func() {
+			BeforeEach(func() {
+				db.receipts["bill"].EOBID = "eob-2"
+			})
+
+			It("should skip it", func() {
+				Expect(matches[0].ReceiptID).To(Equal("copay"))
+			})
+		})
+
+		When("the eob doesn't exist", func() {
+			JustBeforeEach(func() {
+				matches, err = service.SuggestEOBMatches("missing")
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("getting eob: eob not found"))
+			})
+		})
+	})
+
+	Describe("MatchEOB", func() {
+		var (
+			receipt *Receipt
+			err     error
+		)
+
+		BeforeEach(func() {
+			db.eobs["eob-1"] = &EOB{ID: "eob-1", PatientResponsibility: 6000}
+			db.receipts["bill"] = &Receipt{ID: "bill", Amount: 45000}
+		})
+
+		JustBeforeEach(func() {
+			receipt, err = service.MatchEOB("eob-1", "bill")
+		})
+
+		When("the receipt hasn't been reimbursed", func() {
+			It("should limit the reimbursable amount to the patient responsibility", func() {
+				Expect(receipt.ReimbursableAmount()).To(Equal(6000))
+			})
+
+			It("should link the eob to the receipt", func() {
+				Expect(db.eobs["eob-1"].ReceiptID).To(Equal("bill"))
+			})
+		})
+
+		When("the eob was matched to a different receipt", func() {
+			BeforeEach(func() {
+				db.eobs["eob-1"].ReceiptID = "old"
+				db.receipts["old"] = &Receipt{ID: "old", Amount: 6000, EOBID: "eob-1", PatientResponsibility: 6000}
+			})
+
+			It("should unlink the previous receipt", func() {
+				Expect(db.receipts["old"].EOBID).To(BeEmpty())
+			})
+		})
+
+		When("the receipt was reimbursed more than the patient responsibility", func() {
+			BeforeEach(func() {
+				db.receipts["bill"].Allocations = []Allocation{{ReimbursementID: "r1", ReceiptID: "bill", Amount: 10000}}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("receipt bill has been reimbursed 10000 cents, more than the 6000 cents patient responsibility"))
+			})
+		})
+	})
+
+	Describe("DeleteEOB", func() {
+		var err error
+
+		BeforeEach(func() {
+			db.eobs["eob-1"] = &EOB{ID: "eob-1", ReceiptID: "bill", Filename: "eob_eob-1_eob.pdf", PatientResponsibility: 6000}
+			db.receipts["bill"] = &Receipt{ID: "bill", Amount: 45000, EOBID: "eob-1", PatientResponsibility: 6000}
+			storage.files["eob_eob-1_eob.pdf"] = []byte("pdf")
+		})
+
+		JustBeforeEach(func() {
+			err = service.DeleteEOB("eob-1")
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should make the full receipt amount reimbursable again", func() {
+			Expect(db.receipts["bill"].ReimbursableAmount()).To(Equal(45000))
+		})
+
+		It("should remove the file", func() {
+			Expect(storage.files).To(BeEmpty())
+		})
+
+		It("should remove the eob", func() {
+			Expect(db.eobs).To(BeEmpty())
+		})
+	})
 })
@@ -4,6 +4,7 @@ import UploadController from "./controllers/upload_controller.js"
 import ReceiptsController from "./controllers/receipts_controller.js"
 import ReimbursementsController from "./controllers/reimbursements_controller.js"
 import ReimbursementDetailController from "./controllers/reimbursement_detail_controller.js"
+import TrashController from "./controllers/trash_controller.js"
 
 const application = Application.start()
 application.register("tabs", TabsController)
@@ -11,6 +12,7 @@ application.register("upload", UploadController)
 application.register("receipts", ReceiptsController)
 application.register("reimbursements", ReimbursementsController)
 application.register("reimbursement-detail", ReimbursementDetailController)
+application.register("trash", TrashController)
 
 // Debug: log when application is ready
 console.log("Stimulus application started", application)
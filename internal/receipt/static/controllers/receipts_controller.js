@@ -1,12 +1,13 @@
 import { Controller } from "https://cdn.skypack.dev/@hotwired/stimulus@3.2.2"
 
 export default class extends Controller {
-    static targets = ["container", "selectionBar", "selectionCount", "totalCount", "totalValue"]
+    static targets = ["container", "selectionBar", "selectionCount", "totalCount", "totalValue", "totalOutstanding", "accountSelect", "statusSelect", "needsReviewFilter"]
     static values = { selectedIds: Array }
 
     connect() {
         this.selectedIdsValue = []
         this.load()
+        this.loadAccounts()
         
         // Listen for reload and clear selection events
         this.reloadHandler = () => this.load()
@@ -22,12 +23,14 @@ export default class extends Controller {
 
     async load() {
         try {
-            const response = await fetch("/api/receipts")
+            const query = this.needsReviewFilterTarget.checked ? "?needs_review=true" : ""
+            const response = await fetch("/api/receipts" + query)
             if (!response.ok) throw new Error("Failed to load receipts")
 
             const receipts = await response.json()
             // Ensure receipts is always an array, even if backend returns null
             const receiptsArray = receipts || []
+            this.receipts = receiptsArray
             this.updateSummary(receiptsArray)
             this.display(receiptsArray)
         } catch (error) {
@@ -36,6 +39,21 @@ export default class extends Controller {
         }
     }
 
+    async loadAccounts() {
+        try {
+            const response = await fetch("/api/accounts")
+            if (!response.ok) throw new Error("Failed to load accounts")
+
+            const accounts = (await response.json()) || []
+            this.accountSelectTarget.innerHTML = '<option value="">No account</option>' +
+                accounts.map(account =>
+                    `<option value="${this.escapeHtml(account.id)}">${this.escapeHtml(account.name)} (${this.escapeHtml(account.type.toUpperCase())})</option>`
+                ).join("")
+        } catch (error) {
+            console.error("Error loading accounts:", error)
+        }
+    }
+
     display(receipts) {
         // Handle null or undefined receipts
         if (!receipts || receipts.length === 0) {
@@ -54,11 +72,25 @@ export default class extends Controller {
         this.containerTarget.innerHTML = receipts.map(receipt => {
             const date = new Date(receipt.date).toLocaleDateString()
             const amount = receipt.amount ? "$" + (receipt.amount / 100).toFixed(2) : "N/A"
-            const isReimbursed = receipt.reimbursement_id && receipt.reimbursement_id !== ""
+            const isReimbursed = (receipt.allocations || []).length > 0
+            const outstanding = this.outstandingAmount(receipt)
             const isSelected = this.selectedIdsValue.includes(receipt.id)
-            const checkbox = !isReimbursed ? 
+            const checkbox = outstanding > 0 ?
                 `<input type="checkbox" data-action="change->receipts#toggle" data-receipt-id="${this.escapeHtml(receipt.id)}" ${isSelected ? "checked" : ""}>` : ""
-            const badge = isReimbursed ? '<span class="badge">Reimbursed</span>' : ""
+            let badge = ""
+            if (isReimbursed) {
+                badge = outstanding > 0 ?
+                    `<span class="badge">Partially reimbursed ($${(outstanding / 100).toFixed(2)} left)</span>` :
+                    '<span class="badge">Reimbursed</span>'
+            }
+            // Itemized receipts are only reimbursable up to their eligible subtotal
+            const eligible = (receipt.line_items || []).length > 0 ?
+                ` • Eligible $${(this.reimbursableAmount(receipt) / 100).toFixed(2)}` : ""
+            const mismatch = receipt.line_items_mismatch ? " • Items don't match total" : ""
+            const predates = receipt.predates_hsa ? " • Predates HSA" : ""
+            const review = receipt.needs_review ? ` • Check ${this.fieldsToReview(receipt).join(", ")}` : ""
+            const eob = receipt.eob_id ?
+                ` • EOB: you owe $${((receipt.patient_responsibility || 0) / 100).toFixed(2)}` : ""
             
             // Extract display filename (remove ID prefix if present)
             let displayFilename = receipt.filename
@@ -77,7 +109,7 @@ export default class extends Controller {
                     ${checkbox}
                     <div class="receipt-info-content">
                         <div class="receipt-title">${this.escapeHtml(receipt.title)} ${badge}</div>
-                        <div class="receipt-meta">${date} • ${this.escapeHtml(displayFilename)}</div>
+                        <div class="receipt-meta">${date}${receipt.category ? " • " + this.escapeHtml(receipt.category) : ""}${eligible}${mismatch}${predates}${review}${eob} • ${this.escapeHtml(displayFilename)}</div>
                     </div>
                 </div>
                 <div class="receipt-right">
@@ -92,6 +124,14 @@ export default class extends Controller {
         }).join("")
     }
 
+    // Scanned details that were defaulted or read with low confidence
+    fieldsToReview(receipt) {
+        return Object.entries(receipt.fields || {})
+            .filter(([, field]) => field.provenance === "defaulted" ||
+                (field.provenance !== "edited" && field.confidence > 0 && field.confidence < 0.6))
+            .map(([name]) => name)
+    }
+
     toggle(event) {
         const receiptId = event.currentTarget.dataset.receiptId
         if (this.selectedIdsValue.includes(receiptId)) {
@@ -125,17 +165,35 @@ export default class extends Controller {
             return
         }
 
-        if (!confirm("Mark " + this.selectedIdsValue.length + " receipt(s) as reimbursed?")) {
+        let body = { receipt_ids: this.selectedIdsValue }
+        if (this.selectedIdsValue.length === 1) {
+            // A single receipt can be reimbursed partially, e.g. before insurance settles
+            const receipt = (this.receipts || []).find(r => r.id === this.selectedIdsValue[0])
+            const outstanding = receipt ? this.outstandingAmount(receipt) : 0
+            const input = prompt("Amount to reimburse (USD)", (outstanding / 100).toFixed(2))
+            if (input === null) {
+                return
+            }
+            const amount = Math.round(parseFloat(input) * 100)
+            if (!(amount > 0)) {
+                alert("Please enter an amount greater than zero")
+                return
+            }
+            body = { allocations: [{ receipt_id: this.selectedIdsValue[0], amount: amount }] }
+        } else if (!confirm("Mark " + this.selectedIdsValue.length + " receipt(s) as reimbursed?")) {
             return
         }
 
+        if (this.accountSelectTarget.value) {
+            body.account_id = this.accountSelectTarget.value
+        }
+        body.status = this.statusSelectTarget.value
+
         try {
             const response = await fetch("/api/reimbursements", {
                 method: "POST",
                 headers: { "Content-Type": "application/json" },
-                body: JSON.stringify({
-                    receipt_ids: this.selectedIdsValue
-                })
+                body: JSON.stringify(body)
             })
 
             if (!response.ok) {
@@ -200,12 +258,17 @@ export default class extends Controller {
         const receiptTitleInput = modal.querySelector('[data-edit-target="receiptTitle"]')
         const receiptDateInput = modal.querySelector('[data-edit-target="receiptDate"]')
         const receiptAmountInput = modal.querySelector('[data-edit-target="receiptAmount"]')
+        const receiptCategoryInput = modal.querySelector('[data-edit-target="receiptCategory"]')
         const previewContainer = modal.querySelector('[data-edit-target="previewContainer"]')
         
+        // Keep the full receipt so fields not shown in the form survive the update
+        this.editingReceipt = receipt
         receiptIdInput.value = receipt.id
         receiptTitleInput.value = receipt.title
         receiptDateInput.value = new Date(receipt.date).toISOString().split("T")[0]
         receiptAmountInput.value = (receipt.amount / 100).toFixed(2)
+        receiptCategoryInput.value = receipt.category || "other"
+        this.renderAttachments(receipt)
         
         // Show preview
         previewContainer.innerHTML = ""
@@ -267,6 +330,35 @@ export default class extends Controller {
                         <label>Amount (USD)</label>
                         <input type="number" step="0.01" data-edit-target="receiptAmount" required>
                     </div>
+                    <div class="form-group">
+                        <label>Category</label>
+                        <select data-edit-target="receiptCategory">
+                            <option value="medical">Medical</option>
+                            <option value="dental">Dental</option>
+                            <option value="vision">Vision</option>
+                            <option value="pharmacy">Pharmacy/Rx</option>
+                            <option value="otc">OTC</option>
+                            <option value="mental_health">Mental Health</option>
+                            <option value="mileage">Mileage</option>
+                            <option value="premiums">Premiums</option>
+                            <option value="other">Other</option>
+                        </select>
+                    </div>
+                    <div class="form-group">
+                        <label>Attachments</label>
+                        <ul class="attachment-list" data-edit-target="attachmentList"></ul>
+                        <div class="attachment-upload">
+                            <select data-edit-target="attachmentRole">
+                                <option value="bill">Provider bill</option>
+                                <option value="eob">Insurance EOB</option>
+                                <option value="statement">Card statement</option>
+                                <option value="receipt">Receipt</option>
+                                <option value="other">Other</option>
+                            </select>
+                            <input type="file" accept="image/*,application/pdf" data-edit-target="attachmentFile">
+                            <button type="button" class="btn-small attach-btn">Attach</button>
+                        </div>
+                    </div>
                     
                     <div class="modal-actions">
                         <button type="button" class="cancel-edit-btn">Cancel</button>
@@ -283,6 +375,21 @@ export default class extends Controller {
             cancelBtn.addEventListener("click", () => this.closeEditModal())
         }
         
+        // Add attachment handlers
+        const attachBtn = modal.querySelector(".attach-btn")
+        if (attachBtn) {
+            attachBtn.addEventListener("click", () => this.addAttachment())
+        }
+        const attachmentList = modal.querySelector('[data-edit-target="attachmentList"]')
+        if (attachmentList) {
+            attachmentList.addEventListener("click", (e) => {
+                const button = e.target.closest("[data-attachment-id]")
+                if (button) {
+                    this.removeAttachment(button.dataset.attachmentId)
+                }
+            })
+        }
+        
         // Add form submission handler
         const form = modal.querySelector(".edit-receipt-form")
         if (form) {
@@ -295,6 +402,77 @@ export default class extends Controller {
         return modal
     }
 
+    renderAttachments(receipt) {
+        const list = document.querySelector('#editModal [data-edit-target="attachmentList"]')
+        if (!list) {
+            return
+        }
+        
+        list.innerHTML = (receipt.attachments || []).map(attachment => {
+            const url = "/api/receipts/" + encodeURIComponent(receipt.id) + "/attachments/" + encodeURIComponent(attachment.id) + "/file"
+            // The scanned receipt file can only be removed with the receipt itself
+            const removable = attachment.filename !== receipt.filename
+            return `<li>
+                <a href="${url}" target="_blank">${this.escapeHtml(attachment.name)}</a>
+                <span class="badge">${this.escapeHtml(attachment.role)}</span>
+                ${removable ? `<button type="button" class="btn-small btn-danger" data-attachment-id="${this.escapeHtml(attachment.id)}">Remove</button>` : ""}
+            </li>`
+        }).join("")
+    }
+
+    async addAttachment() {
+        const modal = document.getElementById("editModal")
+        const fileInput = modal.querySelector('[data-edit-target="attachmentFile"]')
+        const role = modal.querySelector('[data-edit-target="attachmentRole"]').value
+        if (!fileInput.files.length) {
+            alert("Please choose a file to attach")
+            return
+        }
+        
+        const formData = new FormData()
+        formData.append("file", fileInput.files[0])
+        formData.append("role", role)
+        
+        try {
+            const response = await fetch("/api/receipts/" + this.editingReceipt.id + "/attachments", {
+                method: "POST",
+                body: formData
+            })
+            if (!response.ok) {
+                const errorData = await response.json().catch(() => ({}))
+                throw new Error(errorData.error || "Failed to add attachment")
+            }
+            
+            const attachment = await response.json()
+            this.editingReceipt.attachments = [...(this.editingReceipt.attachments || []), attachment]
+            fileInput.value = ""
+            this.renderAttachments(this.editingReceipt)
+        } catch (error) {
+            alert("Error adding attachment: " + error.message)
+        }
+    }
+
+    async removeAttachment(attachmentId) {
+        if (!confirm("Remove this attachment?")) {
+            return
+        }
+        
+        try {
+            const response = await fetch("/api/receipts/" + this.editingReceipt.id + "/attachments/" + attachmentId, {
+                method: "DELETE"
+            })
+            if (!response.ok) {
+                const errorData = await response.json().catch(() => ({}))
+                throw new Error(errorData.error || "Failed to remove attachment")
+            }
+            
+            this.editingReceipt.attachments = this.editingReceipt.attachments.filter(a => a.id !== attachmentId)
+            this.renderAttachments(this.editingReceipt)
+        } catch (error) {
+            alert("Error removing attachment: " + error.message)
+        }
+    }
+
     async updateReceipt(event) {
         event.preventDefault()
         
@@ -308,6 +486,7 @@ export default class extends Controller {
         const title = modal.querySelector('[data-edit-target="receiptTitle"]').value
         const date = modal.querySelector('[data-edit-target="receiptDate"]').value
         const amount = modal.querySelector('[data-edit-target="receiptAmount"]').value
+        const category = modal.querySelector('[data-edit-target="receiptCategory"]').value
         
         if (!receiptId || !title || !date || !amount) {
             alert("Please fill in all fields")
@@ -315,10 +494,12 @@ export default class extends Controller {
         }
         
         const receiptData = {
+            ...this.editingReceipt,
             id: receiptId,
             title: title,
             date: new Date(date).toISOString(),
-            amount: Math.round(parseFloat(amount) * 100)
+            amount: Math.round(parseFloat(amount) * 100),
+            category: category
         }
         
         try {
@@ -338,6 +519,16 @@ export default class extends Controller {
                 } catch (e) {
                     errorMessage = response.statusText || "Failed to update receipt"
                 }
+
+                // Reimbursed receipts are locked; correcting one needs a recorded reason
+                if (response.status === 409 && errorMessage.includes("locked")) {
+                    const reason = prompt(`${errorMessage}\n\nTo correct it anyway, enter the reason for the change:`)
+                    if (reason && reason.trim()) {
+                        await this.unlockReceipt(receiptId, reason)
+                        return this.updateReceipt(event)
+                    }
+                    return
+                }
                 throw new Error(errorMessage)
             }
             
@@ -352,6 +543,18 @@ export default class extends Controller {
         }
     }
 
+    async unlockReceipt(receiptId, reason) {
+        const response = await fetch(`/api/receipts/${receiptId}/unlock`, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ reason })
+        })
+        if (!response.ok) {
+            const errorData = await response.json().catch(() => ({}))
+            throw new Error(errorData.error || "Failed to unlock receipt")
+        }
+    }
+
     closeEditModal() {
         const modal = document.getElementById("editModal")
         if (modal) {
@@ -378,7 +581,7 @@ export default class extends Controller {
 
     async delete(event) {
         const receiptId = event.currentTarget.dataset.receiptId
-        if (!confirm("Are you sure you want to delete this receipt?")) {
+        if (!confirm("Move this receipt to the trash? You can restore it from the Trash tab.")) {
             return
         }
 
@@ -391,6 +594,7 @@ export default class extends Controller {
 
             this.selectedIdsValue = this.selectedIdsValue.filter(id => id !== receiptId)
             this.load()
+            window.dispatchEvent(new CustomEvent("trash:reload"))
         } catch (error) {
             alert("Error deleting receipt: " + error.message)
         }
@@ -414,6 +618,32 @@ export default class extends Controller {
 
         this.totalCountTarget.textContent = totalCount.toString()
         this.totalValueTarget.textContent = "$" + (totalValue / 100).toFixed(2)
+
+        if (this.hasTotalOutstandingTarget) {
+            const totalOutstanding = receiptsArray.reduce((sum, receipt) => sum + this.outstandingAmount(receipt), 0)
+            this.totalOutstandingTarget.textContent = "$" + (totalOutstanding / 100).toFixed(2)
+        }
+    }
+
+    reimbursableAmount(receipt) {
+        // Expenses incurred before the HSA was established can never be reimbursed
+        if (receipt.predates_hsa) {
+            return 0
+        }
+        // A matched EOB limits the claim to what insurance left the patient to pay
+        if (receipt.eob_id) {
+            return receipt.patient_responsibility || 0
+        }
+        const lineItems = receipt.line_items || []
+        if (lineItems.length === 0) {
+            return receipt.amount || 0
+        }
+        return lineItems.filter(item => item.eligible).reduce((sum, item) => sum + item.amount, 0)
+    }
+
+    outstandingAmount(receipt) {
+        const reimbursed = (receipt.allocations || []).reduce((sum, allocation) => sum + allocation.amount, 0)
+        return Math.max(0, this.reimbursableAmount(receipt) - reimbursed)
     }
 
     escapeHtml(text) {
@@ -23,30 +23,82 @@ export default class extends Controller {
             if (!response.ok) throw new Error("Failed to load reimbursement")
 
             const data = await response.json()
-            this.display(data.reimbursement, data.receipts)
+            this.display(data.reimbursement, data.receipts, data.account, data.missing_receipt_ids || [])
         } catch (error) {
             alert("Error loading reimbursement: " + error.message)
         }
     }
 
-    display(reimbursement, receipts) {
+    display(reimbursement, receipts, account, missingReceiptIds = []) {
+        this.reimbursement = reimbursement
         const date = new Date(reimbursement.created_at).toLocaleDateString()
         const amount = "$" + (reimbursement.total_amount / 100).toFixed(2)
+        const paidDate = reimbursement.paid_date ? reimbursement.paid_date.split("T")[0] : ""
+        const methods = [
+            ["", "Not recorded"],
+            ["custodian_transfer", "Custodian transfer"],
+            ["check", "Check"],
+            ["direct_deposit", "Direct deposit"]
+        ]
+        const status = reimbursement.status || "paid"
+        // Transitions the server allows from each status
+        const transitions = {
+            draft: [["request", "Mark Requested"], ["pay", "Mark Paid"], ["void", "Void"]],
+            requested: [["pay", "Mark Paid"], ["reject", "Mark Rejected"], ["void", "Void"]],
+            paid: [["void", "Void"]]
+        }
+        const actions = (transitions[status] || []).map(([action, label]) =>
+            `<button class="btn-small${action === "void" || action === "reject" ? " btn-danger" : ""}" data-action="click->reimbursement-detail#transition" data-transition="${action}">${label}</button>`
+        ).join("")
+        const history = (reimbursement.history || []).map(change =>
+            `<li>${new Date(change.at).toLocaleString()}: ${this.escapeHtml(change.to)}${change.note ? " — " + this.escapeHtml(change.note) : ""}</li>`
+        ).join("")
+        const methodOptions = methods.map(([value, label]) =>
+            `<option value="${value}" ${reimbursement.payment_method === value || (!reimbursement.payment_method && value === "") ? "selected" : ""}>${label}</option>`
+        ).join("")
 
         let html = `<div class="reimbursement-header">
             <h2>Reimbursement Details</h2>
             <div style="margin-top: 10px;">
                 <div><strong>ID:</strong> ${this.escapeHtml(reimbursement.id)}</div>
                 <div><strong>Date:</strong> ${date}</div>
+                ${account ? `<div><strong>Account:</strong> ${this.escapeHtml(account.name)} (${this.escapeHtml(account.type.toUpperCase())})</div>` : ""}
                 <div><strong>Total Amount:</strong> ${amount}</div>
                 <div><strong>Receipts:</strong> ${receipts.length}</div>
+                <div><strong>Status:</strong> <span class="badge status-${this.escapeHtml(status)}">${this.escapeHtml(status)}</span></div>
             </div>
+            ${actions ? `<div class="status-actions">${actions}</div>` : ""}
+            ${history ? `<ul class="status-history">${history}</ul>` : ""}
         </div>
+        <form class="payment-form" data-action="submit->reimbursement-detail#savePayment">
+            <h3>Payment</h3>
+            <div class="form-group">
+                <label>Date received</label>
+                <input type="date" name="paid_date" value="${paidDate}">
+            </div>
+            <div class="form-group">
+                <label>Method</label>
+                <select name="payment_method">${methodOptions}</select>
+            </div>
+            <div class="form-group">
+                <label>Transaction reference</label>
+                <input type="text" name="reference" value="${this.escapeHtml(reimbursement.reference || "")}">
+            </div>
+            <div class="form-group">
+                <label>Notes</label>
+                <textarea name="notes" rows="2">${this.escapeHtml(reimbursement.notes || "")}</textarea>
+            </div>
+            <button type="submit">Save Payment Details</button>
+        </form>
         <h3 style="margin-bottom: 15px;">Receipts</h3>`
 
         receipts.forEach(receipt => {
             const receiptDate = new Date(receipt.date).toLocaleDateString()
-            const receiptAmount = "$" + (receipt.amount / 100).toFixed(2)
+            // Show the amount this reimbursement paid against the receipt
+            const allocation = (reimbursement.allocations || []).find(a => a.receipt_id === receipt.id)
+            const paid = allocation ? allocation.amount : receipt.amount
+            const receiptAmount = "$" + (paid / 100).toFixed(2) +
+                (paid !== receipt.amount ? ` of $${(receipt.amount / 100).toFixed(2)}` : "")
             html += `<div class="receipt-item">
                 <div class="receipt-info">
                     <div>
@@ -63,6 +115,13 @@ export default class extends Controller {
             </div>`
         })
 
+        // Receipts removed from the database can't be shown, but the reimbursement can still be voided
+        if (missingReceiptIds.length > 0) {
+            html += `<div class="receipt-item missing-receipts">
+                <div class="receipt-info">Missing receipts: ${missingReceiptIds.map(id => this.escapeHtml(id)).join(", ")}</div>
+            </div>`
+        }
+
         this.contentTarget.innerHTML = html
         this.element.style.display = "block"
         
@@ -73,6 +132,63 @@ export default class extends Controller {
         }
     }
 
+    async savePayment(event) {
+        event.preventDefault()
+        const form = event.currentTarget
+        const paidDate = form.elements.paid_date.value
+
+        try {
+            const response = await fetch("/api/reimbursements/" + this.reimbursement.id, {
+                method: "PUT",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({
+                    paid_date: paidDate ? new Date(paidDate).toISOString() : undefined,
+                    payment_method: form.elements.payment_method.value,
+                    reference: form.elements.reference.value,
+                    notes: form.elements.notes.value
+                })
+            })
+            if (!response.ok) {
+                const errorData = await response.json().catch(() => ({}))
+                throw new Error(errorData.error || "Failed to save payment details")
+            }
+            this.reimbursement = await response.json()
+            alert("Payment details saved")
+        } catch (error) {
+            alert("Error saving payment details: " + error.message)
+        }
+    }
+
+    async transition(event) {
+        const action = event.currentTarget.dataset.transition
+        let note = ""
+        if (action === "void" || action === "reject") {
+            // Voided and rejected reimbursements release their receipts, so confirm and ask why
+            note = prompt(action === "void" ?
+                "Void this reimbursement? Its receipts will be outstanding again. Reason:" :
+                "Mark this claim rejected? Its receipts will be outstanding again. Reason:")
+            if (note === null) {
+                return
+            }
+        }
+
+        try {
+            const response = await fetch(`/api/reimbursements/${this.reimbursement.id}/${action}`, {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ note: note })
+            })
+            if (!response.ok) {
+                const errorData = await response.json().catch(() => ({}))
+                throw new Error(errorData.error || "Failed to update reimbursement")
+            }
+            window.dispatchEvent(new CustomEvent("receipts:reload"))
+            this.show(this.reimbursement.id)
+        } catch (error) {
+            alert("Error updating reimbursement: " + error.message)
+        }
+    }
+
     back() {
         this.element.style.display = "none"
         
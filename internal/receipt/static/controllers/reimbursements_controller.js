@@ -17,34 +17,50 @@ export default class extends Controller {
 
     async load() {
         try {
-            const response = await fetch("/api/reimbursements")
+            const response = await fetch("/api/reimbursements?group_by=account")
             if (!response.ok) throw new Error("Failed to load reimbursements")
 
-            const reimbursements = await response.json()
-            this.display(reimbursements)
+            const groups = await response.json()
+            this.display(groups)
         } catch (error) {
             this.containerTarget.innerHTML = 
                 '<div class="empty-state">Error loading reimbursements: ' + error.message + "</div>"
         }
     }
 
-    display(reimbursements) {
-        if (!reimbursements || reimbursements.length === 0) {
+    display(groups) {
+        const nonEmpty = (groups || []).filter(group => group.reimbursements.length > 0)
+        if (nonEmpty.length === 0) {
             this.containerTarget.innerHTML = '<div class="empty-state">No reimbursements yet.</div>'
             return
         }
 
+        this.containerTarget.innerHTML = nonEmpty.map(group => {
+            const name = group.account
+                ? this.escapeHtml(group.account.name) + " (" + this.escapeHtml(group.account.type.toUpperCase()) + ")"
+                : "No account"
+            const total = "$" + (group.total_amount / 100).toFixed(2)
+
+            return `<div class="account-group-header">
+                <span>${name}</span>
+                <span>${total}</span>
+            </div>` + this.renderReimbursements(group.reimbursements)
+        }).join("")
+    }
+
+    renderReimbursements(reimbursements) {
         // Sort by date (newest first)
         reimbursements.sort((a, b) => new Date(b.created_at) - new Date(a.created_at))
 
-        this.containerTarget.innerHTML = reimbursements.map(reimbursement => {
+        return reimbursements.map(reimbursement => {
             const date = new Date(reimbursement.created_at).toLocaleDateString()
             const amount = "$" + (reimbursement.total_amount / 100).toFixed(2)
             const receiptCount = reimbursement.receipt_ids.length
+            const status = reimbursement.status || "paid"
 
             return `<div class="reimbursement-item">
                 <div class="reimbursement-info">
-                    <div class="reimbursement-title">Reimbursement #${this.escapeHtml(reimbursement.id.substring(0, 8))}</div>
+                    <div class="reimbursement-title">Reimbursement #${this.escapeHtml(reimbursement.id.substring(0, 8))} <span class="badge status-${this.escapeHtml(status)}">${this.escapeHtml(status)}</span></div>
                     <div class="reimbursement-meta">${date} • ${receiptCount} receipt${receiptCount > 1 ? "s" : ""}</div>
                 </div>
                 <div style="display: flex; align-items: center;">
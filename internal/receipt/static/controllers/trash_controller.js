@@ -0,0 +1,92 @@
+import { Controller } from "https://cdn.skypack.dev/@hotwired/stimulus@3.2.2"
+
+export default class extends Controller {
+    static targets = ["container"]
+
+    connect() {
+        this.load()
+
+        // Listen for reload events
+        this.reloadHandler = () => this.load()
+        window.addEventListener("trash:reload", this.reloadHandler)
+    }
+
+    disconnect() {
+        window.removeEventListener("trash:reload", this.reloadHandler)
+    }
+
+    async load() {
+        try {
+            const response = await fetch("/api/trash")
+            if (!response.ok) throw new Error("Failed to load trash")
+
+            const receipts = await response.json()
+            this.display(receipts)
+        } catch (error) {
+            this.containerTarget.innerHTML =
+                '<div class="empty-state">Error loading trash: ' + error.message + "</div>"
+        }
+    }
+
+    display(receipts) {
+        if (!receipts || receipts.length === 0) {
+            this.containerTarget.innerHTML = '<div class="empty-state">The trash is empty.</div>'
+            return
+        }
+
+        this.containerTarget.innerHTML = receipts.map(receipt => {
+            const deleted = new Date(receipt.deleted_at).toLocaleDateString()
+            const amount = "$" + (receipt.amount / 100).toFixed(2)
+            const id = this.escapeHtml(receipt.id)
+
+            return `<div class="reimbursement-item">
+                <div class="reimbursement-info">
+                    <div class="reimbursement-title">${this.escapeHtml(receipt.title || "Untitled receipt")}</div>
+                    <div class="reimbursement-meta">Deleted ${deleted}</div>
+                </div>
+                <div style="display: flex; align-items: center;">
+                    <span class="reimbursement-amount">${amount}</span>
+                    <div class="reimbursement-actions">
+                        <button class="btn-small" data-action="click->trash#restore" data-receipt-id="${id}">Restore</button>
+                        <button class="btn-small btn-danger" data-action="click->trash#purge" data-receipt-id="${id}">Delete Forever</button>
+                    </div>
+                </div>
+            </div>`
+        }).join("")
+    }
+
+    async restore(event) {
+        const receiptId = event.currentTarget.dataset.receiptId
+        try {
+            const response = await fetch(`/api/trash/${receiptId}/restore`, { method: "POST" })
+            if (!response.ok) throw new Error("Restore failed")
+
+            this.load()
+            window.dispatchEvent(new CustomEvent("receipts:reload"))
+        } catch (error) {
+            alert("Error restoring receipt: " + error.message)
+        }
+    }
+
+    async purge(event) {
+        const receiptId = event.currentTarget.dataset.receiptId
+        if (!confirm("Permanently delete this receipt and its files? This can't be undone.")) {
+            return
+        }
+
+        try {
+            const response = await fetch(`/api/trash/${receiptId}`, { method: "DELETE" })
+            if (!response.ok) throw new Error("Delete failed")
+
+            this.load()
+        } catch (error) {
+            alert("Error deleting receipt: " + error.message)
+        }
+    }
+
+    escapeHtml(text) {
+        const div = document.createElement("div")
+        div.textContent = text
+        return div.innerHTML
+    }
+}
@@ -3,7 +3,8 @@ import { Controller } from "https://cdn.skypack.dev/@hotwired/stimulus@3.2.2"
 export default class extends Controller {
     static targets = ["fileInput", "uploadBtn", "status", "progress", "progressFill", "progressText", 
                       "modal", "receiptId", "receiptFilename", "receiptContentType", 
-                      "receiptTitle", "receiptDate", "receiptAmount", "previewContainer"]
+                      "receiptTitle", "receiptDate", "receiptAmount", "receiptCategory", "previewContainer",
+                      "eobInput", "eobBtn", "duplicateWarning", "documentType"]
 
     connect() {
         console.log("Upload controller connected")
@@ -40,6 +41,7 @@ export default class extends Controller {
                 try {
                     const formData = new FormData()
                     formData.append("file", file)
+                    formData.append("document_type", this.documentTypeTarget.value)
 
                     const response = await fetch("/api/receipts/scan", {
                         method: "POST",
@@ -161,11 +163,68 @@ export default class extends Controller {
         this.uploadBtnTarget.disabled = false
     }
 
+    async submitEOB(event) {
+        event.preventDefault()
+
+        const file = this.eobInputTarget.files[0]
+        if (!file) {
+            this.showStatus("Please select an EOB to upload", "error")
+            return
+        }
+
+        this.eobBtnTarget.disabled = true
+        this.hideStatus()
+
+        try {
+            const formData = new FormData()
+            formData.append("file", file)
+
+            const response = await fetch("/api/eobs/scan", {
+                method: "POST",
+                body: formData
+            })
+            if (!response.ok) {
+                const errorData = await response.json().catch(() => ({}))
+                throw new Error(errorData.error || "Failed to scan EOB")
+            }
+
+            const { eob, matches } = await response.json()
+            const owed = `$${(eob.patient_responsibility / 100).toFixed(2)}`
+            const best = matches && matches[0]
+
+            if (best && confirm(`Match the EOB from ${eob.provider} (you owe ${owed}) to "${best.title}" (${best.reasons.join(", ")})?`)) {
+                const matchResponse = await fetch(`/api/eobs/${eob.id}/match`, {
+                    method: "PUT",
+                    headers: { "Content-Type": "application/json" },
+                    body: JSON.stringify({ receipt_id: best.receipt_id })
+                })
+                if (!matchResponse.ok) {
+                    const errorData = await matchResponse.json().catch(() => ({}))
+                    throw new Error(errorData.error || "Failed to match EOB")
+                }
+                this.showStatus(`EOB matched to "${best.title}". You owe ${owed}.`, "success")
+                window.dispatchEvent(new CustomEvent("receipts:reload"))
+            } else {
+                this.showStatus(`EOB from ${eob.provider} saved without a matching receipt.`, "success")
+            }
+        } catch (error) {
+            console.error("Error uploading EOB:", error)
+            this.showStatus(error.message, "error")
+        }
+
+        this.eobInputTarget.value = ""
+        this.eobBtnTarget.disabled = false
+    }
+
     reviewReceipt(data, file) {
         return new Promise((resolve, reject) => {
             this.reviewResolve = resolve
             this.reviewReject = reject
 
+            // Keep the full scan result so extracted fields not shown in the form are saved too
+            const { duplicates, ...receipt } = data
+            this.scanResult = receipt
+            this.showDuplicates(duplicates || [])
             this.receiptIdTarget.value = data.id
             this.receiptFilenameTarget.value = data.filename
             this.receiptContentTypeTarget.value = data.content_type
@@ -173,6 +232,7 @@ export default class extends Controller {
             // Date needs to be YYYY-MM-DD for input[type=date]
             this.receiptDateTarget.value = data.date.split("T")[0]
             this.receiptAmountTarget.value = (data.amount / 100).toFixed(2)
+            this.receiptCategoryTarget.value = data.category || "other"
 
             // Show preview
             this.previewTargetUrl = URL.createObjectURL(file)
@@ -195,20 +255,39 @@ export default class extends Controller {
         })
     }
 
+    showDuplicates(duplicates) {
+        if (duplicates.length === 0) {
+            this.duplicateWarningTarget.style.display = "none"
+            this.duplicateWarningTarget.innerHTML = ""
+            return
+        }
+
+        const items = duplicates.map(duplicate => {
+            const div = document.createElement("div")
+            div.textContent = `${duplicate.title} (${duplicate.reasons.join(", ")})`
+            return `<li>${div.innerHTML}</li>`
+        }).join("")
+        this.duplicateWarningTarget.innerHTML =
+            `<strong>This may be a duplicate of:</strong><ul>${items}</ul>Skip it if you've already uploaded this receipt.`
+        this.duplicateWarningTarget.style.display = "block"
+    }
+
     async confirmReceipt(event) {
         event.preventDefault()
         
         const receiptData = {
+            ...this.scanResult,
             id: this.receiptIdTarget.value,
             filename: this.receiptFilenameTarget.value,
             content_type: this.receiptContentTypeTarget.value,
             title: this.receiptTitleTarget.value,
             date: new Date(this.receiptDateTarget.value).toISOString(),
-            amount: Math.round(parseFloat(this.receiptAmountTarget.value) * 100)
+            amount: Math.round(parseFloat(this.receiptAmountTarget.value) * 100),
+            category: this.receiptCategoryTarget.value
         }
 
         try {
-            const response = await fetch("/api/receipts", {
+            const response = await fetch(`/api/receipts/${receiptData.id}/confirm`, {
                 method: "POST",
                 headers: { "Content-Type": "application/json" },
                 body: JSON.stringify(receiptData)
@@ -229,6 +308,11 @@ export default class extends Controller {
 
     cancelReview() {
         const reject = this.reviewReject
+        // Discard the draft saved by the scan; if this fails the sweeper removes it later
+        if (this.scanResult) {
+            fetch(`/api/receipts/${this.scanResult.id}`, { method: "DELETE" })
+                .catch(error => console.error("Error discarding draft:", error))
+        }
         this.closeModal()
         if (reject) reject(new Error("Cancelled by user"))
     }
@@ -237,6 +321,7 @@ export default class extends Controller {
         this.modalTarget.style.display = "none"
         this.reviewResolve = null
         this.reviewReject = null
+        this.scanResult = null
         
         // Clean up preview URL
         if (this.previewTargetUrl) {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Storage defines the interface for file storage operations
@@ -16,6 +17,24 @@ type Storage interface {
 
 	// Delete removes a file
 	Delete(path string) error
+
+	// List returns every stored file, not counting those in the trash
+	List() ([]StoredFile, error)
+
+	// Trash moves a file into the trash area
+	Trash(path string) error
+
+	// Restore moves a file out of the trash area
+	Restore(path string) error
+
+	// Purge permanently removes a file from the trash area
+	Purge(path string) error
+}
+
+// StoredFile is a file in storage
+type StoredFile struct {
+	Path    string
+	ModTime time.Time
 }
 
 // LocalStorage implements the Storage interface using local filesystem
@@ -23,6 +42,9 @@ type LocalStorage struct {
 	basePath string
 }
 
+// trashDir is the directory under the storage path that trashed files are moved into
+const trashDir = ".trash"
+
 // NewLocalStorage creates a new LocalStorage instance
 func NewLocalStorage(basePath string) (*LocalStorage, error) {
 	// Create directory if it doesn't exist
@@ -63,3 +85,50 @@ func (l *LocalStorage) Delete(path string) error {
 	return nil
 }
 
+// List returns the files in local storage
+func (l *LocalStorage) List() ([]StoredFile, error) {
+	entries, err := os.ReadDir(l.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading storage directory: %w", err)
+	}
+
+	files := make([]StoredFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("reading file info: %w", err)
+		}
+		files = append(files, StoredFile{Path: entry.Name(), ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Trash moves a file into the trash directory
+func (l *LocalStorage) Trash(path string) error {
+	if err := os.MkdirAll(filepath.Join(l.basePath, trashDir), 0755); err != nil {
+		return fmt.Errorf("creating trash directory: %w", err)
+	}
+	if err := os.Rename(filepath.Join(l.basePath, path), filepath.Join(l.basePath, trashDir, path)); err != nil {
+		return fmt.Errorf("moving file to trash: %w", err)
+	}
+	return nil
+}
+
+// Restore moves a file from the trash directory back into storage
+func (l *LocalStorage) Restore(path string) error {
+	if err := os.Rename(filepath.Join(l.basePath, trashDir, path), filepath.Join(l.basePath, path)); err != nil {
+		return fmt.Errorf("restoring file from trash: %w", err)
+	}
+	return nil
+}
+
+// Purge removes a file from the trash directory
+func (l *LocalStorage) Purge(path string) error {
+	if err := os.Remove(filepath.Join(l.basePath, trashDir, path)); err != nil {
+		return fmt.Errorf("purging file: %w", err)
+	}
+	return nil
+}
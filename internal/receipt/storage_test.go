@@ -138,6 +138,67 @@ var _ = Describe("LocalStorage", func() {
 		})
 	})
 
+	Describe("List", func() {
+		var (
+			files []StoredFile
+			err   error
+		)
+
+		BeforeEach(func() {
+			_, saveErr := storage.Save("a.jpg", []byte("a"))
+			Expect(saveErr).NotTo(HaveOccurred())
+			_, saveErr = storage.Save("b.pdf", []byte("b"))
+			Expect(saveErr).NotTo(HaveOccurred())
+		})
+
+		JustBeforeEach(func() {
+			files, err = storage.List()
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return every stored file with its modification time", func() {
+			Expect(files).To(ConsistOf(
+				And(HaveField("Path", "a.jpg"), HaveField("ModTime", Not(BeZero()))),
+				And(HaveField("Path", "b.pdf"), HaveField("ModTime", Not(BeZero()))),
+			))
+		})
+	})
+
+	Describe("Trash", func() {
+		BeforeEach(func() {
+			_, err := storage.Save("test.jpg", []byte("data"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storage.Trash("test.jpg")).To(Succeed())
+		})
+
+		It("should move the file out of storage", func() {
+			_, err := storage.Get("test.jpg")
+			Expect(err).To(HaveOccurred())
+			files, err := storage.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(BeEmpty())
+		})
+
+		When("the file is restored", func() {
+			It("should be readable again", func() {
+				Expect(storage.Restore("test.jpg")).To(Succeed())
+				data, err := storage.Get("test.jpg")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(data).To(Equal([]byte("data")))
+			})
+		})
+
+		When("the file is purged", func() {
+			It("should remove it from the trash", func() {
+				Expect(storage.Purge("test.jpg")).To(Succeed())
+				Expect(filepath.Join(tmpDir, trashDir, "test.jpg")).NotTo(BeAnExistingFile())
+			})
+		})
+	})
+
 	Describe("NewLocalStorage", func() {
 		var (
 			storagePath string
@@ -0,0 +1,99 @@
+package receipt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+// uploadName matches the names the service stores uploads under: "<id>_<name>" for receipts
+// and their attachments, and "eob_<id>_<name>" for EOBs, where ids are generated numbers
+var uploadName = regexp.MustCompile(`^(eob_)?[0-9]+_.`)
+
+// SweepResult counts what a sweep removed
+type SweepResult struct {
+	DraftsDeleted int
+	FilesDeleted  int // Unreferenced files, not counting the files of deleted drafts
+}
+
+// SweepAbandoned deletes drafts that were never reviewed and uploaded files that nothing
+// references, once they're older than maxAge
+// Only files named like the service's own uploads are deleted, so anything else kept in
+// the storage directory, such as backups or the database, is left alone.
+func (s *Service) SweepAbandoned(maxAge time.Duration) (*SweepResult, error) {
+	cutoff := s.timeSource.Now().Add(-maxAge)
+	result := &SweepResult{}
+
+	receipts, err := s.db.ListReceipts()
+	if err != nil {
+		return nil, fmt.Errorf("listing receipts: %w", err)
+	}
+
+	referenced := make(map[string]bool)
+	for _, receipt := range receipts {
+		if receipt.IsDraft() && receipt.CreatedAt.Before(cutoff) {
+			if err := s.DeleteReceipt(receipt.ID); err != nil {
+				return nil, fmt.Errorf("deleting draft %s: %w", receipt.ID, err)
+			}
+			result.DraftsDeleted++
+			continue
+		}
+		for _, filename := range receipt.storedFiles() {
+			referenced[filename] = true
+		}
+	}
+
+	eobs, err := s.db.ListEOBs()
+	if err != nil {
+		return nil, fmt.Errorf("listing eobs: %w", err)
+	}
+	for _, eob := range eobs {
+		referenced[eob.Filename] = true
+	}
+
+	files, err := s.storage.List()
+	if err != nil {
+		return nil, fmt.Errorf("listing stored files: %w", err)
+	}
+	for _, file := range files {
+		// Recent files may belong to a scan that hasn't been saved yet
+		if !uploadName.MatchString(file.Path) || referenced[file.Path] || !file.ModTime.Before(cutoff) {
+			continue
+		}
+		if err := s.storage.Delete(file.Path); err != nil {
+			return nil, fmt.Errorf("deleting file %s: %w", file.Path, err)
+		}
+		result.FilesDeleted++
+	}
+
+	return result, nil
+}
+
+// RunSweeper calls SweepAbandoned and PurgeTrash every interval until ctx is cancelled
+func (s *Service) RunSweeper(ctx context.Context, interval, maxAge, trashRetention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.SweepAbandoned(maxAge)
+			if err != nil {
+				slog.Error("Failed to sweep abandoned uploads", "error", err)
+			} else if result.DraftsDeleted > 0 || result.FilesDeleted > 0 {
+				slog.Info("Swept abandoned uploads", "drafts", result.DraftsDeleted, "files", result.FilesDeleted)
+			}
+
+			purged, err := s.PurgeTrash(trashRetention)
+			if err != nil {
+				slog.Error("Failed to purge trash", "error", err)
+			} else if purged > 0 {
+				slog.Info("Purged trash", "receipts", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package scanning
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DefaultFallbackConfidence is the lowest field confidence a Fallback accepts without trying the next scanner
+const DefaultFallbackConfidence = 0.6
+
+// Fallback implements the Scanner interface by trying scanners in order
+// A receipt is passed to the next scanner when a scanner fails or isn't confident in
+// what it read, so a cheap local model can be tried before a more accurate cloud one.
+type Fallback struct {
+	scanners      []Scanner
+	minConfidence float64
+}
+
+// NewFallback creates a Scanner that tries each scanner in order
+// minConfidence is the lowest confidence accepted for the title, date and amount.
+func NewFallback(minConfidence float64, scanners ...Scanner) (*Fallback, error) {
+	if len(scanners) == 0 {
+		return nil, fmt.Errorf("fallback needs at least one scanner")
+	}
+	return &Fallback{scanners: scanners, minConfidence: minConfidence}, nil
+}
+
+// ScanReceipt returns the first confident result
+// If no scanner is confident, the result of the last scanner that succeeded is returned.
+func (f *Fallback) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	var (
+		best *ReceiptData
+		errs []error
+	)
+	for _, scanner := range f.scanners {
+		data, err := scanner.ScanReceipt(imageData, contentType, docType)
+		if err != nil {
+			slog.Warn("Scanner failed, trying the next one", "scanner", fmt.Sprintf("%T", scanner), "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		if f.confident(data) {
+			return data, nil
+		}
+		slog.Info("Scanner wasn't confident, trying the next one", "scanner", fmt.Sprintf("%T", scanner))
+		best = data
+	}
+	if best != nil {
+		return best, nil
+	}
+	return nil, fmt.Errorf("all scanners failed: %w", errors.Join(errs...))
+}
+
+// confident reports whether the title, date and amount were all read with enough confidence
+// Line items that don't add up to the amount suggest the amount was misread.
+func (f *Fallback) confident(data *ReceiptData) bool {
+	for _, field := range []FieldConfidence{data.Fields.Title, data.Fields.Date, data.Fields.Amount} {
+		if field.Provenance == ProvenanceDefaulted || field.Confidence > 0 && field.Confidence < f.minConfidence {
+			return false
+		}
+	}
+	return !data.LineItemsMismatch
+}
+
+// ScanEOB returns the result of the first scanner that succeeds
+func (f *Fallback) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	var errs []error
+	for _, scanner := range f.scanners {
+		data, err := scanner.ScanEOB(imageData, contentType)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, ErrUnsupported) {
+			slog.Warn("Scanner failed, trying the next one", "scanner", fmt.Sprintf("%T", scanner), "error", err)
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("all scanners failed: %w", errors.Join(errs...))
+}
+
+// Close closes every scanner
+func (f *Fallback) Close() error {
+	var errs []error
+	for _, scanner := range f.scanners {
+		errs = append(errs, scanner.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Consensus implements the Scanner interface by running two scanners on every document
+// The primary scanner's result is returned, with the title, date and amount marked as
+// disputed where the secondary scanner read something different.
+type Consensus struct {
+	primary   Scanner
+	secondary Scanner
+}
+
+// NewConsensus creates a Scanner that checks the primary scanner against the secondary one
+func NewConsensus(primary, secondary Scanner) *Consensus {
+	return &Consensus{primary: primary, secondary: secondary}
+}
+
+// ScanReceipt runs both scanners at once and flags the details they disagree on
+// If one scanner fails, the other's result is returned unchecked.
+func (c *Consensus) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	var (
+		wg                       sync.WaitGroup
+		primary, secondary       *ReceiptData
+		primaryErr, secondaryErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		primary, primaryErr = c.primary.ScanReceipt(imageData, contentType, docType)
+	}()
+	go func() {
+		defer wg.Done()
+		secondary, secondaryErr = c.secondary.ScanReceipt(imageData, contentType, docType)
+	}()
+	wg.Wait()
+
+	switch {
+	case primaryErr != nil && secondaryErr != nil:
+		return nil, fmt.Errorf("both scanners failed: %w", errors.Join(primaryErr, secondaryErr))
+	case primaryErr != nil:
+		slog.Warn("Primary scanner failed, using the secondary scanner unchecked", "error", primaryErr)
+		return secondary, nil
+	case secondaryErr != nil:
+		slog.Warn("Secondary scanner failed, using the primary scanner unchecked", "error", secondaryErr)
+		return primary, nil
+	}
+
+	if !sameMerchant(primary.Title, secondary.Title) {
+		primary.Fields.Title.Disputed = true
+	}
+	if primary.Date != secondary.Date {
+		primary.Fields.Date.Disputed = true
+	}
+	if math.Abs(primary.Amount-secondary.Amount) >= 0.01 {
+		primary.Fields.Amount.Disputed = true
+	}
+	return primary, nil
+}
+
+// sameMerchant reports whether two scanned titles name the same business
+// Scanners describe the purchase differently ("CVS - Amoxicillin" and "CVS Pharmacy - Prescription"),
+// so only the part before the description is compared, ignoring case and punctuation.
+func sameMerchant(a, b string) bool {
+	a, b = merchantKey(a), merchantKey(b)
+	if a == "" || b == "" {
+		return a == b
+	}
+	return strings.Contains(a, b) || strings.Contains(b, a)
+}
+
+// merchantKey reduces a title to the lowercase letters and digits of its merchant name
+func merchantKey(title string) string {
+	merchant, _, _ := strings.Cut(title, " - ")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, merchant)
+}
+
+// ScanEOB uses the primary scanner, falling back to the secondary one if it fails
+func (c *Consensus) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	data, err := c.primary.ScanEOB(imageData, contentType)
+	if err == nil {
+		return data, nil
+	}
+	data, secondaryErr := c.secondary.ScanEOB(imageData, contentType)
+	if secondaryErr != nil {
+		return nil, fmt.Errorf("both scanners failed: %w", errors.Join(err, secondaryErr))
+	}
+	return data, nil
+}
+
+// Close closes both scanners
+func (c *Consensus) Close() error {
+	return errors.Join(c.primary.Close(), c.secondary.Close())
+}
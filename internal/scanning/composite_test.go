@@ -0,0 +1,254 @@
+package scanning
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// stubScanner returns canned results and counts its calls
+type stubScanner struct {
+	receipt  *ReceiptData
+	eob      *EOBData
+	err      error
+	calls    int
+	closeErr error
+	closed   bool
+}
+
+func (s *stubScanner) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	data := *s.receipt
+	return &data, nil
+}
+
+func (s *stubScanner) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.eob, nil
+}
+
+func (s *stubScanner) Close() error {
+	s.closed = true
+	return s.closeErr
+}
+
+// confidentReceipt returns scanned receipt data with every detail read confidently
+func confidentReceipt(title, date string, amount float64) *ReceiptData {
+	extracted := FieldConfidence{Provenance: ProvenanceExtracted, Confidence: 0.9}
+	return &ReceiptData{
+		Title:    title,
+		Date:     date,
+		Amount:   amount,
+		Category: "pharmacy",
+		Fields:   Fields{Title: extracted, Date: extracted, Amount: extracted, Category: extracted},
+	}
+}
+
+var _ = Describe("Fallback", func() {
+	var (
+		local, cloud *stubScanner
+		fallback     *Fallback
+		receipt      *ReceiptData
+		err          error
+	)
+
+	BeforeEach(func() {
+		local = &stubScanner{receipt: confidentReceipt("CVS - Amoxicillin", "2024-02-01", 12.34)}
+		cloud = &stubScanner{receipt: confidentReceipt("CVS Pharmacy - Amoxicillin", "2024-02-01", 12.34)}
+		fallback, err = NewFallback(DefaultFallbackConfidence, local, cloud)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		receipt, err = fallback.ScanReceipt([]byte("image"), "image/png", DocumentReceipt)
+	})
+
+	When("the first scanner is confident", func() {
+		It("should not try the next scanner", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("CVS - Amoxicillin"))
+			Expect(cloud.calls).To(Equal(0))
+		})
+	})
+
+	When("the first scanner fails", func() {
+		BeforeEach(func() {
+			local.err = errors.New("connection refused")
+		})
+
+		It("should use the next scanner", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("CVS Pharmacy - Amoxicillin"))
+		})
+	})
+
+	When("the first scanner isn't confident about the amount", func() {
+		BeforeEach(func() {
+			local.receipt.Fields.Amount.Confidence = 0.3
+		})
+
+		It("should use the next scanner", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("CVS Pharmacy - Amoxicillin"))
+		})
+	})
+
+	When("the first scanner defaulted the date", func() {
+		BeforeEach(func() {
+			local.receipt.Fields.Date = FieldConfidence{Provenance: ProvenanceDefaulted}
+		})
+
+		It("should use the next scanner", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cloud.calls).To(Equal(1))
+		})
+	})
+
+	When("the first scanner's line items don't add up", func() {
+		BeforeEach(func() {
+			local.receipt.LineItemsMismatch = true
+		})
+
+		It("should use the next scanner", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cloud.calls).To(Equal(1))
+		})
+	})
+
+	When("no scanner is confident", func() {
+		BeforeEach(func() {
+			local.receipt.Fields.Amount.Confidence = 0.3
+			cloud.receipt.Fields.Title.Confidence = 0.4
+		})
+
+		It("should use the last result", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("CVS Pharmacy - Amoxicillin"))
+		})
+	})
+
+	When("the last scanner fails after an unconfident result", func() {
+		BeforeEach(func() {
+			local.receipt.Fields.Amount.Confidence = 0.3
+			cloud.err = errors.New("quota exceeded")
+		})
+
+		It("should use the unconfident result", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("CVS - Amoxicillin"))
+		})
+	})
+
+	When("every scanner fails", func() {
+		BeforeEach(func() {
+			local.err = errors.New("connection refused")
+			cloud.err = errors.New("quota exceeded")
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("all scanners failed")))
+			Expect(err).To(MatchError(ContainSubstring("quota exceeded")))
+		})
+	})
+
+	Describe("ScanEOB", func() {
+		When("the first scanner doesn't support EOBs", func() {
+			BeforeEach(func() {
+				local.err = ErrUnsupported
+				cloud.eob = &EOBData{Provider: "Dr. Smith"}
+			})
+
+			It("should use the next scanner", func() {
+				eob, err := fallback.ScanEOB([]byte("image"), "image/png")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(eob.Provider).To(Equal("Dr. Smith"))
+			})
+		})
+	})
+
+	Describe("Close", func() {
+		It("should close every scanner", func() {
+			local.closeErr = errors.New("close failed")
+			Expect(fallback.Close()).To(MatchError("close failed"))
+			Expect(local.closed).To(BeTrue())
+			Expect(cloud.closed).To(BeTrue())
+		})
+	})
+
+	When("no scanners are given", func() {
+		It("returns an error", func() {
+			_, err := NewFallback(DefaultFallbackConfidence)
+			Expect(err).To(MatchError("fallback needs at least one scanner"))
+		})
+	})
+})
+
+var _ = Describe("Consensus", func() {
+	var (
+		primary, secondary *stubScanner
+		receipt            *ReceiptData
+		err                error
+	)
+
+	BeforeEach(func() {
+		primary = &stubScanner{receipt: confidentReceipt("CVS - Amoxicillin", "2024-02-01", 12.34)}
+		secondary = &stubScanner{receipt: confidentReceipt("CVS Pharmacy - Prescription", "2024-02-01", 12.34)}
+	})
+
+	JustBeforeEach(func() {
+		receipt, err = NewConsensus(primary, secondary).ScanReceipt([]byte("image"), "image/png", DocumentReceipt)
+	})
+
+	When("the scanners agree", func() {
+		It("should return the primary result without disputes", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("CVS - Amoxicillin"))
+			Expect(receipt.Fields.Title.Disputed).To(BeFalse())
+			Expect(receipt.Fields.Date.Disputed).To(BeFalse())
+			Expect(receipt.Fields.Amount.Disputed).To(BeFalse())
+		})
+	})
+
+	When("the scanners disagree", func() {
+		BeforeEach(func() {
+			secondary.receipt = confidentReceipt("Walgreens - Amoxicillin", "2024-02-07", 12.84)
+		})
+
+		It("should flag the title, date and amount as disputed", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Fields.Title.Disputed).To(BeTrue())
+			Expect(receipt.Fields.Date.Disputed).To(BeTrue())
+			Expect(receipt.Fields.Amount.Disputed).To(BeTrue())
+			Expect(receipt.Fields.Category.Disputed).To(BeFalse())
+		})
+	})
+
+	When("one scanner fails", func() {
+		BeforeEach(func() {
+			primary.err = errors.New("connection refused")
+		})
+
+		It("should use the other scanner's result", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("CVS Pharmacy - Prescription"))
+		})
+	})
+
+	When("both scanners fail", func() {
+		BeforeEach(func() {
+			primary.err = errors.New("connection refused")
+			secondary.err = errors.New("quota exceeded")
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("both scanners failed")))
+		})
+	})
+})
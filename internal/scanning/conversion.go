@@ -13,52 +13,69 @@ import (
 	"github.com/gen2brain/heic"
 )
 
-// receiptScanPrompt is the shared prompt used by all LLM providers for scanning receipts
-const receiptScanPrompt = `You are analyzing a receipt or invoice document. Carefully read all text in the image and extract the following information:
+// pdfToImages renders pages of a PDF to PNG images, in page order
+// It also returns the number of pages in the document, which may be more than were rendered.
+func pdfToImages(pdfData []byte, opts pdfOptions) ([][]byte, int, error) {
+	doc, err := fitz.NewFromMemory(pdfData)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening PDF: %w", err)
+	}
+	defer doc.Close()
 
-1. **Store/Business Name**: Look for the merchant name, store name, or business name at the top of the receipt. This is usually the largest text or in a header. Examples: "Walmart", "CVS Pharmacy", "Walgreens", "Target".
+	total := doc.NumPage()
+	if total == 0 {
+		return nil, 0, fmt.Errorf("PDF has no pages")
+	}
 
-2. **Date**: Find the transaction date, purchase date, or invoice date on the receipt. Convert it to ISO 8601 format (YYYY-MM-DD). Look for dates near the top or bottom of the receipt. Common formats: MM/DD/YYYY, DD/MM/YYYY, or written dates.
+	var pages [][]byte
+	for _, page := range pdfPageIndexes(total, opts.maxPages) {
+		img, err := doc.ImageDPI(page, opts.dpi)
+		if err != nil {
+			return nil, 0, fmt.Errorf("rendering PDF page %d: %w", page+1, err)
+		}
 
-3. **Total Amount**: Find the final total, grand total, or amount due. This is usually at the bottom of the receipt, often labeled as "TOTAL", "Amount Due", "Grand Total", or similar. Extract only the numeric value (e.g., 42.75 for $42.75).
+		// Encode as PNG
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, 0, fmt.Errorf("encoding PNG: %w", err)
+		}
+		pages = append(pages, buf.Bytes())
+	}
 
-Return ONLY valid JSON in this exact format:
-{
-  "title": "Store Name - Brief Description",
-  "date": "YYYY-MM-DD",
-  "amount": 0.00
+	return pages, total, nil
 }
 
-Important:
-- The title should start with the actual store/business name from the receipt
-- The date must be in YYYY-MM-DD format
-- The amount must be a number (not a string), representing dollars and cents
-- If you cannot find a field, use null for that field
-- Do not include any text before or after the JSON
-- Do not use markdown code blocks`
-
-// pdfToImage converts a PDF to a PNG image
-func pdfToImage(pdfData []byte) ([]byte, error) {
-	doc, err := fitz.NewFromMemory(pdfData)
-	if err != nil {
-		return nil, fmt.Errorf("opening PDF: %w", err)
+// pdfPageIndexes picks which pages of a document to render, zero-based
+// When there are more pages than maxPages, the last page is kept in place of one of the
+// earlier ones because that's where bills and statements usually put the total.
+// A single page is always the first, so a document is identified by its first page.
+func pdfPageIndexes(total, maxPages int) []int {
+	count := min(total, maxPages)
+	indexes := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		indexes = append(indexes, i)
 	}
-	defer doc.Close()
+	if total > count && count > 1 {
+		indexes[count-1] = total - 1
+	}
+	return indexes
+}
 
-	// Render the first page (most receipts are single page)
-	// Use a high DPI for better quality (300 DPI)
-	img, err := doc.Image(0)
-	if err != nil {
-		return nil, fmt.Errorf("rendering PDF page: %w", err)
+// pagesPrompt tells the model how the document's pages are laid out before the scan prompt
+// Single images are sent with the scan prompt unchanged.
+func pagesPrompt(prompt string, pages []int, total int) string {
+	if len(pages) <= 1 {
+		return prompt
 	}
 
-	// Encode as PNG
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, img); err != nil {
-		return nil, fmt.Errorf("encoding PNG: %w", err)
+	note := fmt.Sprintf("This document has %d pages, provided as %d images in page order.", total, len(pages))
+	if len(pages) < total {
+		note = fmt.Sprintf("This document has %d pages. Pages 1-%d and the last page (%d) are provided as %d images in page order; the pages in between are omitted.",
+			total, len(pages)-1, total, len(pages))
 	}
+	note += " Read every page before answering: treat them as one document, combine line items that continue across pages, and take the total from the page that states the final amount (usually the last page) rather than a page subtotal."
 
-	return buf.Bytes(), nil
+	return note + "\n\n" + prompt
 }
 
 // imageToPNG converts any image format to PNG
@@ -121,14 +138,15 @@ func isHEICMimeType(mimeType string) bool {
 }
 
 // convertToPNG converts PDFs and non-PNG images to PNG format
+// Only the first page of a PDF is converted; use prepareImages for every page.
 // Returns the PNG data and a boolean indicating if conversion occurred
 func convertToPNG(imageData []byte, mimeType string) ([]byte, bool, error) {
 	if mimeType == "application/pdf" {
-		pngData, err := pdfToImage(imageData)
+		pages, _, err := pdfToImages(imageData, pdfOptions{maxPages: 1, dpi: DefaultPDFDPI})
 		if err != nil {
 			return nil, false, fmt.Errorf("converting PDF to image: %w", err)
 		}
-		return pngData, true, nil
+		return pages[0], true, nil
 	} else if mimeType != "image/png" || isHEICFormat(imageData) || isHEICMimeType(mimeType) {
 		// Convert all non-PNG images (including HEIC) to PNG
 		pngData, err := imageToPNG(imageData, mimeType)
@@ -141,14 +159,19 @@ func convertToPNG(imageData []byte, mimeType string) ([]byte, bool, error) {
 	return imageData, false, nil
 }
 
-// prepareImageData normalizes the MIME type and converts the image to PNG if needed
-// Returns the final image data, the MIME type to use, and whether conversion occurred
-func prepareImageData(imageData []byte, contentType string) ([]byte, string, bool, error) {
-	// Normalize MIME type (lowercase, trim whitespace)
+// normalizeMimeType lowercases and trims a content type, defaulting to JPEG
+func normalizeMimeType(contentType string) string {
 	mimeType := strings.ToLower(strings.TrimSpace(contentType))
 	if mimeType == "" {
 		mimeType = "image/jpeg" // default
 	}
+	return mimeType
+}
+
+// prepareImageData normalizes the MIME type and converts the image to PNG if needed
+// Returns the final image data, the MIME type to use, and whether conversion occurred
+func prepareImageData(imageData []byte, contentType string) ([]byte, string, bool, error) {
+	mimeType := normalizeMimeType(contentType)
 
 	// Convert to PNG if needed
 	finalImageData, converted, err := convertToPNG(imageData, mimeType)
@@ -162,3 +185,21 @@ func prepareImageData(imageData []byte, contentType string) ([]byte, string, boo
 
 	return finalImageData, finalMimeType, converted, nil
 }
+
+// prepareImages converts a document to PNG images, one per page, and adapts the prompt to them
+// PDFs are rendered page by page within the page cap; everything else is a single image.
+func prepareImages(imageData []byte, contentType string, prompt string, opts pdfOptions) ([][]byte, string, error) {
+	if normalizeMimeType(contentType) != "application/pdf" {
+		pngData, _, _, err := prepareImageData(imageData, contentType)
+		if err != nil {
+			return nil, "", err
+		}
+		return [][]byte{pngData}, prompt, nil
+	}
+
+	pages, total, err := pdfToImages(imageData, opts)
+	if err != nil {
+		return nil, "", fmt.Errorf("converting PDF to images: %w", err)
+	}
+	return pages, pagesPrompt(prompt, pdfPageIndexes(total, opts.maxPages), total), nil
+}
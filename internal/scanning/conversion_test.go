@@ -0,0 +1,164 @@
+package scanning
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// testPDF builds a PDF with the given number of blank pages
+// The first page is letter size, and each page after it is an inch wider than the one before.
+func testPDF(pages int) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>"}
+	kids := ""
+	for i := 0; i < pages; i++ {
+		kids += fmt.Sprintf("%d 0 R ", i+3)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages))
+	for i := 0; i < pages; i++ {
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d 792] >>", 612+72*i))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+var _ = Describe("pdfPageIndexes", func() {
+	When("the document fits within the page cap", func() {
+		It("returns every page", func() {
+			Expect(pdfPageIndexes(3, 10)).To(Equal([]int{0, 1, 2}))
+		})
+	})
+
+	When("the document is longer than the page cap", func() {
+		It("keeps the last page in place of the last page that fits", func() {
+			Expect(pdfPageIndexes(12, 4)).To(Equal([]int{0, 1, 2, 11}))
+		})
+	})
+
+	When("only one page is allowed", func() {
+		It("returns the first page", func() {
+			Expect(pdfPageIndexes(5, 1)).To(Equal([]int{0}))
+		})
+	})
+})
+
+var _ = Describe("convertToPNG", func() {
+	When("the document is a multi-page PDF", func() {
+		It("converts the first page", func() {
+			converted, ok, err := convertToPNG(testPDF(3), "application/pdf")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			img, err := png.Decode(bytes.NewReader(converted))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(img.Bounds().Dx()).To(Equal(1700)) // The first page's 8.5in at 200 DPI
+		})
+	})
+})
+
+var _ = Describe("pagesPrompt", func() {
+	When("there is a single page", func() {
+		It("returns the prompt unchanged", func() {
+			Expect(pagesPrompt("scan this", []int{0}, 1)).To(Equal("scan this"))
+		})
+	})
+
+	When("every page is included", func() {
+		It("tells the model to read across the pages", func() {
+			prompt := pagesPrompt("scan this", []int{0, 1, 2}, 3)
+			Expect(prompt).To(HavePrefix("This document has 3 pages, provided as 3 images in page order."))
+			Expect(prompt).To(ContainSubstring("usually the last page"))
+			Expect(prompt).To(HaveSuffix("\n\nscan this"))
+		})
+	})
+
+	When("pages were left out", func() {
+		It("says which pages are included", func() {
+			prompt := pagesPrompt("scan this", []int{0, 1, 11}, 12)
+			Expect(prompt).To(ContainSubstring("Pages 1-2 and the last page (12) are provided as 3 images"))
+		})
+	})
+})
+
+var _ = Describe("prepareImages", func() {
+	var (
+		data   []byte
+		opts   pdfOptions
+		pages  [][]byte
+		prompt string
+		err    error
+	)
+
+	BeforeEach(func() {
+		data = testPDF(3)
+		opts = pdfOptions{maxPages: 10, dpi: 36}
+	})
+
+	JustBeforeEach(func() {
+		pages, prompt, err = prepareImages(data, "application/pdf", "scan this", opts)
+	})
+
+	It("renders every page of a PDF at the configured DPI", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pages).To(HaveLen(3))
+		img, err := png.Decode(bytes.NewReader(pages[0]))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(img.Bounds().Dx()).To(Equal(306)) // 8.5in at 36 DPI
+		Expect(prompt).To(HavePrefix("This document has 3 pages"))
+	})
+
+	When("the PDF has more pages than the cap", func() {
+		BeforeEach(func() {
+			opts.maxPages = 2
+		})
+
+		It("renders only the capped pages", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pages).To(HaveLen(2))
+			Expect(prompt).To(ContainSubstring("the last page (3)"))
+		})
+	})
+
+	When("the document is an image", func() {
+		BeforeEach(func() {
+			var buf bytes.Buffer
+			Expect(png.Encode(&buf, testDocument(60, 80, 0))).To(Succeed())
+			data = buf.Bytes()
+		})
+
+		JustBeforeEach(func() {
+			pages, prompt, err = prepareImages(data, "image/png", "scan this", opts)
+		})
+
+		It("returns a single image and the prompt unchanged", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pages).To(HaveLen(1))
+			Expect(prompt).To(Equal("scan this"))
+		})
+	})
+
+	When("the PDF is invalid", func() {
+		BeforeEach(func() {
+			data = []byte("not a pdf")
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ContainSubstring("converting PDF to images")))
+		})
+	})
+})
@@ -0,0 +1,70 @@
+package scanning
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is returned when a scanner's API responds with an error status
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // How long the API asked callers to wait; zero when it didn't say
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Is makes rate limit and quota responses match ErrQuotaExhausted
+func (e *APIError) Is(target error) bool {
+	return target == ErrQuotaExhausted && e.StatusCode == http.StatusTooManyRequests
+}
+
+// retryable reports whether the same request may succeed if it's sent again later
+func (e *APIError) retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests:
+		return !e.quotaUsedUp()
+	case http.StatusRequestTimeout, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// quotaUsedUp reports whether a rate limit response says the account has run out of quota
+// or credit, which waiting won't fix, rather than that requests are coming in too fast
+func (e *APIError) quotaUsedUp() bool {
+	return e.StatusCode == http.StatusTooManyRequests && strings.Contains(e.Message, "insufficient_quota")
+}
+
+// newAPIError reads an error response from a scanner's HTTP API
+func newAPIError(provider string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as an HTTP date
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
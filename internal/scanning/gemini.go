@@ -2,11 +2,13 @@ package scanning
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -14,10 +16,11 @@ import (
 type Gemini struct {
 	client *genai.Client
 	model  *genai.GenerativeModel
+	opts   options
 }
 
 // NewGemini creates a new Gemini Scanner instance
-func NewGemini(apiKey string, modelName string) (*Gemini, error) {
+func NewGemini(apiKey string, modelName string, opts ...Option) (*Gemini, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("gemini api key is required")
 	}
@@ -36,35 +39,95 @@ func NewGemini(apiKey string, modelName string) (*Gemini, error) {
 	return &Gemini{
 		client: client,
 		model:  model,
+		opts:   newOptions(opts),
 	}, nil
 }
 
 // ScanReceipt analyzes a receipt and extracts metadata
-func (g *Gemini) ScanReceipt(imageData []byte, contentType string) (*ReceiptData, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func (g *Gemini) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	prompt, err := g.opts.prompts.forReceipt(docType)
+	if err != nil {
+		return nil, err
+	}
 
-	// Prepare image data (convert to PNG if needed)
-	finalImageData, _, _, err := prepareImageData(imageData, contentType)
+	text, err := g.generate(imageData, contentType, prompt, receiptSchema)
 	if err != nil {
 		return nil, err
 	}
 
-	// genai.ImageData expects just the format suffix (e.g., "png"), not the full MIME type (e.g., "image/png")
-	// After prepareImageData, everything is PNG, so we always use "png"
-	parts := []genai.Part{
-		genai.ImageData("png", finalImageData),
-		genai.Text(receiptScanPrompt),
+	data, err := parseReceiptJSON(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing receipt data: %w", err)
 	}
+	data.DocumentType = docType
+	data.PromptVersion = prompt.Version
+
+	return data, nil
+}
 
-	// Generate response
-	resp, err := g.model.GenerateContent(ctx, parts...)
+// ScanEOB analyzes an insurance explanation of benefits and extracts the claim amounts
+func (g *Gemini) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	prompt, err := g.opts.prompts.For(DocumentEOB)
 	if err != nil {
-		return nil, fmt.Errorf("generating content: %w", err)
+		return nil, err
+	}
+
+	text, err := g.generate(imageData, contentType, prompt, eobSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := parseEOBJSON(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing eob data: %w", err)
+	}
+
+	return data, nil
+}
+
+// generate sends the document and prompt to Gemini and returns the JSON response
+// The response is constrained to the given schema.
+func (g *Gemini) generate(imageData []byte, contentType string, prompt Prompt, responseSchema *schema) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.opts.timeoutOr(30*time.Second))
+	defer cancel()
+
+	// Prepare image data (convert to PNG if needed, one image per PDF page)
+	pages, text, err := prepareImages(imageData, contentType, prompt.Text, g.opts.pdf)
+	if err != nil {
+		return "", err
+	}
+
+	// genai.ImageData expects just the format suffix (e.g., "png"), not the full MIME type (e.g., "image/png")
+	// After prepareImages, everything is PNG, so we always use "png"
+	parts := make([]genai.Part, 0, len(pages)+1)
+	for _, page := range pages {
+		parts = append(parts, genai.ImageData("png", page))
+	}
+	parts = append(parts, genai.Text(text))
+
+	// Generate response; the model is copied so each document type gets its own schema and system prompt
+	model := *g.model
+	model.ResponseMIMEType = "application/json"
+	model.ResponseSchema = responseSchema.gemini()
+	if prompt.System != "" {
+		model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(prompt.System)}}
+	}
+	resp, err := model.GenerateContent(ctx, parts...)
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) {
+			err = &APIError{
+				Provider:   "gemini",
+				StatusCode: apiErr.Code,
+				Message:    apiErr.Message,
+				RetryAfter: parseRetryAfter(apiErr.Header.Get("Retry-After")),
+			}
+		}
+		return "", fmt.Errorf("generating content: %w", err)
 	}
 
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no response from gemini")
+		return "", fmt.Errorf("no response from gemini")
 	}
 
 	// Extract text response
@@ -76,19 +139,7 @@ func (g *Gemini) ScanReceipt(imageData []byte, contentType string) (*ReceiptData
 		}
 	}
 
-	// Parse JSON response
-	text := strings.TrimSpace(responseText.String())
-	// Remove markdown code blocks if present
-	text = strings.TrimPrefix(text, "```json")
-	text = strings.TrimPrefix(text, "```")
-	text = strings.TrimSpace(text)
-
-	data, err := parseReceiptJSON(text)
-	if err != nil {
-		return nil, fmt.Errorf("parsing receipt data: %w", err)
-	}
-
-	return data, nil
+	return responseText.String(), nil
 }
 
 // Close closes the Gemini client
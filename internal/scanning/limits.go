@@ -0,0 +1,164 @@
+package scanning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Defaults for retrying scanner calls
+const (
+	DefaultMaxAttempts = 4                // Tries per document, including the first
+	DefaultBaseDelay   = time.Second      // Wait before the first retry
+	DefaultMaxDelay    = 30 * time.Second // Longest wait between retries
+)
+
+// Limits controls how calls to a scanner are retried and throttled
+// Zero values use the defaults, or turn off the rate limit and concurrency cap.
+type Limits struct {
+	MaxAttempts       int           // Tries per document, including the first
+	BaseDelay         time.Duration // Wait before the first retry; doubled for each retry after it
+	MaxDelay          time.Duration // Longest wait between retries; longer Retry-After requests aren't waited for
+	RequestsPerMinute float64       // Sustained rate calls are sent at; zero for no limit
+	Burst             int           // Calls that may be sent at once before the rate limit applies
+	MaxConcurrent     int           // Calls that may be in flight at once; zero for no cap
+}
+
+// Limited implements the Scanner interface by retrying and throttling another scanner
+// Failures that may pass, such as network errors, rate limits and server errors, are
+// retried with jittered exponential backoff. Other errors are returned as is, including
+// calls that timed out: a call that used its whole timeout is likely to again, and retrying
+// it would hold the upload, or the next scanner in a Fallback, for several times as long.
+type Limited struct {
+	scanner Scanner
+	limits  Limits
+	limiter *rate.Limiter // Nil when calls aren't rate limited
+	slots   chan struct{} // Nil when concurrency isn't capped
+	sleep   func(time.Duration)
+}
+
+// NewLimited wraps a scanner so its calls are retried and throttled
+func NewLimited(scanner Scanner, limits Limits) *Limited {
+	if limits.MaxAttempts <= 0 {
+		limits.MaxAttempts = DefaultMaxAttempts
+	}
+	if limits.BaseDelay <= 0 {
+		limits.BaseDelay = DefaultBaseDelay
+	}
+	if limits.MaxDelay <= 0 {
+		limits.MaxDelay = DefaultMaxDelay
+	}
+
+	l := &Limited{scanner: scanner, limits: limits, sleep: time.Sleep}
+	if limits.RequestsPerMinute > 0 {
+		l.limiter = rate.NewLimiter(rate.Limit(limits.RequestsPerMinute/60), max(limits.Burst, 1))
+	}
+	if limits.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, limits.MaxConcurrent)
+	}
+	return l
+}
+
+// ScanReceipt scans a receipt with the wrapped scanner
+func (l *Limited) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	var data *ReceiptData
+	err := l.call(func() (err error) {
+		data, err = l.scanner.ScanReceipt(imageData, contentType, docType)
+		return err
+	})
+	return data, err
+}
+
+// ScanEOB scans an explanation of benefits with the wrapped scanner
+func (l *Limited) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	var data *EOBData
+	err := l.call(func() (err error) {
+		data, err = l.scanner.ScanEOB(imageData, contentType)
+		return err
+	})
+	return data, err
+}
+
+// Close closes the wrapped scanner
+func (l *Limited) Close() error {
+	return l.scanner.Close()
+}
+
+// call runs scan until it succeeds, fails in a way retrying won't fix, or runs out of attempts
+func (l *Limited) call(scan func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := l.throttled(scan)
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if attempt >= l.limits.MaxAttempts {
+			if attempt > 1 {
+				err = fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		delay := l.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+			// A long wait usually means a daily quota is used up, so there's no point holding the upload
+			if apiErr.RetryAfter > l.limits.MaxDelay {
+				return err
+			}
+			delay = apiErr.RetryAfter
+		}
+		slog.Warn("Scanner call failed, retrying", "scanner", fmt.Sprintf("%T", l.scanner), "attempt", attempt, "delay", delay, "error", err)
+		l.sleep(delay)
+	}
+}
+
+// throttled runs scan once a concurrency slot and a rate limit token are free
+func (l *Limited) throttled(scan func() error) error {
+	if l.slots != nil {
+		l.slots <- struct{}{}
+		defer func() { <-l.slots }()
+	}
+	if l.limiter != nil {
+		if err := l.limiter.Wait(context.Background()); err != nil {
+			return fmt.Errorf("waiting for rate limit: %w", err)
+		}
+	}
+	return scan()
+}
+
+// backoff returns how long to wait before retrying after the given attempt
+// The wait doubles with each attempt, and is jittered between half and all of it
+// so a batch of uploads that failed together don't all retry at the same moment.
+func (l *Limited) backoff(attempt int) time.Duration {
+	delay := l.limits.MaxDelay
+	if shift := attempt - 1; shift < 32 && l.limits.BaseDelay<<shift < delay {
+		delay = l.limits.BaseDelay << shift
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retryable reports whether a failed scan may succeed if it's tried again
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.retryable()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
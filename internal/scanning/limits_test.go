@@ -0,0 +1,205 @@
+package scanning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// flakyScanner fails with the queued errors before succeeding
+type flakyScanner struct {
+	stubScanner
+	mu       sync.Mutex
+	failures []error
+}
+
+func (s *flakyScanner) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.failures) > 0 {
+		err := s.failures[0]
+		s.failures = s.failures[1:]
+		return nil, err
+	}
+	return s.receipt, nil
+}
+
+var _ = Describe("Limited", func() {
+	var (
+		scanner *flakyScanner
+		limits  Limits
+		limited *Limited
+		delays  []time.Duration
+		receipt *ReceiptData
+		err     error
+	)
+
+	BeforeEach(func() {
+		scanner = &flakyScanner{stubScanner: stubScanner{receipt: confidentReceipt("CVS", "2024-02-01", 12.34)}}
+		limits = Limits{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+		delays = nil
+	})
+
+	JustBeforeEach(func() {
+		limited = NewLimited(scanner, limits)
+		limited.sleep = func(d time.Duration) { delays = append(delays, d) }
+		receipt, err = limited.ScanReceipt([]byte("image"), "image/png", DocumentReceipt)
+	})
+
+	When("the API has a temporary outage", func() {
+		BeforeEach(func() {
+			scanner.failures = []error{
+				&APIError{Provider: "ollama", StatusCode: http.StatusServiceUnavailable},
+				fmt.Errorf("calling ollama API: %w", &APIError{Provider: "ollama", StatusCode: http.StatusBadGateway}),
+			}
+		})
+
+		It("should retry with growing, jittered delays", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("CVS"))
+			Expect(scanner.calls).To(Equal(3))
+			Expect(delays).To(HaveLen(2))
+			Expect(delays[0]).To(BeNumerically("~", 750*time.Millisecond, 250*time.Millisecond))
+			Expect(delays[1]).To(BeNumerically("~", 1500*time.Millisecond, 500*time.Millisecond))
+		})
+	})
+
+	When("the API keeps failing", func() {
+		BeforeEach(func() {
+			for range 3 {
+				scanner.failures = append(scanner.failures, &APIError{Provider: "ollama", StatusCode: http.StatusInternalServerError})
+			}
+		})
+
+		It("should give up after the last attempt", func() {
+			Expect(err).To(MatchError(ContainSubstring("giving up after 3 attempts")))
+			Expect(scanner.calls).To(Equal(3))
+		})
+	})
+
+	When("the API asks to wait", func() {
+		BeforeEach(func() {
+			scanner.failures = []error{&APIError{Provider: "gemini", StatusCode: http.StatusTooManyRequests, RetryAfter: 8 * time.Second}}
+		})
+
+		It("should wait as long as asked", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(delays).To(Equal([]time.Duration{8 * time.Second}))
+		})
+	})
+
+	When("the API asks to wait longer than the longest delay", func() {
+		BeforeEach(func() {
+			scanner.failures = []error{&APIError{Provider: "gemini", StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}}
+		})
+
+		It("returns a quota error without retrying", func() {
+			Expect(err).To(MatchError(ErrQuotaExhausted))
+			Expect(scanner.calls).To(Equal(1))
+		})
+	})
+
+	When("the account's quota is used up", func() {
+		BeforeEach(func() {
+			scanner.failures = []error{&APIError{Provider: "openai", StatusCode: http.StatusTooManyRequests,
+				Message: `{"error": {"message": "You exceeded your current quota", "type": "insufficient_quota", "code": "insufficient_quota"}}`}}
+		})
+
+		It("returns a quota error without retrying", func() {
+			Expect(err).To(MatchError(ErrQuotaExhausted))
+			Expect(scanner.calls).To(Equal(1))
+		})
+	})
+
+	When("the call times out", func() {
+		BeforeEach(func() {
+			scanner.failures = []error{fmt.Errorf("calling ollama API: %w", context.DeadlineExceeded)}
+		})
+
+		It("returns the error without retrying", func() {
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(scanner.calls).To(Equal(1))
+		})
+	})
+
+	When("the connection is refused", func() {
+		BeforeEach(func() {
+			scanner.failures = []error{fmt.Errorf("calling ollama API: %w", syscall.ECONNREFUSED)}
+		})
+
+		It("should retry", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scanner.calls).To(Equal(2))
+		})
+	})
+
+	When("the request is rejected", func() {
+		BeforeEach(func() {
+			scanner.failures = []error{&APIError{Provider: "openai", StatusCode: http.StatusBadRequest}}
+		})
+
+		It("returns the error without retrying", func() {
+			Expect(err).To(MatchError(ContainSubstring("status 400")))
+			Expect(scanner.calls).To(Equal(1))
+		})
+	})
+
+	When("the response can't be parsed", func() {
+		BeforeEach(func() {
+			scanner.failures = []error{errors.New("parsing receipt data: no JSON object found")}
+		})
+
+		It("returns the error without retrying", func() {
+			Expect(err).To(MatchError("parsing receipt data: no JSON object found"))
+			Expect(scanner.calls).To(Equal(1))
+		})
+	})
+
+	When("concurrency is capped", func() {
+		It("should never run more calls at once than allowed", func() {
+			var inFlight, most atomic.Int32
+			blocking := &blockingScanner{started: func() {
+				n := inFlight.Add(1)
+				for {
+					if m := most.Load(); n <= m || most.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				inFlight.Add(-1)
+			}}
+			limited := NewLimited(blocking, Limits{MaxConcurrent: 2})
+
+			var wg sync.WaitGroup
+			for range 6 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					limited.ScanEOB([]byte("image"), "image/png")
+				}()
+			}
+			wg.Wait()
+			Expect(most.Load()).To(BeNumerically("<=", 2))
+		})
+	})
+})
+
+// blockingScanner calls started for every EOB it scans
+type blockingScanner struct {
+	stubScanner
+	started func()
+}
+
+func (s *blockingScanner) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	s.started()
+	return &EOBData{}, nil
+}
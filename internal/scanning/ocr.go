@@ -0,0 +1,200 @@
+package scanning
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ocrRulesVersion identifies the extraction rules below; it's recorded in place of a prompt version
+// Bump it when the rules change so receipts can be traced back to the rules that read them.
+const ocrRulesVersion = "ocr-rules-2"
+
+// Confidence given to details found by the extraction rules
+const (
+	ocrKeywordConfidence  = 0.7 // Amount on a total line, or a date matching a known format
+	ocrMerchantConfidence = 0.5 // First line that looks like a name; often a logo or address instead
+	ocrFallbackConfidence = 0.3 // Largest amount on a receipt without a total line
+)
+
+// totalKeywords are labels of the amount a receipt says was paid or is owed, most specific first
+var totalKeywords = []string{
+	"amount due",
+	"balance due",
+	"patient balance",
+	"please pay",
+	"grand total",
+	"total due",
+	"total",
+}
+
+// notTotalKeywords mark lines that mention a total but aren't the amount paid
+var notTotalKeywords = []string{"subtotal", "sub total", "sub-total", "total savings", "total tax", "total discount"}
+
+var (
+	amountPattern    = regexp.MustCompile(`-?\$?\s?(\d{1,3}(?:,\d{3})+|\d+)\.(\d{2})\b`)
+	numericDate      = regexp.MustCompile(`\b(\d{1,2})[/-](\d{1,2})[/-](\d{4}|\d{2})\b`)
+	isoDate          = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	writtenDate      = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2}),?\s+(\d{4})\b`)
+	nonMerchantStart = regexp.MustCompile(`^[\d#*(+]`)
+)
+
+// extractReceiptText finds the merchant, date and total in text read from a receipt
+// Details that aren't found are left empty for normalizeReceipt to default.
+func extractReceiptText(text string) *ReceiptData {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	data := &ReceiptData{RawText: text, PromptVersion: ocrRulesVersion}
+	if merchant, ok := findMerchant(lines); ok {
+		data.Title = merchant
+		data.Fields.Title = FieldConfidence{Provenance: ProvenanceExtracted, Confidence: ocrMerchantConfidence}
+	}
+	if date, ok := findDate(lines); ok {
+		data.Date = date
+		data.Fields.Date = FieldConfidence{Provenance: ProvenanceExtracted, Confidence: ocrKeywordConfidence}
+	}
+	if amount, ok := findTotal(lines); ok {
+		data.Amount = amount
+		data.Fields.Amount = FieldConfidence{Provenance: ProvenanceExtracted, Confidence: ocrKeywordConfidence}
+	} else if amount, ok := largestAmount(lines); ok {
+		data.Amount = amount
+		data.Fields.Amount = FieldConfidence{Provenance: ProvenanceInferred, Confidence: ocrFallbackConfidence}
+	}
+	return data
+}
+
+// findMerchant returns the first line that looks like a business name
+// Receipts put the merchant at the top, but OCR often reads a store number or phone line first.
+func findMerchant(lines []string) (string, bool) {
+	for _, line := range lines {
+		if nonMerchantStart.MatchString(line) {
+			continue
+		}
+		var letters int
+		for _, r := range line {
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+		if letters >= 3 && letters*2 >= len([]rune(line)) {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// findDate returns the first date on the receipt in YYYY-MM-DD format
+func findDate(lines []string) (string, bool) {
+	for _, line := range lines {
+		if m := isoDate.FindStringSubmatch(line); m != nil {
+			if date, ok := makeDate(m[1], m[2], m[3]); ok {
+				return date, true
+			}
+		}
+		if m := numericDate.FindStringSubmatch(line); m != nil {
+			year := m[3]
+			if len(year) == 2 {
+				year = "20" + year
+			}
+			// US receipts put the month first
+			if date, ok := makeDate(year, m[1], m[2]); ok {
+				return date, true
+			}
+		}
+		if m := writtenDate.FindStringSubmatch(line); m != nil {
+			month, err := time.Parse("Jan", strings.ToUpper(m[1][:1])+strings.ToLower(m[1][1:3]))
+			if err != nil {
+				continue
+			}
+			if date, ok := makeDate(m[3], strconv.Itoa(int(month.Month())), m[2]); ok {
+				return date, true
+			}
+		}
+	}
+	return "", false
+}
+
+// makeDate formats a date from its parts, rejecting dates that don't exist
+func makeDate(year, month, day string) (string, bool) {
+	y, errY := strconv.Atoi(year)
+	m, errM := strconv.Atoi(month)
+	d, errD := strconv.Atoi(day)
+	if errY != nil || errM != nil || errD != nil {
+		return "", false
+	}
+	date := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if date.Year() != y || int(date.Month()) != m || date.Day() != d {
+		return "", false
+	}
+	return date.Format("2006-01-02"), true
+}
+
+// findTotal returns the amount on the line with the most specific total label
+// When a label appears more than once, the last one wins, since running totals come first.
+func findTotal(lines []string) (float64, bool) {
+	best := len(totalKeywords)
+	var total float64
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		if containsAny(lower, notTotalKeywords) {
+			continue
+		}
+		for rank, keyword := range totalKeywords {
+			if rank > best || !strings.Contains(lower, keyword) {
+				continue
+			}
+			amounts := lineAmounts(line)
+			if len(amounts) == 0 {
+				continue
+			}
+			best = rank
+			total = amounts[len(amounts)-1]
+			break
+		}
+	}
+	return total, best < len(totalKeywords)
+}
+
+// largestAmount returns the largest amount anywhere on the receipt
+func largestAmount(lines []string) (float64, bool) {
+	var largest float64
+	for _, line := range lines {
+		for _, amount := range lineAmounts(line) {
+			largest = max(largest, amount)
+		}
+	}
+	return largest, largest > 0
+}
+
+// lineAmounts returns the dollar amounts on a line, in order
+func lineAmounts(line string) []float64 {
+	var amounts []float64
+	for _, m := range amountPattern.FindAllStringSubmatch(line, -1) {
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", "")+"."+m[2], 64)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(m[0], "-") {
+			amount = -amount
+		}
+		amounts = append(amounts, amount)
+	}
+	return amounts
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
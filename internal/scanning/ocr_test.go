@@ -0,0 +1,114 @@
+package scanning
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("extractReceiptText", func() {
+	var (
+		text string
+		data *ReceiptData
+	)
+
+	JustBeforeEach(func() {
+		data = extractReceiptText(text)
+	})
+
+	When("the receipt has a merchant, date and total", func() {
+		BeforeEach(func() {
+			text = `
+#1234 STORE
+CVS/pharmacy
+(555) 123-4567
+03/07/24 10:42 AM
+AMOXICILLIN 500MG      10.00
+SHAMPOO                 6.49
+SUBTOTAL               16.49
+TAX                     0.52
+TOTAL                  17.01
+VISA                   17.01
+`
+		})
+
+		It("uses the first line that looks like a name as the merchant", func() {
+			Expect(data.Title).To(Equal("CVS/pharmacy"))
+			Expect(data.Fields.Title).To(Equal(FieldConfidence{Provenance: ProvenanceExtracted, Confidence: ocrMerchantConfidence}))
+		})
+
+		It("reads the date, month first", func() {
+			Expect(data.Date).To(Equal("2024-03-07"))
+		})
+
+		It("uses the amount on the total line", func() {
+			Expect(data.Amount).To(Equal(17.01))
+			Expect(data.Fields.Amount.Provenance).To(Equal(ProvenanceExtracted))
+		})
+
+		It("keeps the raw text and records the rules version", func() {
+			Expect(data.RawText).To(Equal(text))
+			Expect(data.PromptVersion).To(Equal(ocrRulesVersion))
+		})
+	})
+
+	When("a bill shows both total charges and the amount due", func() {
+		BeforeEach(func() {
+			text = `City General Hospital
+Statement Date: March 15, 2024
+Total Charges $1,250.00
+Insurance Payments -$1,000.00
+Amount Due $250.00`
+		})
+
+		It("uses the amount due", func() {
+			Expect(data.Amount).To(Equal(250.0))
+		})
+
+		It("reads a written date", func() {
+			Expect(data.Date).To(Equal("2024-03-15"))
+		})
+	})
+
+	When("a total label appears more than once", func() {
+		BeforeEach(func() {
+			text = "Walgreens\nTOTAL 5.00\nCOUPON -1.00\nTOTAL 4.00"
+		})
+
+		It("uses the last one", func() {
+			Expect(data.Amount).To(Equal(4.0))
+		})
+	})
+
+	When("the total line counts the items", func() {
+		BeforeEach(func() {
+			text = "Rite Aid\nSALINE SPRAY 8.99\nVITAMIN D 15.99\nSUBTOTAL 24.98\nTOTAL 3 ITEMS 24.99\nCASH 30.00"
+		})
+
+		It("uses the amount on the total line", func() {
+			Expect(data.Amount).To(Equal(24.99))
+			Expect(data.Fields.Amount.Provenance).To(Equal(ProvenanceExtracted))
+		})
+	})
+
+	When("there's no total line", func() {
+		BeforeEach(func() {
+			text = "Corner Drug\nBANDAGES 3.99\nGAUZE 12.50"
+		})
+
+		It("infers the largest amount with low confidence", func() {
+			Expect(data.Amount).To(Equal(12.5))
+			Expect(data.Fields.Amount).To(Equal(FieldConfidence{Provenance: ProvenanceInferred, Confidence: ocrFallbackConfidence}))
+		})
+	})
+
+	When("the date doesn't exist", func() {
+		BeforeEach(func() {
+			text = "Corner Drug\n13/45/2024\nTOTAL 1.00"
+		})
+
+		It("leaves the date for the defaults", func() {
+			Expect(data.Date).To(BeEmpty())
+			Expect(data.Fields.Date).To(BeZero())
+		})
+	})
+})
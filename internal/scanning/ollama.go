@@ -6,9 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -17,6 +15,7 @@ type Ollama struct {
 	baseURL string
 	model   string
 	client  *http.Client
+	opts    options
 }
 
 // NewOllama creates a new Ollama Scanner instance
@@ -28,7 +27,7 @@ type Ollama struct {
 //   - llava-phi3 (smaller, faster, but less accurate)
 //
 // Note: Some models may struggle with PDFs - consider converting PDFs to images first
-func NewOllama(baseURL string, modelName string) (*Ollama, error) {
+func NewOllama(baseURL string, modelName string, opts ...Option) (*Ollama, error) {
 	if baseURL == "" {
 		baseURL = "http://localhost:11434"
 	}
@@ -36,12 +35,14 @@ func NewOllama(baseURL string, modelName string) (*Ollama, error) {
 		modelName = "llava" // Default to llava, a popular vision model
 	}
 
+	cfg := newOptions(opts)
 	return &Ollama{
 		baseURL: baseURL,
 		model:   modelName,
 		client: &http.Client{
-			Timeout: 120 * time.Second, // Ollama can be slower, especially for vision models
+			Timeout: cfg.timeoutOr(120 * time.Second), // Ollama can be slower, especially for vision models
 		},
+		opts: cfg,
 	}, nil
 }
 
@@ -50,12 +51,13 @@ type ollamaChatRequest struct {
 	Model    string          `json:"model"`
 	Messages []ollamaMessage `json:"messages"`
 	Stream   bool            `json:"stream"`
-	Images   []string        `json:"images,omitempty"`
+	Format   *schema         `json:"format,omitempty"` // JSON schema the response must follow
 }
 
 type ollamaMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // Base64-encoded images, in page order
 }
 
 // ollamaChatResponse represents the response from Ollama's chat API
@@ -65,79 +67,109 @@ type ollamaChatResponse struct {
 }
 
 // ScanReceipt analyzes a receipt and extracts metadata
-func (o *Ollama) ScanReceipt(imageData []byte, contentType string) (*ReceiptData, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
+func (o *Ollama) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	prompt, err := o.opts.prompts.forReceipt(docType)
+	if err != nil {
+		return nil, err
+	}
 
-	// Prepare image data (convert to PNG if needed)
-	finalImageData, _, _, err := prepareImageData(imageData, contentType)
+	text, err := o.chat(imageData, contentType, prompt, receiptSchema)
 	if err != nil {
 		return nil, err
 	}
 
-	// Encode image as base64
-	imageBase64 := base64.StdEncoding.EncodeToString(finalImageData)
+	data, err := parseReceiptJSON(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing receipt data: %w", err)
+	}
+	data.DocumentType = docType
+	data.PromptVersion = prompt.Version
+
+	return data, nil
+}
+
+// ScanEOB analyzes an insurance explanation of benefits and extracts the claim amounts
+func (o *Ollama) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	prompt, err := o.opts.prompts.For(DocumentEOB)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := o.chat(imageData, contentType, prompt, eobSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := parseEOBJSON(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing eob data: %w", err)
+	}
+
+	return data, nil
+}
+
+// chat sends the document and prompts to Ollama and returns the JSON response
+// The response is constrained to the given schema.
+func (o *Ollama) chat(imageData []byte, contentType string, prompt Prompt, responseSchema *schema) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.opts.timeoutOr(120*time.Second))
+	defer cancel()
+
+	// Prepare image data (convert to PNG if needed, one image per PDF page)
+	pages, text, err := prepareImages(imageData, contentType, prompt.Text, o.opts.pdf)
+	if err != nil {
+		return "", err
+	}
+
+	// Encode images as base64
+	images := make([]string, 0, len(pages))
+	for _, page := range pages {
+		images = append(images, base64.StdEncoding.EncodeToString(page))
+	}
 
 	// Prepare the request with system message for better context
+	var messages []ollamaMessage
+	if prompt.System != "" {
+		messages = append(messages, ollamaMessage{Role: "system", Content: prompt.System})
+	}
+	messages = append(messages, ollamaMessage{Role: "user", Content: text, Images: images})
+
 	reqBody := ollamaChatRequest{
-		Model:  o.model,
-		Stream: false,
-		Messages: []ollamaMessage{
-			{
-				Role:    "system",
-				Content: "You are an expert at reading and extracting information from receipts and invoices. You must carefully read all text in images and extract accurate information.",
-			},
-			{
-				Role:    "user",
-				Content: receiptScanPrompt,
-			},
-		},
-		Images: []string{imageBase64},
+		Model:    o.model,
+		Stream:   false,
+		Format:   responseSchema,
+		Messages: messages,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
+		return "", fmt.Errorf("marshaling request: %w", err)
 	}
 
 	// Make the request
 	url := fmt.Sprintf("%s/api/chat", o.baseURL)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return "", fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("calling ollama API: %w", err)
+		return "", fmt.Errorf("calling ollama API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body))
+		return "", newAPIError("ollama", resp)
 	}
 
 	// Parse response
 	var chatResp ollamaChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
-	}
-
-	// Extract text response
-	text := strings.TrimSpace(chatResp.Message.Content)
-	// Remove markdown code blocks if present
-	text = strings.TrimPrefix(text, "```json")
-	text = strings.TrimPrefix(text, "```")
-	text = strings.TrimSpace(text)
-
-	data, err := parseReceiptJSON(text)
-	if err != nil {
-		return nil, fmt.Errorf("parsing receipt data: %w", err)
+		return "", fmt.Errorf("decoding response: %w", err)
 	}
 
-	return data, nil
+	return chatResp.Message.Content, nil
 }
 
 // Close closes the Ollama client (no-op for HTTP client)
@@ -0,0 +1,87 @@
+package scanning
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ollama", func() {
+	var (
+		server   *httptest.Server
+		request  ollamaChatRequest
+		response string
+		scanner  *Ollama
+		data     *ReceiptData
+		err      error
+	)
+
+	BeforeEach(func() {
+		response = `{"title": "CVS", "date": "2024-01-15", "amount": 25.99, "category": "pharmacy",
+			"fields": {"title": {"provenance": "extracted", "confidence": 0.9}}}`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+			json.NewEncoder(w).Encode(ollamaChatResponse{
+				Message: ollamaMessage{Role: "assistant", Content: response},
+				Done:    true,
+			})
+		}))
+		DeferCleanup(server.Close)
+
+		scanner, err = NewOllama(server.URL, "llava", WithPDFDPI(36))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		data, err = scanner.ScanReceipt(testPDF(2), "application/pdf", DocumentPharmacy)
+	})
+
+	It("constrains the response to the receipt schema", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(request.Format.Required).To(ContainElement("fields"))
+		Expect(request.Format.Properties).To(HaveKey("line_items"))
+	})
+
+	It("uses the prompt for the document type", func() {
+		prompt, _ := defaultPrompts.For(DocumentPharmacy)
+		Expect(request.Messages[0]).To(Equal(ollamaMessage{Role: "system", Content: prompt.System}))
+		Expect(request.Messages[1].Content).To(HaveSuffix(prompt.Text))
+		Expect(data.DocumentType).To(Equal(DocumentPharmacy))
+		Expect(data.PromptVersion).To(Equal(prompt.Version))
+	})
+
+	It("sends every page with the user message", func() {
+		Expect(request.Messages).To(HaveLen(2))
+		Expect(request.Messages[0].Images).To(BeEmpty())
+		Expect(request.Messages[1].Images).To(HaveLen(2))
+		Expect(request.Messages[1].Content).To(HavePrefix("This document has 2 pages"))
+	})
+
+	It("parses the response", func() {
+		Expect(data.Title).To(Equal("CVS"))
+		Expect(data.Fields.Title).To(Equal(FieldConfidence{Provenance: ProvenanceExtracted, Confidence: 0.9}))
+	})
+
+	When("the document type isn't a receipt", func() {
+		JustBeforeEach(func() {
+			data, err = scanner.ScanReceipt(testPDF(1), "application/pdf", DocumentEOB)
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ErrUnknownDocumentType))
+		})
+	})
+
+	When("the response doesn't match the schema", func() {
+		BeforeEach(func() {
+			response = `{"title": "CVS", "amount": "a lot"}`
+		})
+
+		It("reports the field that violated it", func() {
+			Expect(err).To(MatchError(ContainSubstring("amount: expected number, got string")))
+		})
+	})
+})
@@ -0,0 +1,214 @@
+package scanning
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAI implements the Scanner interface using the OpenAI chat completions API
+// Besides OpenAI itself, most local runtimes serve this API, including the llama.cpp
+// server, vLLM, LM Studio and LocalAI.
+type OpenAI struct {
+	baseURL string
+	model   string
+	apiKey  string
+	client  *http.Client
+	opts    options
+}
+
+// NewOpenAI creates a new OpenAI-compatible Scanner instance
+// baseURL is the API root including its version, e.g. "http://localhost:8000/v1".
+// The API key is optional, since local runtimes usually don't need one.
+func NewOpenAI(baseURL string, modelName string, apiKey string, opts ...Option) (*OpenAI, error) {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	if modelName == "" {
+		return nil, fmt.Errorf("openai model is required")
+	}
+
+	cfg := newOptions(opts)
+	return &OpenAI{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   modelName,
+		apiKey:  apiKey,
+		client: &http.Client{
+			Timeout: cfg.timeoutOr(120 * time.Second), // Local vision models can be slow
+		},
+		opts: cfg,
+	}, nil
+}
+
+// openAIChatRequest represents the request body for the chat completions API
+type openAIChatRequest struct {
+	Model          string               `json:"model"`
+	Messages       []openAIMessage      `json:"messages"`
+	ResponseFormat openAIResponseFormat `json:"response_format"`
+	Temperature    float64              `json:"temperature"`
+}
+
+// openAIMessage is a chat message; Content is a string, or content parts for messages with images
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+// openAIContentPart is a piece of text or an image in a message
+type openAIContentPart struct {
+	Type     string          `json:"type"` // "text" or "image_url"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"` // A data URL for inline images
+}
+
+// openAIResponseFormat constrains the response to a JSON schema
+type openAIResponseFormat struct {
+	Type       string           `json:"type"` // Always "json_schema"
+	JSONSchema openAIJSONSchema `json:"json_schema"`
+}
+
+type openAIJSONSchema struct {
+	Name   string  `json:"name"`
+	Schema *schema `json:"schema"`
+}
+
+// openAIChatResponse represents the response from the chat completions API
+type openAIChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// ScanReceipt analyzes a receipt and extracts metadata
+func (o *OpenAI) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	prompt, err := o.opts.prompts.forReceipt(docType)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := o.complete(imageData, contentType, prompt, "receipt", receiptSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := parseReceiptJSON(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing receipt data: %w", err)
+	}
+	data.DocumentType = docType
+	data.PromptVersion = prompt.Version
+
+	return data, nil
+}
+
+// ScanEOB analyzes an insurance explanation of benefits and extracts the claim amounts
+func (o *OpenAI) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	prompt, err := o.opts.prompts.For(DocumentEOB)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := o.complete(imageData, contentType, prompt, "eob", eobSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := parseEOBJSON(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing eob data: %w", err)
+	}
+
+	return data, nil
+}
+
+// complete sends the document and prompts to the chat completions API and returns the JSON response
+// The response is constrained to the given schema, which is sent under schemaName.
+func (o *OpenAI) complete(imageData []byte, contentType string, prompt Prompt, schemaName string, responseSchema *schema) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.opts.timeoutOr(120*time.Second))
+	defer cancel()
+
+	// Prepare image data (convert to PNG if needed, one image per PDF page)
+	pages, text, err := prepareImages(imageData, contentType, prompt.Text, o.opts.pdf)
+	if err != nil {
+		return "", err
+	}
+
+	// Images are sent inline as data URLs, in page order, followed by the instructions
+	parts := make([]openAIContentPart, 0, len(pages)+1)
+	for _, page := range pages {
+		parts = append(parts, openAIContentPart{
+			Type:     "image_url",
+			ImageURL: &openAIImageURL{URL: "data:image/png;base64," + base64.StdEncoding.EncodeToString(page)},
+		})
+	}
+	parts = append(parts, openAIContentPart{Type: "text", Text: text})
+
+	var messages []openAIMessage
+	if prompt.System != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: prompt.System})
+	}
+	messages = append(messages, openAIMessage{Role: "user", Content: parts})
+
+	reqBody := openAIChatRequest{
+		Model:    o.model,
+		Messages: messages,
+		ResponseFormat: openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: openAIJSONSchema{Name: schemaName, Schema: responseSchema},
+		},
+		Temperature: 0, // Extraction should be repeatable
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("marshaling request: %w", err)
+	}
+
+	// Make the request
+	url := fmt.Sprintf("%s/chat/completions", o.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("calling openai API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError("openai", resp)
+	}
+
+	// Parse response
+	var chatResp openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("no response from openai API")
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+// Close closes the OpenAI client (no-op for HTTP client)
+func (o *OpenAI) Close() error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package scanning
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OpenAI", func() {
+	// openAIRequest mirrors openAIChatRequest with the message content left undecoded
+	type openAIRequest struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+		ResponseFormat openAIResponseFormat `json:"response_format"`
+	}
+
+	var (
+		server        *httptest.Server
+		request       openAIRequest
+		authorization string
+		status        int
+		response      string
+		apiKey        string
+		scanner       *OpenAI
+		data          *ReceiptData
+		err           error
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		response = `{"title": "CVS", "date": "2024-01-15", "amount": 25.99, "category": "pharmacy",
+			"fields": {"title": {"provenance": "extracted", "confidence": 0.9}}}`
+		apiKey = ""
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/v1/chat/completions"))
+			authorization = r.Header.Get("Authorization")
+			Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]any{
+				"choices": []map[string]any{
+					{"message": map[string]string{"role": "assistant", "content": response}},
+				},
+			})
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	JustBeforeEach(func() {
+		scanner, err = NewOpenAI(server.URL+"/v1/", "qwen2-vl", apiKey, WithPDFDPI(36))
+		Expect(err).NotTo(HaveOccurred())
+		data, err = scanner.ScanReceipt(testPDF(2), "application/pdf", DocumentReceipt)
+	})
+
+	It("parses the response", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data.Title).To(Equal("CVS"))
+		Expect(data.Amount).To(Equal(25.99))
+		Expect(data.DocumentType).To(Equal(DocumentReceipt))
+	})
+
+	It("sends the model and constrains the response to the receipt schema", func() {
+		Expect(request.Model).To(Equal("qwen2-vl"))
+		Expect(request.ResponseFormat.Type).To(Equal("json_schema"))
+		Expect(request.ResponseFormat.JSONSchema.Name).To(Equal("receipt"))
+		Expect(request.ResponseFormat.JSONSchema.Schema.Required).To(ContainElement("fields"))
+	})
+
+	It("sends the system prompt and every page as an inline image before the instructions", func() {
+		prompt, _ := defaultPrompts.For(DocumentReceipt)
+		Expect(request.Messages).To(HaveLen(2))
+		Expect(request.Messages[0].Role).To(Equal("system"))
+		var system string
+		Expect(json.Unmarshal(request.Messages[0].Content, &system)).To(Succeed())
+		Expect(system).To(Equal(prompt.System))
+
+		var parts []openAIContentPart
+		Expect(json.Unmarshal(request.Messages[1].Content, &parts)).To(Succeed())
+		Expect(parts).To(HaveLen(3))
+		Expect(parts[0].Type).To(Equal("image_url"))
+		Expect(parts[0].ImageURL.URL).To(HavePrefix("data:image/png;base64,"))
+		Expect(parts[1].Type).To(Equal("image_url"))
+		Expect(parts[2].Type).To(Equal("text"))
+		Expect(parts[2].Text).To(HavePrefix("This document has 2 pages"))
+	})
+
+	It("doesn't send an API key when there isn't one", func() {
+		Expect(authorization).To(BeEmpty())
+	})
+
+	When("an API key is set", func() {
+		BeforeEach(func() {
+			apiKey = "sk-test"
+		})
+
+		It("sends it as a bearer token", func() {
+			Expect(authorization).To(Equal("Bearer sk-test"))
+		})
+	})
+
+	When("the API returns an error", func() {
+		BeforeEach(func() {
+			status = http.StatusBadRequest
+		})
+
+		It("returns the error with the status", func() {
+			Expect(err).To(MatchError(ContainSubstring("openai API error (status 400)")))
+		})
+	})
+
+	When("the API is rate limiting", func() {
+		BeforeEach(func() {
+			status = http.StatusTooManyRequests
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "20")
+				w.WriteHeader(status)
+			})
+		})
+
+		It("returns a quota error with the requested wait", func() {
+			Expect(err).To(MatchError(ErrQuotaExhausted))
+			var apiErr *APIError
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.RetryAfter).To(Equal(20 * time.Second))
+		})
+	})
+
+	When("the response doesn't match the schema", func() {
+		BeforeEach(func() {
+			response = `{"title": "CVS", "amount": "a lot"}`
+		})
+
+		It("reports the field that violated it", func() {
+			Expect(err).To(MatchError(ContainSubstring("amount: expected number, got string")))
+		})
+	})
+})
+
+var _ = Describe("NewOpenAI", func() {
+	When("no model is given", func() {
+		It("returns an error", func() {
+			_, err := NewOpenAI("http://localhost:8000/v1", "", "")
+			Expect(err).To(MatchError("openai model is required"))
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package scanning
+
+import "time"
+
+// Defaults for rendering PDF pages
+const (
+	DefaultPDFMaxPages = 10  // Most pages of a PDF sent to the model
+	DefaultPDFDPI      = 200 // Sharp enough for small print without making every page a huge image
+)
+
+// Option configures a scanner
+type Option func(*options)
+
+// options holds the settings shared by all scanners
+type options struct {
+	pdf     pdfOptions
+	prompts *Prompts
+	timeout time.Duration // Zero uses the scanner's own default
+}
+
+// pdfOptions controls how PDF pages are rendered to images
+type pdfOptions struct {
+	maxPages int
+	dpi      float64
+}
+
+// newOptions applies opts over the defaults
+func newOptions(opts []Option) options {
+	o := options{
+		pdf:     pdfOptions{maxPages: DefaultPDFMaxPages, dpi: DefaultPDFDPI},
+		prompts: defaultPrompts,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithPDFMaxPages limits how many pages of a PDF are sent to the model
+// Longer documents send the first pages and the last page, which usually has the totals.
+func WithPDFMaxPages(maxPages int) Option {
+	return func(o *options) {
+		if maxPages > 0 {
+			o.pdf.maxPages = maxPages
+		}
+	}
+}
+
+// WithPDFDPI sets the resolution PDF pages are rendered at
+func WithPDFDPI(dpi float64) Option {
+	return func(o *options) {
+		if dpi > 0 {
+			o.pdf.dpi = dpi
+		}
+	}
+}
+
+// WithPrompts replaces the built-in prompts, e.g. with ones loaded by LoadPrompts
+func WithPrompts(prompts *Prompts) Option {
+	return func(o *options) {
+		if prompts != nil {
+			o.prompts = prompts
+		}
+	}
+}
+
+// WithTimeout limits how long a single call to the model may take
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// timeoutOr returns the configured call timeout, or the scanner's default when none was set
+func (o options) timeoutOr(def time.Duration) time.Duration {
+	if o.timeout > 0 {
+		return o.timeout
+	}
+	return def
+}
@@ -3,79 +3,211 @@ package scanning
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 )
 
 // parseReceiptJSON parses the JSON response from Gemini
 func parseReceiptJSON(text string) (*ReceiptData, error) {
+	text, err := extractJSONObject(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := receiptSchema.validate([]byte(text)); err != nil {
+		return nil, err
+	}
+
+	var data ReceiptData
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		return nil, fmt.Errorf("unmarshaling json: %w", err)
+	}
+
+	if err := normalizeReceipt(&data); err != nil {
+		return nil, err
+	}
+
+	// Note: Amount is kept as float64 here (for JSON unmarshaling from Gemini)
+	// It will be converted to int cents in the service layer when creating the Receipt model
+
+	return &data, nil
+}
+
+// normalizeReceipt cleans up extracted receipt data in place
+// Missing details are filled in with defaults and marked as defaulted.
+func normalizeReceipt(data *ReceiptData) error {
+	// Validate and parse date, defaulting to today if it is missing or can't be parsed
+	if date, ok := normalizeDate(data.Date); ok {
+		data.Date = date
+		data.Fields.Date = reported(data.Fields.Date, ProvenanceExtracted)
+	} else {
+		data.Date = time.Now().Format("2006-01-02")
+		data.Fields.Date = defaulted()
+	}
+
+	// Clean up title
+	data.Title = strings.TrimSpace(data.Title)
+	if data.Title == "" {
+		data.Title = "Unknown Expense"
+		data.Fields.Title = defaulted()
+	} else {
+		data.Fields.Title = reported(data.Fields.Title, ProvenanceExtracted)
+	}
+
+	if data.Amount <= 0 {
+		data.Fields.Amount = defaulted()
+	} else {
+		data.Fields.Amount = reported(data.Fields.Amount, ProvenanceExtracted)
+	}
+
+	// Normalize category; the schema limits it to the taxonomy, but it may still be missing
+	// Categories are a judgement about the document, so they're inferred unless the scanner says otherwise
+	data.Category = strings.ToLower(strings.TrimSpace(data.Category))
+	if data.Category == "" {
+		data.Fields.Category = defaulted()
+	} else {
+		data.Fields.Category = reported(data.Fields.Category, ProvenanceInferred)
+	}
+
+	return validateLineItems(data)
+}
+
+// reported cleans up the confidence the scanner reported for a field it filled in
+// Fields the scanner didn't describe fall back to the given provenance.
+func reported(field FieldConfidence, fallback Provenance) FieldConfidence {
+	field.Provenance = Provenance(strings.ToLower(strings.TrimSpace(string(field.Provenance))))
+	if field.Provenance == "" {
+		field.Provenance = fallback
+	}
+	field.Confidence = math.Min(math.Max(field.Confidence, 0), 1)
+	return field
+}
+
+// defaulted is the confidence of a field that was filled in because nothing usable was found
+func defaulted() FieldConfidence {
+	return FieldConfidence{Provenance: ProvenanceDefaulted}
+}
+
+// validateLineItems checks the extracted line items and flags receipts whose items
+// do not add up to the total. Items are cleaned up in place.
+func validateLineItems(data *ReceiptData) error {
+	if len(data.LineItems) == 0 {
+		return nil
+	}
+
+	var sumCents int64
+	for i := range data.LineItems {
+		item := &data.LineItems[i]
+		item.Description = strings.TrimSpace(item.Description)
+		if item.Description == "" {
+			return fmt.Errorf("line item %d: description is required", i+1)
+		}
+		if item.Quantity < 0 {
+			return fmt.Errorf("line item %d: quantity cannot be negative", i+1)
+		}
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		sumCents += int64(math.Round(item.Amount * 100))
+	}
+
+	// Allow a cent of rounding difference per item
+	diff := sumCents - int64(math.Round(data.Amount*100))
+	if diff < 0 {
+		diff = -diff
+	}
+	data.LineItemsMismatch = diff > int64(len(data.LineItems))
+
+	return nil
+}
+
+// extractJSONObject strips markdown code blocks and surrounding prose from an LLM response
+func extractJSONObject(text string) (string, error) {
 	// Remove markdown code blocks if present
 	text = strings.TrimSpace(text)
-	
+
 	// Remove opening markdown code blocks
 	text = strings.TrimPrefix(text, "```json")
 	text = strings.TrimPrefix(text, "```")
 	text = strings.TrimSpace(text)
-	
+
 	// Find the JSON object boundaries - look for first { and last }
 	startIdx := strings.Index(text, "{")
 	if startIdx == -1 {
-		return nil, fmt.Errorf("no JSON object found in response")
+		return "", fmt.Errorf("no JSON object found in response")
 	}
-	
+
 	endIdx := strings.LastIndex(text, "}")
 	if endIdx == -1 || endIdx < startIdx {
-		return nil, fmt.Errorf("invalid JSON object in response")
+		return "", fmt.Errorf("invalid JSON object in response")
 	}
-	
+
 	// Extract just the JSON part
-	text = text[startIdx : endIdx+1]
+	return text[startIdx : endIdx+1], nil
+}
 
-	var data ReceiptData
+// normalizeDate converts a date in one of the common formats to YYYY-MM-DD
+// The second return value is false if the date is empty or can't be parsed.
+func normalizeDate(date string) (string, bool) {
+	if date == "" {
+		return "", false
+	}
+	formats := []string{
+		"2006-01-02",
+		"2006/01/02",
+		"01/02/2006",
+		"02-01-2006",
+	}
+	for _, format := range formats {
+		if d, err := time.Parse(format, date); err == nil {
+			return d.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+// parseEOBJSON parses the JSON response for an explanation of benefits
+func parseEOBJSON(text string) (*EOBData, error) {
+	text, err := extractJSONObject(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := eobSchema.validate([]byte(text)); err != nil {
+		return nil, err
+	}
+
+	var data EOBData
 	if err := json.Unmarshal([]byte(text), &data); err != nil {
 		return nil, fmt.Errorf("unmarshaling json: %w", err)
 	}
 
-	// Validate and parse date
-	if data.Date != "" {
-		// Try to parse the date
-		parsedDate, err := time.Parse("2006-01-02", data.Date)
-		if err != nil {
-			// Try other common formats
-			formats := []string{
-				"2006/01/02",
-				"01/02/2006",
-				"02-01-2006",
-			}
-			parsed := false
-			for _, format := range formats {
-				if d, e := time.Parse(format, data.Date); e == nil {
-					data.Date = d.Format("2006-01-02")
-					parsed = true
-					break
-				}
-			}
-			if !parsed {
-				// If we can't parse it, use today's date
-				data.Date = time.Now().Format("2006-01-02")
-			}
-		} else {
-			data.Date = parsedDate.Format("2006-01-02")
+	// An EOB without a service date can't be matched, so leave it empty rather than guessing
+	data.ServiceDate, _ = normalizeDate(strings.TrimSpace(data.ServiceDate))
+	data.Provider = strings.TrimSpace(data.Provider)
+	data.ClaimNumber = strings.TrimSpace(data.ClaimNumber)
+
+	amounts := []struct {
+		name  string
+		value float64
+	}{
+		{"billed", data.Billed},
+		{"allowed", data.Allowed},
+		{"insurer_paid", data.InsurerPaid},
+		{"patient_responsibility", data.PatientResponsibility},
+	}
+	for _, amount := range amounts {
+		if amount.value < 0 {
+			return nil, fmt.Errorf("%s amount cannot be negative", amount.name)
 		}
-	} else {
-		// Default to today if no date found
-		data.Date = time.Now().Format("2006-01-02")
 	}
 
-	// Clean up title
-	data.Title = strings.TrimSpace(data.Title)
-	if data.Title == "" {
-		data.Title = "Unknown Expense"
+	// Allow a cent of rounding difference
+	if data.Allowed > 0 {
+		diff := math.Round(data.Allowed*100) - math.Round((data.InsurerPaid+data.PatientResponsibility)*100)
+		data.AmountsMismatch = math.Abs(diff) > 1
 	}
 
-	// Note: Amount is kept as float64 here (for JSON unmarshaling from Gemini)
-	// It will be converted to int cents in the service layer when creating the Receipt model
-
 	return &data, nil
 }
-
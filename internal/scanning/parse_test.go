@@ -1,6 +1,7 @@
 package scanning
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -44,6 +45,38 @@ var _ = Describe("parseReceiptJSON", func() {
 		It("should parse the amount correctly", func() {
 			Expect(data.Amount).To(Equal(25.99))
 		})
+
+		It("should mark the fields it found as extracted", func() {
+			Expect(data.Fields.Title.Provenance).To(Equal(ProvenanceExtracted))
+			Expect(data.Fields.Date.Provenance).To(Equal(ProvenanceExtracted))
+			Expect(data.Fields.Amount.Provenance).To(Equal(ProvenanceExtracted))
+		})
+
+		It("should mark the missing category as defaulted", func() {
+			Expect(data.Fields.Category).To(Equal(FieldConfidence{Provenance: ProvenanceDefaulted}))
+		})
+	})
+
+	When("the scanner reports field confidence", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "CVS", "date": "2024-01-15", "amount": 25.99, "category": "pharmacy",
+				"fields": {
+					"title": {"provenance": "inferred", "confidence": 0.4},
+					"date": {"confidence": 1.5}
+				}}`
+		})
+
+		It("should keep the reported provenance and confidence", func() {
+			Expect(data.Fields.Title).To(Equal(FieldConfidence{Provenance: ProvenanceInferred, Confidence: 0.4}))
+		})
+
+		It("should clamp confidences to between 0 and 1", func() {
+			Expect(data.Fields.Date).To(Equal(FieldConfidence{Provenance: ProvenanceExtracted, Confidence: 1}))
+		})
+
+		It("should treat an unreported category as inferred", func() {
+			Expect(data.Fields.Category).To(Equal(FieldConfidence{Provenance: ProvenanceInferred}))
+		})
 	})
 
 	When("parsing JSON with markdown code blocks", func() {
@@ -77,6 +110,10 @@ var _ = Describe("parseReceiptJSON", func() {
 			expectedDate := time.Now().Format("2006-01-02")
 			Expect(data.Date).To(Equal(expectedDate))
 		})
+
+		It("should mark the date as defaulted", func() {
+			Expect(data.Fields.Date).To(Equal(FieldConfidence{Provenance: ProvenanceDefaulted}))
+		})
 	})
 
 	When("parsing JSON with empty title", func() {
@@ -91,6 +128,21 @@ var _ = Describe("parseReceiptJSON", func() {
 		It("should default to Unknown Expense", func() {
 			Expect(data.Title).To(Equal("Unknown Expense"))
 		})
+
+		It("should mark the title as defaulted", func() {
+			Expect(data.Fields.Title.Provenance).To(Equal(ProvenanceDefaulted))
+		})
+	})
+
+	When("parsing JSON with no amount", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "Test", "date": "2024-01-15", "amount": null}`
+		})
+
+		It("should mark the amount as defaulted", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data.Fields.Amount.Provenance).To(Equal(ProvenanceDefaulted))
+		})
 	})
 
 	When("parsing JSON with whitespace-only title", func() {
@@ -122,6 +174,129 @@ var _ = Describe("parseReceiptJSON", func() {
 		})
 	})
 
+	When("parsing JSON with a category", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "CVS Pharmacy", "date": "2024-01-15", "amount": 25.99, "category": " Pharmacy "}`
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should normalize the category", func() {
+			Expect(data.Category).To(Equal("pharmacy"))
+		})
+	})
+
+	When("parsing JSON with line items that sum to the total", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "CVS", "date": "2024-01-15", "amount": 15.50, "line_items": [
+				{"description": "Amoxicillin", "quantity": 1, "amount": 10.00, "eligible": true},
+				{"description": " Shampoo ", "amount": 5.50, "eligible": false}
+			]}`
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should parse every line item", func() {
+			Expect(data.LineItems).To(HaveLen(2))
+		})
+
+		It("should trim descriptions", func() {
+			Expect(data.LineItems[1].Description).To(Equal("Shampoo"))
+		})
+
+		It("should default a missing quantity to 1", func() {
+			Expect(data.LineItems[1].Quantity).To(Equal(1.0))
+		})
+
+		It("should not flag a mismatch", func() {
+			Expect(data.LineItemsMismatch).To(BeFalse())
+		})
+	})
+
+	When("parsing JSON with line items that do not sum to the total", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "CVS", "date": "2024-01-15", "amount": 20.00, "line_items": [
+				{"description": "Amoxicillin", "quantity": 1, "amount": 10.00, "eligible": true}
+			]}`
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should flag the mismatch", func() {
+			Expect(data.LineItemsMismatch).To(BeTrue())
+		})
+	})
+
+	When("parsing JSON with a line item missing its description", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "CVS", "date": "2024-01-15", "amount": 10.00, "line_items": [
+				{"description": "", "quantity": 1, "amount": 10.00, "eligible": true}
+			]}`
+		})
+
+		It("returns the error", func() {
+			Expect(err).To(MatchError("line item 1: description is required"))
+		})
+	})
+
+	When("parsing JSON with malformed line items", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "CVS", "date": "2024-01-15", "amount": 10.00, "line_items": "none"}`
+		})
+
+		It("returns the error", func() {
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("parsing JSON that doesn't match the schema", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "CVS", "date": "2024-01-15", "amount": "10.00",
+				"line_items": [{"description": "Gauze", "quantity": 1, "amount": 10.00, "eligible": "yes"}],
+				"fields": {"title": {"provenance": "guessed", "confidence": 0.5}}}`
+		})
+
+		It("reports every field that violated it", func() {
+			var schemaErr *SchemaError
+			Expect(errors.As(err, &schemaErr)).To(BeTrue())
+			Expect(schemaErr.Violations).To(ConsistOf(
+				"amount: expected number, got string",
+				"line_items[0].eligible: expected boolean, got string",
+				`fields.title.provenance: "guessed" is not one of extracted, inferred`,
+			))
+		})
+	})
+
+	When("parsing JSON with a category outside the taxonomy", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": "Sephora", "date": "2024-01-15", "amount": 42.00, "category": "cosmetics"}`
+		})
+
+		It("reports the category as a schema violation", func() {
+			var schemaErr *SchemaError
+			Expect(errors.As(err, &schemaErr)).To(BeTrue())
+			Expect(schemaErr.Violations).To(ConsistOf(ContainSubstring(`category: "cosmetics" is not one of medical, dental`)))
+		})
+	})
+
+	When("parsing JSON with nulls for fields that weren't found", func() {
+		BeforeEach(func() {
+			jsonInput = `{"title": null, "date": null, "amount": null, "category": null}`
+		})
+
+		It("defaults them", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data.Title).To(Equal("Unknown Expense"))
+			Expect(data.Fields.Amount.Provenance).To(Equal(ProvenanceDefaulted))
+		})
+	})
+
 	When("parsing invalid JSON", func() {
 		BeforeEach(func() {
 			jsonInput = `invalid json`
@@ -132,3 +307,68 @@ var _ = Describe("parseReceiptJSON", func() {
 		})
 	})
 })
+
+var _ = Describe("parseEOBJSON", func() {
+	var (
+		jsonInput string
+		data      *EOBData
+		err       error
+	)
+
+	JustBeforeEach(func() {
+		data, err = parseEOBJSON(jsonInput)
+	})
+
+	When("parsing a valid EOB", func() {
+		BeforeEach(func() {
+			jsonInput = `{"provider": " Main Street Pediatrics ", "service_date": "03/14/2024", "claim_number": "C-1",
+				"billed": 250.00, "allowed": 180.00, "insurer_paid": 140.00, "patient_responsibility": 40.00}`
+		})
+
+		It("should not return an error", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should normalize the service date", func() {
+			Expect(data.ServiceDate).To(Equal("2024-03-14"))
+		})
+
+		It("should trim the provider", func() {
+			Expect(data.Provider).To(Equal("Main Street Pediatrics"))
+		})
+
+		It("should not flag the amounts", func() {
+			Expect(data.AmountsMismatch).To(BeFalse())
+		})
+	})
+
+	When("the amounts don't add up to the allowed amount", func() {
+		BeforeEach(func() {
+			jsonInput = `{"provider": "Clinic", "service_date": "2024-03-14", "billed": 250.00, "allowed": 180.00, "insurer_paid": 100.00, "patient_responsibility": 40.00}`
+		})
+
+		It("should flag the mismatch", func() {
+			Expect(data.AmountsMismatch).To(BeTrue())
+		})
+	})
+
+	When("the service date is missing", func() {
+		BeforeEach(func() {
+			jsonInput = `{"provider": "Clinic", "service_date": null, "patient_responsibility": 40.00}`
+		})
+
+		It("should leave it empty", func() {
+			Expect(data.ServiceDate).To(BeEmpty())
+		})
+	})
+
+	When("an amount is negative", func() {
+		BeforeEach(func() {
+			jsonInput = `{"provider": "Clinic", "service_date": "2024-03-14", "patient_responsibility": -5.00}`
+		})
+
+		It("returns the error", func() {
+			Expect(err).To(MatchError("patient_responsibility amount cannot be negative"))
+		})
+	})
+})
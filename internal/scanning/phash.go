@@ -0,0 +1,77 @@
+package scanning
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math/bits"
+)
+
+// PerceptualHash returns a 64-bit difference hash of a document's normalized PNG image
+// Unlike a content hash, visually similar images (re-encoded, resized or re-exported)
+// get hashes that differ in only a few bits; compare them with HashDistance.
+func PerceptualHash(imageData []byte, contentType string) (uint64, error) {
+	pngData, _, _, err := prepareImageData(imageData, contentType)
+	if err != nil {
+		return 0, fmt.Errorf("preparing image: %w", err)
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		return 0, fmt.Errorf("decoding PNG: %w", err)
+	}
+	return differenceHash(img), nil
+}
+
+// HashDistance returns the number of bits that differ between two perceptual hashes
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// differenceHash shrinks the image to a 9x8 grayscale grid and records whether
+// each cell is darker than its right-hand neighbour
+func differenceHash(img image.Image) uint64 {
+	const width, height = 9, 8
+	var grid [height][width]float64
+
+	bounds := img.Bounds()
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+			grid[y][x] = averageLuminance(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if grid[y][x] < grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageLuminance averages the brightness of a block of the image
+// Large blocks are sampled on a grid so full-resolution phone photos stay fast to hash.
+func averageLuminance(img image.Image, x0, y0, x1, y1 int) float64 {
+	const samples = 16
+	stepX := max((x1-x0)/samples, 1)
+	stepY := max((y1-y0)/samples, 1)
+
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
@@ -0,0 +1,70 @@
+package scanning
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// testDocument draws a page with dark bands, shifted by offset pixels
+func testDocument(width, height, offset int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			shade := uint8(255)
+			if ((x+offset)/(width/6))%2 == 0 && (y/(height/5))%2 == 1 {
+				shade = 40
+			}
+			img.SetGray(x, y, color.Gray{Y: shade})
+		}
+	}
+	return img
+}
+
+var _ = Describe("PerceptualHash", func() {
+	var (
+		original []byte
+		hash     uint64
+		err      error
+	)
+
+	BeforeEach(func() {
+		var buf bytes.Buffer
+		Expect(png.Encode(&buf, testDocument(360, 400, 0))).To(Succeed())
+		original = buf.Bytes()
+		hash, err = PerceptualHash(original, "image/png")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	When("the same image is re-encoded at a different size", func() {
+		It("should produce a nearly identical hash", func() {
+			var buf bytes.Buffer
+			Expect(jpeg.Encode(&buf, testDocument(720, 800, 0), &jpeg.Options{Quality: 60})).To(Succeed())
+			other, err := PerceptualHash(buf.Bytes(), "image/jpeg")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(HashDistance(hash, other)).To(BeNumerically("<=", 4))
+		})
+	})
+
+	When("the image is different", func() {
+		It("should produce a distant hash", func() {
+			var buf bytes.Buffer
+			Expect(png.Encode(&buf, testDocument(360, 400, 60))).To(Succeed())
+			other, err := PerceptualHash(buf.Bytes(), "image/png")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(HashDistance(hash, other)).To(BeNumerically(">", 10))
+		})
+	})
+
+	When("the data isn't an image", func() {
+		It("returns an error", func() {
+			_, err := PerceptualHash([]byte("not an image"), "image/png")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
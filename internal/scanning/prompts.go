@@ -0,0 +1,159 @@
+package scanning
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DocumentType is the kind of document being scanned, which selects the prompt used
+type DocumentType string
+
+// Supported document types
+const (
+	DocumentReceipt  DocumentType = "receipt"  // Store receipts and anything without a more specific type
+	DocumentPharmacy DocumentType = "pharmacy" // Pharmacy receipts for prescriptions
+	DocumentInvoice  DocumentType = "invoice"  // Bills and patient statements from healthcare providers
+	DocumentEOB      DocumentType = "eob"      // Insurance explanations of benefits
+)
+
+// DocumentTypes lists every supported document type
+var DocumentTypes = []DocumentType{DocumentReceipt, DocumentPharmacy, DocumentInvoice, DocumentEOB}
+
+// IsReceipt reports whether documents of this type are scanned into ReceiptData
+func (t DocumentType) IsReceipt() bool {
+	return t == DocumentReceipt || t == DocumentPharmacy || t == DocumentInvoice
+}
+
+// schema returns the schema of the response for this document type
+func (t DocumentType) schema() *schema {
+	if t == DocumentEOB {
+		return eobSchema
+	}
+	return receiptSchema
+}
+
+// ErrUnknownDocumentType is returned when there's no prompt for a document type
+var ErrUnknownDocumentType = errors.New("unknown document type")
+
+// Prompt is a rendered prompt for one document type
+type Prompt struct {
+	System  string // Context given to the model before the document, for scanners with a system message
+	Text    string // Instructions sent with the document
+	Version string // Identifies the prompt, so scans can be traced back to the prompt that produced them
+}
+
+// Prompts holds the prompt for each document type
+type Prompts struct {
+	prompts map[DocumentType]Prompt
+}
+
+// promptData is what prompt templates are rendered with
+type promptData struct {
+	DocumentType DocumentType
+	Schema       string // JSON Schema of the expected response
+}
+
+//go:embed prompts/*.tmpl
+var defaultTemplates embed.FS
+
+// defaultPrompts are the built-in prompts, used when no template directory is configured
+var defaultPrompts = mustDefaultPrompts()
+
+// mustDefaultPrompts renders the built-in prompts, which must always be valid
+func mustDefaultPrompts() *Prompts {
+	prompts, err := renderPrompts(template.Must(baseTemplates()))
+	if err != nil {
+		panic(err)
+	}
+	return prompts
+}
+
+// baseTemplates parses the built-in templates
+func baseTemplates() (*template.Template, error) {
+	return template.New("prompts").ParseFS(defaultTemplates, "prompts/*.tmpl")
+}
+
+// LoadPrompts loads prompt templates from the *.tmpl files in a directory
+// Templates are Go text/templates named after the document type ("receipt", "pharmacy",
+// "invoice" or "eob"), with an optional "<type>.system" template for the system message and
+// "<type>.version" for the version recorded on scans. Any template not defined in the directory,
+// including the shared "categories", "line items" and "receipt format" blocks, keeps its built-in
+// definition. Without an explicit version, the version is a hash of the rendered prompt.
+func LoadPrompts(dir string) (*Prompts, error) {
+	tmpl, err := baseTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("parsing built-in prompts: %w", err)
+	}
+	if _, err := tmpl.ParseGlob(filepath.Join(dir, "*.tmpl")); err != nil {
+		return nil, fmt.Errorf("parsing prompt templates: %w", err)
+	}
+	return renderPrompts(tmpl)
+}
+
+// renderPrompts renders the prompt for every document type
+func renderPrompts(tmpl *template.Template) (*Prompts, error) {
+	prompts := &Prompts{prompts: make(map[DocumentType]Prompt, len(DocumentTypes))}
+	for _, docType := range DocumentTypes {
+		schemaJSON, err := json.Marshal(docType.schema())
+		if err != nil {
+			return nil, fmt.Errorf("marshaling %s schema: %w", docType, err)
+		}
+		data := promptData{DocumentType: docType, Schema: string(schemaJSON)}
+
+		var prompt Prompt
+		if prompt.Text, err = renderTemplate(tmpl, string(docType), data); err != nil {
+			return nil, err
+		}
+		if prompt.System, err = renderTemplate(tmpl, string(docType)+".system", data); err != nil {
+			return nil, err
+		}
+		if prompt.Version, err = renderTemplate(tmpl, string(docType)+".version", data); err != nil {
+			return nil, err
+		}
+		if prompt.Text == "" {
+			return nil, fmt.Errorf("prompt for %s is empty", docType)
+		}
+		if prompt.Version == "" {
+			hash := sha256.Sum256([]byte(prompt.System + "\x00" + prompt.Text))
+			prompt.Version = "sha256:" + hex.EncodeToString(hash[:6])
+		}
+		prompts.prompts[docType] = prompt
+	}
+	return prompts, nil
+}
+
+// renderTemplate renders the named template, or returns an empty string if it isn't defined
+func renderTemplate(tmpl *template.Template, name string, data promptData) (string, error) {
+	if tmpl.Lookup(name) == nil {
+		return "", nil
+	}
+	var text strings.Builder
+	if err := tmpl.ExecuteTemplate(&text, name, data); err != nil {
+		return "", fmt.Errorf("rendering %s prompt: %w", name, err)
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// For returns the prompt for a document type
+func (p *Prompts) For(docType DocumentType) (Prompt, error) {
+	prompt, ok := p.prompts[docType]
+	if !ok {
+		return Prompt{}, fmt.Errorf("%w: %s", ErrUnknownDocumentType, docType)
+	}
+	return prompt, nil
+}
+
+// forReceipt returns the prompt for a document type scanned into ReceiptData
+func (p *Prompts) forReceipt(docType DocumentType) (Prompt, error) {
+	if !docType.IsReceipt() {
+		return Prompt{}, fmt.Errorf("%w: %s is not a receipt type", ErrUnknownDocumentType, docType)
+	}
+	return p.For(docType)
+}
@@ -0,0 +1,119 @@
+package scanning
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Prompts", func() {
+	It("has a built-in prompt for every document type", func() {
+		for _, docType := range DocumentTypes {
+			prompt, err := defaultPrompts.For(docType)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt.System).NotTo(BeEmpty())
+			Expect(prompt.Text).To(ContainSubstring("Return JSON in this format"))
+			Expect(prompt.Version).To(HavePrefix("sha256:"))
+		}
+	})
+
+	It("shares the category list between receipt prompts", func() {
+		receipt, _ := defaultPrompts.For(DocumentReceipt)
+		pharmacy, _ := defaultPrompts.For(DocumentPharmacy)
+		Expect(receipt.Text).To(ContainSubstring(`"mental_health": therapy`))
+		Expect(pharmacy.Text).To(ContainSubstring(`"mental_health": therapy`))
+		Expect(pharmacy.Version).NotTo(Equal(receipt.Version))
+	})
+
+	It("returns an error for an unknown document type", func() {
+		_, err := defaultPrompts.For("statement")
+		Expect(err).To(MatchError(ErrUnknownDocumentType))
+	})
+
+	Describe("LoadPrompts", func() {
+		var (
+			dir     string
+			prompts *Prompts
+			err     error
+		)
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+		})
+
+		JustBeforeEach(func() {
+			prompts, err = LoadPrompts(dir)
+		})
+
+		writeTemplate := func(name, text string) {
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte(text), 0644)).To(Succeed())
+		}
+
+		When("a document type's prompt is overridden", func() {
+			BeforeEach(func() {
+				writeTemplate("pharmacy.tmpl", `{{define "pharmacy"}}Read the {{.DocumentType}} receipt.
+{{template "receipt format" .}}{{end}}
+{{define "pharmacy.version"}}pharmacy-v3{{end}}`)
+			})
+
+			It("renders the new template with the shared blocks", func() {
+				Expect(err).NotTo(HaveOccurred())
+				prompt, _ := prompts.For(DocumentPharmacy)
+				Expect(prompt.Text).To(HavePrefix("Read the pharmacy receipt.\nReturn JSON in this format"))
+				Expect(prompt.Version).To(Equal("pharmacy-v3"))
+			})
+
+			It("keeps the built-in system message", func() {
+				prompt, _ := prompts.For(DocumentPharmacy)
+				builtIn, _ := defaultPrompts.For(DocumentPharmacy)
+				Expect(prompt.System).To(Equal(builtIn.System))
+			})
+
+			It("keeps the built-in prompts for other document types", func() {
+				Expect(prompts.For(DocumentReceipt)).To(Equal(defaultPrompts.prompts[DocumentReceipt]))
+			})
+		})
+
+		When("a shared block is overridden", func() {
+			BeforeEach(func() {
+				writeTemplate("categories.tmpl", `{{define "categories"}}Always use "other".{{end}}`)
+			})
+
+			It("changes every prompt that uses it and their versions", func() {
+				Expect(err).NotTo(HaveOccurred())
+				prompt, _ := prompts.For(DocumentInvoice)
+				builtIn, _ := defaultPrompts.For(DocumentInvoice)
+				Expect(prompt.Text).To(ContainSubstring(`**Expense Category**: Always use "other".`))
+				Expect(prompt.Version).NotTo(Equal(builtIn.Version))
+			})
+		})
+
+		When("a template can't be parsed", func() {
+			BeforeEach(func() {
+				writeTemplate("receipt.tmpl", `{{define "receipt"}}{{.Missing`)
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ContainSubstring("parsing prompt templates")))
+			})
+		})
+
+		When("a template fails to render", func() {
+			BeforeEach(func() {
+				writeTemplate("receipt.tmpl", `{{define "receipt"}}{{.Missing}}{{end}}`)
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ContainSubstring("rendering receipt prompt")))
+			})
+		})
+
+		When("the directory has no templates", func() {
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -1,17 +1,80 @@
 package scanning
 
+import "errors"
+
 // ReceiptData contains extracted information from a receipt
 type ReceiptData struct {
-	Title  string  `json:"title"`
-	Date   string  `json:"date"`   // ISO 8601 format
-	Amount float64 `json:"amount"`
+	Title             string     `json:"title"`
+	Date              string     `json:"date"` // ISO 8601 format
+	Amount            float64    `json:"amount"`
+	Category          string     `json:"category" schema:"enum=medical|dental|vision|pharmacy|otc|mental_health|mileage|premiums|other"` // Proposed expense category, from the receipt package's taxonomy
+	LineItems         []LineItem `json:"line_items,omitempty"`                                                                           // Individual items, when the document itemizes them
+	LineItemsMismatch bool       `json:"line_items_mismatch,omitempty" schema:"-"`                                                       // Set when the line items don't add up to Amount
+	Fields            Fields     `json:"fields"`                                                                                         // How much to trust each extracted field
+
+	DocumentType  DocumentType `json:"-"` // Kind of document the receipt was scanned as
+	PromptVersion string       `json:"-"` // Version of the prompt that produced this data
+	RawText       string       `json:"-"` // Text read from the document, for scanners that run OCR
+}
+
+// Provenance is where an extracted field's value came from
+type Provenance string
+
+// Supported provenances
+const (
+	ProvenanceExtracted Provenance = "extracted" // Read from the document
+	ProvenanceInferred  Provenance = "inferred"  // Worked out from context rather than read directly
+	ProvenanceDefaulted Provenance = "defaulted" // Filled in because nothing usable was found
+)
+
+// FieldConfidence describes how much to trust a single extracted field
+type FieldConfidence struct {
+	Provenance Provenance `json:"provenance" schema:"enum=extracted|inferred"` // Scanners only report what they read or inferred
+	Confidence float64    `json:"confidence"`                                  // From 0 to 1; zero when the scanner didn't say
+	Disputed   bool       `json:"-"`                                           // Set by Consensus when the scanners read different values
+}
+
+// Fields holds the confidence of each extracted receipt field
+type Fields struct {
+	Title    FieldConfidence `json:"title"`
+	Date     FieldConfidence `json:"date"`
+	Amount   FieldConfidence `json:"amount"`
+	Category FieldConfidence `json:"category"`
 }
 
+// LineItem is a single item extracted from an itemized receipt
+type LineItem struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	Amount      float64 `json:"amount"`   // Line total in dollars; negative for discounts
+	Eligible    bool    `json:"eligible"` // Whether the item is an HSA-eligible expense
+}
+
+// EOBData contains extracted information from an insurance explanation of benefits
+type EOBData struct {
+	Provider              string  `json:"provider"`
+	ServiceDate           string  `json:"service_date"` // ISO 8601 format
+	ClaimNumber           string  `json:"claim_number,omitempty"`
+	Billed                float64 `json:"billed"`                                // Amount the provider charged, in dollars
+	Allowed               float64 `json:"allowed"`                               // Amount the plan allows for the service, in dollars
+	InsurerPaid           float64 `json:"insurer_paid"`                          // Amount the insurer paid, in dollars
+	PatientResponsibility float64 `json:"patient_responsibility"`                // Amount the patient owes, in dollars
+	AmountsMismatch       bool    `json:"amounts_mismatch,omitempty" schema:"-"` // Set when insurer paid and patient responsibility don't add up to allowed
+}
+
+// ErrUnsupported is returned when a scanner can't read a kind of document
+var ErrUnsupported = errors.New("not supported by this scanner")
+
+// ErrQuotaExhausted is matched by errors from an API that is rate limiting or out of quota
+var ErrQuotaExhausted = errors.New("scanner quota exhausted")
+
 // Scanner defines the interface for receipt scanning operations
 type Scanner interface {
 	// ScanReceipt analyzes a receipt image/PDF and extracts metadata
-	ScanReceipt(imageData []byte, contentType string) (*ReceiptData, error)
+	// docType selects the prompt and must be a receipt type (receipt, pharmacy or invoice).
+	ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error)
+	// ScanEOB analyzes an insurance explanation of benefits and extracts the claim amounts
+	ScanEOB(imageData []byte, contentType string) (*EOBData, error)
 	// Close closes the scanner and releases resources
 	Close() error
 }
-
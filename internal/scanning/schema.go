@@ -0,0 +1,217 @@
+package scanning
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// schema is the subset of JSON Schema used to constrain scanner responses
+type schema struct {
+	Type       string             `json:"type"`
+	Properties map[string]*schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *schema            `json:"items,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+}
+
+// Schemas for the responses the scanners ask for
+var (
+	receiptSchema = schemaFor(reflect.TypeFor[ReceiptData]())
+	eobSchema     = schemaFor(reflect.TypeFor[EOBData]())
+)
+
+// schemaFor derives a schema from a Go type using its json tags
+// Fields without omitempty are required. Fields tagged schema:"-" are computed after
+// parsing and left out, and schema:"enum=a|b" limits a string to the listed values.
+func schemaFor(t reflect.Type) *schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &schema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Struct:
+		s := &schema{Type: "object", Properties: make(map[string]*schema)}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			tag := field.Tag.Get("schema")
+			if !field.IsExported() || name == "-" || tag == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			property := schemaFor(field.Type)
+			if values, ok := strings.CutPrefix(tag, "enum="); ok {
+				property.Enum = strings.Split(values, "|")
+			}
+			s.Properties[name] = property
+			if !strings.Contains(opts, "omitempty") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		return s
+	}
+	panic(fmt.Sprintf("no schema for %s", t))
+}
+
+// gemini converts the schema to the form Gemini's response schema expects
+func (s *schema) gemini() *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	types := map[string]genai.Type{
+		"string":  genai.TypeString,
+		"boolean": genai.TypeBoolean,
+		"integer": genai.TypeInteger,
+		"number":  genai.TypeNumber,
+		"array":   genai.TypeArray,
+		"object":  genai.TypeObject,
+	}
+	g := &genai.Schema{
+		Type:     types[s.Type],
+		Items:    s.Items.gemini(),
+		Required: s.Required,
+		Enum:     s.Enum,
+	}
+	if len(s.Enum) > 0 {
+		g.Format = "enum"
+	}
+	if len(s.Properties) > 0 {
+		g.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, property := range s.Properties {
+			g.Properties[name] = property.gemini()
+		}
+	}
+	return g
+}
+
+// SchemaError is returned when a scanner's response doesn't match the schema it was asked for
+type SchemaError struct {
+	Violations []string // One entry per offending field, e.g. "amount: expected number, got string"
+}
+
+func (e *SchemaError) Error() string {
+	return "response doesn't match schema: " + strings.Join(e.Violations, "; ")
+}
+
+// validate checks a JSON document against the schema
+// Missing fields and nulls are allowed here; parsing fills them in with defaults and
+// marks them as defaulted. Values of the wrong type or outside an enum are violations.
+// Enums are matched ignoring case and surrounding space, which parsing cleans up.
+func (s *schema) validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("unmarshaling json: %w", err)
+	}
+
+	var violations []string
+	s.check(value, "", &violations)
+	if len(violations) > 0 {
+		return &SchemaError{Violations: violations}
+	}
+	return nil
+}
+
+// check records every place value doesn't match the schema
+func (s *schema) check(value any, path string, violations *[]string) {
+	if value == nil {
+		return
+	}
+	violation := func(format string, args ...any) {
+		name := path
+		if name == "" {
+			name = "response"
+		}
+		*violations = append(*violations, name+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			violation("expected object, got %s", jsonType(value))
+			return
+		}
+		for name, property := range s.Properties {
+			if v, ok := object[name]; ok {
+				property.check(v, joinPath(path, name), violations)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			violation("expected array, got %s", jsonType(value))
+			return
+		}
+		for i, item := range items {
+			s.Items.check(item, fmt.Sprintf("%s[%d]", path, i), violations)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			violation("expected string, got %s", jsonType(value))
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, strings.ToLower(strings.TrimSpace(str))) {
+			violation("%q is not one of %s", str, strings.Join(s.Enum, ", "))
+		}
+	case "number", "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			violation("expected %s, got %s", s.Type, jsonType(value))
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				violation("expected integer, got %s", number)
+			}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			violation("expected boolean, got %s", jsonType(value))
+		}
+	}
+}
+
+// joinPath appends a property name to a dotted field path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
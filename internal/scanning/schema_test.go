@@ -0,0 +1,59 @@
+package scanning
+
+import (
+	"encoding/json"
+
+	"github.com/google/generative-ai-go/genai"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("receiptSchema", func() {
+	It("requires the fields that aren't optional", func() {
+		Expect(receiptSchema.Required).To(ConsistOf("title", "date", "amount", "category", "fields"))
+	})
+
+	It("leaves out fields computed after parsing", func() {
+		Expect(receiptSchema.Properties).NotTo(HaveKey("line_items_mismatch"))
+		Expect(eobSchema.Properties).NotTo(HaveKey("amounts_mismatch"))
+	})
+
+	It("describes line items as an array of objects", func() {
+		lineItems := receiptSchema.Properties["line_items"]
+		Expect(lineItems.Type).To(Equal("array"))
+		Expect(lineItems.Items.Properties["eligible"].Type).To(Equal("boolean"))
+	})
+
+	It("limits provenance to what a scanner can report", func() {
+		provenance := receiptSchema.Properties["fields"].Properties["date"].Properties["provenance"]
+		Expect(provenance.Enum).To(Equal([]string{"extracted", "inferred"}))
+	})
+
+	It("limits the category to the expense taxonomy", func() {
+		Expect(receiptSchema.Properties["category"].Enum).To(Equal([]string{
+			"medical", "dental", "vision", "pharmacy", "otc", "mental_health", "mileage", "premiums", "other",
+		}))
+	})
+
+	It("marshals as JSON Schema", func() {
+		data, err := json.Marshal(receiptSchema.Properties["fields"].Properties["amount"])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`{
+			"type": "object",
+			"properties": {
+				"provenance": {"type": "string", "enum": ["extracted", "inferred"]},
+				"confidence": {"type": "number"}
+			},
+			"required": ["provenance", "confidence"]
+		}`))
+	})
+
+	It("converts to a Gemini response schema", func() {
+		gemini := receiptSchema.gemini()
+		Expect(gemini.Type).To(Equal(genai.TypeObject))
+		Expect(gemini.Properties["amount"].Type).To(Equal(genai.TypeNumber))
+		provenance := gemini.Properties["fields"].Properties["title"].Properties["provenance"]
+		Expect(provenance.Format).To(Equal("enum"))
+		Expect(provenance.Enum).To(Equal([]string{"extracted", "inferred"}))
+	})
+})
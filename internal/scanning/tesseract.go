@@ -0,0 +1,102 @@
+package scanning
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Tesseract implements the Scanner interface by running the Tesseract OCR engine locally
+// and extracting the merchant, date and total with rules, so no GPU or cloud API is needed.
+// It's less accurate than the LLM scanners; its details are marked for review accordingly.
+type Tesseract struct {
+	binary   string
+	language string
+	opts     options
+}
+
+// NewTesseract creates a new Tesseract Scanner instance
+// binary is the tesseract executable, found on PATH if it isn't a path. language is a
+// Tesseract language code such as "eng", whose trained data must be installed.
+func NewTesseract(binary string, language string, opts ...Option) (*Tesseract, error) {
+	if binary == "" {
+		binary = "tesseract"
+	}
+	if language == "" {
+		language = "eng"
+	}
+
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("finding tesseract: %w", err)
+	}
+
+	return &Tesseract{
+		binary:   path,
+		language: language,
+		opts:     newOptions(opts),
+	}, nil
+}
+
+// ScanReceipt reads the text of a receipt and extracts metadata from it
+// Every page of a PDF is read, so totals on the last page are found.
+func (t *Tesseract) ScanReceipt(imageData []byte, contentType string, docType DocumentType) (*ReceiptData, error) {
+	if !docType.IsReceipt() {
+		return nil, fmt.Errorf("%w: %s is not a receipt type", ErrUnknownDocumentType, docType)
+	}
+
+	// Prepare image data (convert to PNG if needed, one image per PDF page)
+	pages, _, err := prepareImages(imageData, contentType, "", t.opts.pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	for i, page := range pages {
+		pageText, err := t.recognize(page)
+		if err != nil {
+			return nil, fmt.Errorf("reading page %d: %w", i+1, err)
+		}
+		text.WriteString(pageText)
+		text.WriteString("\n")
+	}
+
+	data := extractReceiptText(text.String())
+	data.DocumentType = docType
+	if err := normalizeReceipt(data); err != nil {
+		return nil, fmt.Errorf("parsing receipt data: %w", err)
+	}
+
+	return data, nil
+}
+
+// ScanEOB isn't supported; EOB amounts are laid out in tables the rules can't read reliably
+func (t *Tesseract) ScanEOB(imageData []byte, contentType string) (*EOBData, error) {
+	return nil, fmt.Errorf("%w: the tesseract scanner can't read EOBs", ErrUnsupported)
+}
+
+// recognize runs Tesseract on a PNG image and returns the text it read
+func (t *Tesseract) recognize(png []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.opts.timeoutOr(60*time.Second))
+	defer cancel()
+
+	// Page segmentation mode 4 reads a single column of text of variable sizes, like a receipt
+	cmd := exec.CommandContext(ctx, t.binary, "stdin", "stdout", "-l", t.language, "--psm", "4")
+	cmd.Stdin = bytes.NewReader(png)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Close releases resources (no-op; Tesseract runs per scan)
+func (t *Tesseract) Close() error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package scanning
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tesseract", func() {
+	var (
+		binary      string
+		output      string
+		data        []byte
+		contentType string
+		docType     DocumentType
+		receipt     *ReceiptData
+		err         error
+	)
+
+	BeforeEach(func() {
+		// A stand-in for tesseract that records its arguments and input and prints the OCR output
+		dir := GinkgoT().TempDir()
+		binary = filepath.Join(dir, "tesseract")
+		output = "Corner Drug\n2024-02-01\nTOTAL 12.34\n"
+		script := `#!/bin/sh
+echo "$@" >> "` + dir + `/args"
+cat > "` + dir + `/input.png"
+cat "` + dir + `/output"
+`
+		Expect(os.WriteFile(binary, []byte(script), 0755)).To(Succeed())
+
+		var buf bytes.Buffer
+		Expect(png.Encode(&buf, testDocument(60, 80, 0))).To(Succeed())
+		data = buf.Bytes()
+		contentType = "image/png"
+		docType = DocumentReceipt
+	})
+
+	JustBeforeEach(func() {
+		Expect(os.WriteFile(filepath.Join(filepath.Dir(binary), "output"), []byte(output), 0644)).To(Succeed())
+		scanner, newErr := NewTesseract(binary, "eng", WithPDFDPI(36))
+		Expect(newErr).NotTo(HaveOccurred())
+		receipt, err = scanner.ScanReceipt(data, contentType, docType)
+	})
+
+	It("extracts the receipt from the OCR text", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(receipt.Title).To(Equal("Corner Drug"))
+		Expect(receipt.Date).To(Equal("2024-02-01"))
+		Expect(receipt.Amount).To(Equal(12.34))
+		Expect(receipt.RawText).To(Equal(output + "\n"))
+	})
+
+	It("runs tesseract on the PNG with the language", func() {
+		args, _ := os.ReadFile(filepath.Join(filepath.Dir(binary), "args"))
+		Expect(string(args)).To(Equal("stdin stdout -l eng --psm 4\n"))
+		input, _ := os.ReadFile(filepath.Join(filepath.Dir(binary), "input.png"))
+		Expect(input).To(Equal(data))
+	})
+
+	It("defaults the category, which OCR can't tell", func() {
+		Expect(receipt.Fields.Category.Provenance).To(Equal(ProvenanceDefaulted))
+	})
+
+	When("the document is a multi-page PDF", func() {
+		BeforeEach(func() {
+			data = testPDF(3)
+			contentType = "application/pdf"
+		})
+
+		It("reads every page", func() {
+			args, _ := os.ReadFile(filepath.Join(filepath.Dir(binary), "args"))
+			Expect(bytes.Count(args, []byte("stdin"))).To(Equal(3))
+		})
+	})
+
+	When("nothing is read", func() {
+		BeforeEach(func() {
+			output = ""
+		})
+
+		It("defaults every detail", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(receipt.Title).To(Equal("Unknown Expense"))
+			Expect(receipt.Fields.Title.Provenance).To(Equal(ProvenanceDefaulted))
+			Expect(receipt.Fields.Amount.Provenance).To(Equal(ProvenanceDefaulted))
+		})
+	})
+
+	When("the document type isn't a receipt", func() {
+		BeforeEach(func() {
+			docType = DocumentEOB
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError(ErrUnknownDocumentType))
+		})
+	})
+
+	Describe("ScanEOB", func() {
+		It("isn't supported", func() {
+			scanner, _ := NewTesseract(binary, "")
+			_, err := scanner.ScanEOB(data, contentType)
+			Expect(err).To(MatchError(ErrUnsupported))
+		})
+	})
+})
+
+var _ = Describe("NewTesseract", func() {
+	When("the binary can't be found", func() {
+		It("returns an error", func() {
+			_, err := NewTesseract(filepath.Join(GinkgoT().TempDir(), "missing"), "")
+			Expect(err).To(MatchError(ContainSubstring("finding tesseract")))
+		})
+	})
+})